@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/souvikjs01/go-ecommerce/config"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/notify"
 	"github.com/souvikjs01/go-ecommerce/routes"
+	"github.com/souvikjs01/go-ecommerce/services"
 )
 
 func main() {
@@ -14,13 +18,30 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error in Setting up the Configuration file: %v", err)
 	}
+	model.DefaultCurrency = cfg.DEFAULT_CURRENCY
 	// db Connection
 	client, err := config.NewDB(cfg)
 	if err != nil {
 		log.Fatalf("Error in Setting up the DB connection: %v", err)
 	}
+	if err := config.EnsureIndexes(client); err != nil {
+		log.Fatalf("Error in creating the DB indexes: %v", err)
+	}
+	if failed, err := services.NewProductImportService(client).FailInterruptedImports(); err != nil {
+		fmt.Println("failed to mark interrupted product imports:", err)
+	} else if failed > 0 {
+		fmt.Println("marked interrupted product imports failed:", failed)
+	}
+	// background jobs
+	notifier := notify.New(cfg.NOTIFY_WEBHOOK_URL)
+	services.NewAbandonedCartJob(client, notifier, cfg.ABANDONED_CART_THRESHOLDS).
+		Start(context.Background(), cfg.ABANDONED_CART_CHECK_INTERVAL)
+	services.NewNotificationQueue(client, notifier, cfg.PUBLIC_BASE_URL).
+		Start(context.Background(), cfg.NOTIFICATION_QUEUE_INTERVAL)
+	services.NewCartReconcileJob(client, services.NewCartOptions(cfg)).
+		Start(context.Background(), cfg.CART_RECONCILE_INTERVAL)
 	// router
 	fmt.Println("okay we are good to go")
-	router := routes.SetupRoutes(client)
+	router := routes.SetupRoutes(client, cfg)
 	router.Run(":8080")
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+
+	"github.com/souvikjs01/go-ecommerce/config"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+// one-off data migrations, run with: go run ./cmd/migrate
+func main() {
+	cfg, err := config.SetConfig()
+	if err != nil {
+		log.Fatalf("Error in Setting up the Configuration file: %v", err)
+	}
+	model.DefaultCurrency = cfg.DEFAULT_CURRENCY
+
+	client, err := config.NewDB(cfg)
+	if err != nil {
+		log.Fatalf("Error in Setting up the DB connection: %v", err)
+	}
+
+	if err := services.MigrateMoneyFields(client); err != nil {
+		log.Fatalf("money migration failed: %v", err)
+	}
+	if err := services.MigrateCartTimestamps(client); err != nil {
+		log.Fatalf("cart timestamp migration failed: %v", err)
+	}
+	if err := services.MigrateReviewStatus(client); err != nil {
+		log.Fatalf("review status migration failed: %v", err)
+	}
+	if err := services.MigrateProductCategories(client); err != nil {
+		log.Fatalf("category migration failed: %v", err)
+	}
+	if err := services.MigrateOrderNumbers(client, cfg.ORDER_NUMBER_PREFIX); err != nil {
+		log.Fatalf("order number migration failed: %v", err)
+	}
+	if err := services.MigrateCouponUsage(client); err != nil {
+		log.Fatalf("coupon usage migration failed: %v", err)
+	}
+}
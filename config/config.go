@@ -2,26 +2,138 @@ package config
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // defining envireoment variables Structure
 type Config struct {
-	MONGO_URI   string
-	PORT        string
-	JWT_SECRET  string
-	UPSTASH_URI string
+	MONGO_URI           string
+	PORT                string
+	JWT_SECRET          string
+	UPSTASH_URI         string
+	ORDER_NUMBER_PREFIX string
+	STORAGE_DIR         string
+	DEFAULT_CURRENCY    string
+	// catalogue prices already contain the tax of the shipping region
+	PRICES_INCLUDE_TAX bool
+	// how long an untouched guest cart is kept
+	GUEST_CART_TTL_HOURS int
+	// the site the guest cart cookie is sent to, and whether only over HTTPS
+	GUEST_CART_COOKIE_DOMAIN string
+	GUEST_CART_COOKIE_SECURE bool
+	// "sum" adds the guest quantities to the saved cart, "latest" lets the
+	// guest cart's quantities win
+	CART_MERGE_STRATEGY    string
+	CART_MAX_LINE_QUANTITY int
+	// carts left unchanged this long are removed, 0 keeps them forever
+	CART_TTL_DAYS int
+	// how often cart lines are checked against the catalogue
+	CART_RECONCILE_INTERVAL time.Duration
+	// out of stock lines are removed from carts instead of flagged
+	CART_REMOVE_UNAVAILABLE bool
+	// idle times after which abandoned cart reminders are sent, e.g. "1h,24h,72h"
+	ABANDONED_CART_THRESHOLDS     []time.Duration
+	ABANDONED_CART_CHECK_INTERVAL time.Duration
+	// notifications are posted here, or only logged when empty
+	NOTIFY_WEBHOOK_URL string
+	// how often queued notifications are sent
+	NOTIFICATION_QUEUE_INTERVAL time.Duration
+	// where the API is reachable from outside, links in notifications use it
+	PUBLIC_BASE_URL string
+	// reviews containing these words or phrases are held, comma separated
+	REVIEW_BANNED_WORDS []string
+	// reviews containing links are held
+	REVIEW_HOLD_LINKS bool
+	// reviews that pass the checks are published without a moderator
+	REVIEW_AUTO_APPROVE bool
+	// a published review goes back to the moderation queue after this many
+	// reports, 0 never hides it
+	REVIEW_REPORT_THRESHOLD int
+	// "local" keeps files below STORAGE_DIR, "s3" in an S3 compatible bucket
+	STORAGE_DRIVER string
+	S3_ENDPOINT    string
+	S3_REGION      string
+	S3_BUCKET      string
+	S3_ACCESS_KEY  string
+	S3_SECRET_KEY  string
+	// MinIO and most other stand-ins need path style addressing
+	S3_PATH_STYLE bool
+	// largest accepted image upload in bytes
+	IMAGE_MAX_BYTES       int64
+	IMAGE_MAX_PER_PRODUCT int
+	// widths of the resized copies of product images, e.g. "160,480,1024"
+	IMAGE_WIDTHS []int
+	// image URLs start with this, e.g. a CDN in front of the bucket, by
+	// default the API serves them
+	IMAGE_BASE_URL string
+	// largest accepted product import file in bytes
+	IMPORT_MAX_BYTES int64
 }
 
 func SetConfig() (*Config, error) {
 	viper.SetConfigFile(".env")
+	viper.SetDefault("ORDER_NUMBER_PREFIX", "ORD")
+	viper.SetDefault("STORAGE_DRIVER", "local")
+	viper.SetDefault("STORAGE_DIR", "./storage_data")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("DEFAULT_CURRENCY", "USD")
+	viper.SetDefault("PRICES_INCLUDE_TAX", false)
+	viper.SetDefault("GUEST_CART_TTL_HOURS", 168)
+	viper.SetDefault("GUEST_CART_COOKIE_DOMAIN", "localhost")
+	viper.SetDefault("GUEST_CART_COOKIE_SECURE", false)
+	viper.SetDefault("CART_MERGE_STRATEGY", "sum")
+	viper.SetDefault("CART_MAX_LINE_QUANTITY", 99)
+	viper.SetDefault("CART_TTL_DAYS", 90)
+	viper.SetDefault("CART_RECONCILE_INTERVAL", "1h")
+	viper.SetDefault("ABANDONED_CART_THRESHOLDS", "1h,24h,72h")
+	viper.SetDefault("ABANDONED_CART_CHECK_INTERVAL", "15m")
+	viper.SetDefault("NOTIFICATION_QUEUE_INTERVAL", "30s")
+	viper.SetDefault("PUBLIC_BASE_URL", "http://localhost:8080")
+	viper.SetDefault("REVIEW_HOLD_LINKS", true)
+	viper.SetDefault("REVIEW_AUTO_APPROVE", true)
+	viper.SetDefault("REVIEW_REPORT_THRESHOLD", 3)
+	viper.SetDefault("IMAGE_MAX_BYTES", 10<<20)
+	viper.SetDefault("IMAGE_MAX_PER_PRODUCT", 10)
+	viper.SetDefault("IMAGE_WIDTHS", "160,480,1024")
+	viper.SetDefault("IMPORT_MAX_BYTES", 20<<20)
 	err := viper.ReadInConfig()
 
 	if err != nil {
 		return nil, err
 	}
 
+	thresholds, err := parseDurations(viper.GetString("ABANDONED_CART_THRESHOLDS"))
+	if err != nil {
+		return nil, fmt.Errorf("ABANDONED_CART_THRESHOLDS: %w", err)
+	}
+
+	widths, err := parseInts(viper.GetString("IMAGE_WIDTHS"))
+	if err != nil {
+		return nil, fmt.Errorf("IMAGE_WIDTHS: %w", err)
+	}
+	switch viper.GetString("CART_MERGE_STRATEGY") {
+	case "sum", "latest":
+	default:
+		return nil, fmt.Errorf("CART_MERGE_STRATEGY: unknown strategy %q, use sum or latest", viper.GetString("CART_MERGE_STRATEGY"))
+	}
+	switch viper.GetString("STORAGE_DRIVER") {
+	case "local":
+	case "s3":
+		if viper.GetString("S3_ENDPOINT") == "" || viper.GetString("S3_BUCKET") == "" {
+			return nil, fmt.Errorf("STORAGE_DRIVER s3 needs S3_ENDPOINT and S3_BUCKET")
+		}
+	default:
+		return nil, fmt.Errorf("STORAGE_DRIVER: unknown driver %q, use local or s3", viper.GetString("STORAGE_DRIVER"))
+	}
+	imageBaseURL := viper.GetString("IMAGE_BASE_URL")
+	if imageBaseURL == "" {
+		imageBaseURL = strings.TrimRight(viper.GetString("PUBLIC_BASE_URL"), "/") + "/api/v1/images"
+	}
+
 	port := viper.GetString("PORT")
 	fmt.Printf("PORT from the .env : %s", port)
 
@@ -32,5 +144,90 @@ func SetConfig() (*Config, error) {
 		PORT:        viper.GetString("PORT"),
 		JWT_SECRET:  viper.GetString("JWT_SECRET"),
 		UPSTASH_URI: viper.GetString("UPSTASH_URI"),
+
+		ORDER_NUMBER_PREFIX: viper.GetString("ORDER_NUMBER_PREFIX"),
+		STORAGE_DIR:         viper.GetString("STORAGE_DIR"),
+		DEFAULT_CURRENCY:    viper.GetString("DEFAULT_CURRENCY"),
+		PRICES_INCLUDE_TAX:  viper.GetBool("PRICES_INCLUDE_TAX"),
+
+		GUEST_CART_TTL_HOURS:     viper.GetInt("GUEST_CART_TTL_HOURS"),
+		GUEST_CART_COOKIE_DOMAIN: viper.GetString("GUEST_CART_COOKIE_DOMAIN"),
+		GUEST_CART_COOKIE_SECURE: viper.GetBool("GUEST_CART_COOKIE_SECURE"),
+		CART_MERGE_STRATEGY:      viper.GetString("CART_MERGE_STRATEGY"),
+		CART_MAX_LINE_QUANTITY:   viper.GetInt("CART_MAX_LINE_QUANTITY"),
+
+		CART_TTL_DAYS:           viper.GetInt("CART_TTL_DAYS"),
+		CART_RECONCILE_INTERVAL: viper.GetDuration("CART_RECONCILE_INTERVAL"),
+		CART_REMOVE_UNAVAILABLE: viper.GetBool("CART_REMOVE_UNAVAILABLE"),
+
+		ABANDONED_CART_THRESHOLDS:     thresholds,
+		ABANDONED_CART_CHECK_INTERVAL: viper.GetDuration("ABANDONED_CART_CHECK_INTERVAL"),
+		NOTIFY_WEBHOOK_URL:            viper.GetString("NOTIFY_WEBHOOK_URL"),
+		NOTIFICATION_QUEUE_INTERVAL:   viper.GetDuration("NOTIFICATION_QUEUE_INTERVAL"),
+		PUBLIC_BASE_URL:               viper.GetString("PUBLIC_BASE_URL"),
+
+		REVIEW_BANNED_WORDS:     parseList(viper.GetString("REVIEW_BANNED_WORDS")),
+		REVIEW_HOLD_LINKS:       viper.GetBool("REVIEW_HOLD_LINKS"),
+		REVIEW_AUTO_APPROVE:     viper.GetBool("REVIEW_AUTO_APPROVE"),
+		REVIEW_REPORT_THRESHOLD: viper.GetInt("REVIEW_REPORT_THRESHOLD"),
+
+		STORAGE_DRIVER: viper.GetString("STORAGE_DRIVER"),
+		S3_ENDPOINT:    viper.GetString("S3_ENDPOINT"),
+		S3_REGION:      viper.GetString("S3_REGION"),
+		S3_BUCKET:      viper.GetString("S3_BUCKET"),
+		S3_ACCESS_KEY:  viper.GetString("S3_ACCESS_KEY"),
+		S3_SECRET_KEY:  viper.GetString("S3_SECRET_KEY"),
+		S3_PATH_STYLE:  viper.GetBool("S3_PATH_STYLE"),
+
+		IMAGE_MAX_BYTES:       viper.GetInt64("IMAGE_MAX_BYTES"),
+		IMAGE_MAX_PER_PRODUCT: viper.GetInt("IMAGE_MAX_PER_PRODUCT"),
+		IMAGE_WIDTHS:          widths,
+		IMAGE_BASE_URL:        strings.TrimRight(imageBaseURL, "/"),
+
+		IMPORT_MAX_BYTES: viper.GetInt64("IMPORT_MAX_BYTES"),
 	}, nil
 }
+
+// comma separated durations, e.g. "1h,24h"
+func parseDurations(value string) ([]time.Duration, error) {
+	durations := []time.Duration{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// comma separated values, blanks are dropped
+func parseList(value string) []string {
+	values := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// comma separated positive numbers, e.g. "160,480"
+func parseInts(value string) ([]int, error) {
+	numbers := []int{}
+	for _, part := range parseList(value) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -27,3 +29,279 @@ func NewDB(cfg *Config) (*mongo.Client, error) {
 	fmt.Println("DataBase Connected Successfully!")
 	return mongoClient, nil
 }
+
+// EnsureIndexes creates the indexes the services rely on
+func EnsureIndexes(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	db := client.Database("go-ecomm")
+
+	// order numbers are unique, older orders without one are skipped
+	_, err := db.Collection("orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ordernumber", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// coupon codes are looked up case insensitively, they are stored upper case
+	_, err = db.Collection("coupons").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("coupon_redemptions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "couponid", Value: 1}, {Key: "userid", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	// per customer coupon limits, one counter per coupon and customer
+	_, err = db.Collection("coupon_usage").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "couponid", Value: 1}, {Key: "userid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// zones are looked up by the destination country, methods by their zone
+	_, err = db.Collection("shipping_zones").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "countries", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("shipping_methods").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "zoneid", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// guest carts are removed once they expire
+	_, err = db.Collection("guest_carts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresat", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// idle carts are scanned for reminders, reminders are looked up per user
+	// when an order recovers them
+	_, err = db.Collection("carts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "reminderssent", Value: 1}, {Key: "updatedat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("cart_reminders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userid", Value: 1}, {Key: "sentat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// carts left unchanged past the cart TTL are removed
+	_, err = db.Collection("carts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "updatedat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// wishlists are listed per user, shared ones are opened by their token
+	_, err = db.Collection("wishlists").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userid", Value: 1}, {Key: "kind", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("wishlists").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sharetoken", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"sharetoken": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// one alert per user, product, change and variant, unsubscribe links
+	// find it by its token
+	_, err = db.Collection("product_alerts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "productid", Value: 1}, {Key: "type", Value: 1}, {Key: "userid", Value: 1},
+			{Key: "size", Value: 1}, {Key: "color", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("product_alerts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "unsubscribetoken", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// a notification is queued once per dedup key, the worker takes the due ones
+	_, err = db.Collection("notifications").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "dedupkey", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("notifications").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// one review per customer and product, listed per product
+	_, err = db.Collection("reviews").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "productid", Value: 1}, {Key: "userid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("reviews").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "productid", Value: 1}, {Key: "createdat", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// moderation queue by status, oldest first
+	_, err = db.Collection("reviews").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	// one report per customer and review
+	_, err = db.Collection("review_reports").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reviewid", Value: 1}, {Key: "userid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("review_moderation_log").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "reviewid", Value: 1}, {Key: "createdat", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// questions and answers listed per product and question, and queued by status
+	_, err = db.Collection("questions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "productid", Value: 1}, {Key: "status", Value: 1}, {Key: "createdat", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("questions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("answers").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "questionid", Value: 1}, {Key: "status", Value: 1}, {Key: "helpful", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("answers").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	// one helpful vote per customer and answer
+	_, err = db.Collection("answer_votes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "answerid", Value: 1}, {Key: "userid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// products refer to categories by slug, subtrees are found by path
+	_, err = db.Collection("categories").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("categories").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "path", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("products").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "categories", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// imports match products by SKU, products without one are left out
+	_, err = db.Collection("products").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sku", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("product_imports").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdat", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// one invoice per order and one credit note per refund, so retries can't
+	// issue them twice
+	_, err = db.Collection("invoices").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "orderid", Value: 1}, {Key: "type", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"type": "invoice"}),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("invoices").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "refundid", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"refundid": bson.M{"$type": "objectId"}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// one tax rate per country, region and category
+	_, err = db.Collection("tax_rates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "country", Value: 1}, {Key: "region", Value: 1}, {Key: "category", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type AddressHandlerStruct struct {
+	service services.AddressService
+}
+
+func NewAddressHandler(service services.AddressService) *AddressHandlerStruct {
+	return &AddressHandlerStruct{
+		service: service,
+	}
+}
+
+func (h *AddressHandlerStruct) AddAddressHandler(ctx *gin.Context) {
+	var payload request.AddressPayload
+	userId := ctx.GetString("userId")
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		address, err := h.service.AddAddress(&payload, userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- address
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case address := <-addressChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"address": address,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *AddressHandlerStruct) GetMyAddressesHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+
+	addressesChan := make(chan *[]model.Address, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		addresses, err := h.service.GetUserAddresses(userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressesChan <- addresses
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case addresses := <-addressesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"addresses": addresses,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *AddressHandlerStruct) UpdateAddressHandler(ctx *gin.Context) {
+	var payload request.UpdateAddressPayload
+	userId := ctx.GetString("userId")
+	addressId := ctx.Param("addressId")
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		address, err := h.service.UpdateAddress(&payload, userId, addressId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- address
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case address := <-addressChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"address": address,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *AddressHandlerStruct) DeleteAddressHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	addressId := ctx.Param("addressId")
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		address, err := h.service.DeleteAddress(userId, addressId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- address
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case address := <-addressChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"address": address,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type AlertHandlerStruct struct {
+	service services.AlertService
+}
+
+func NewAlertHandler(service services.AlertService) *AlertHandlerStruct {
+	return &AlertHandlerStruct{
+		service: service,
+	}
+}
+
+// subscribe to a product coming back in stock or getting cheaper
+func (h *AlertHandlerStruct) SubscribeHandler(ctx *gin.Context) {
+	var payload request.ProductAlertPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	alertChan := make(chan *model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		alert, err := h.service.Subscribe(&payload, ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertChan <- alert
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case alert := <-alertChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"alert":   alert,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *AlertHandlerStruct) GetMyAlertsHandler(ctx *gin.Context) {
+	alertsChan := make(chan *[]model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		alerts, err := h.service.GetMyAlerts(ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertsChan <- alerts
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case alerts := <-alertsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"alerts":  alerts,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *AlertHandlerStruct) DeleteAlertHandler(ctx *gin.Context) {
+	alertChan := make(chan *model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		alert, err := h.service.DeleteAlert(ctx.GetString("userId"), ctx.Param("alertId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertChan <- alert
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case alert := <-alertChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"alert":   alert,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// unsubscribe link of an alert notification
+func (h *AlertHandlerStruct) UnsubscribeHandler(ctx *gin.Context) {
+	alertChan := make(chan *model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		alert, err := h.service.Unsubscribe(ctx.Param("token"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertChan <- alert
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case alert := <-alertChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"alert":   alert,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -10,18 +10,23 @@ import (
 	"github.com/souvikjs01/go-ecommerce/request"
 	"github.com/souvikjs01/go-ecommerce/services"
 	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type AuthInterface interface{}
 
 // Dependency injection of Services
 type AuthHandlerStruct struct {
-	services services.AuthService
+	services    services.AuthService
+	carts       services.CartService
+	guestCookie GuestCartCookie
 }
 
-func NewAuthHandler(services services.AuthService) *AuthHandlerStruct {
+func NewAuthHandler(services services.AuthService, carts services.CartService, guestCookie GuestCartCookie) *AuthHandlerStruct {
 	return &AuthHandlerStruct{
-		services: services,
+		services:    services,
+		carts:       carts,
+		guestCookie: guestCookie,
 	}
 }
 
@@ -92,6 +97,7 @@ func (h *AuthHandlerStruct) Signup(ctx *gin.Context) {
 			)
 		}
 	case result_user := <-user_chan:
+		h.mergeGuestCart(ctx, result_user.ID.Hex())
 		ctx.JSON(
 			http.StatusAccepted,
 			gin.H{
@@ -153,6 +159,7 @@ func (h *AuthHandlerStruct) Login(ctx *gin.Context) {
 			},
 		)
 	case res_response := <-user_chan:
+		h.mergeGuestCart(ctx, res_response.ID.Hex())
 		ctx.JSON(
 			http.StatusAccepted,
 			gin.H{
@@ -197,3 +204,17 @@ func (h *AuthHandlerStruct) Logout(ctx *gin.Context) {
 		"message": "Logged out successfully!",
 	})
 }
+
+// moves the shopper's guest cart into their cart, a failed merge doesn't
+// fail the login
+func (h *AuthHandlerStruct) mergeGuestCart(ctx *gin.Context, userId string) {
+	guestCartId := guestCartID(ctx)
+	if guestCartId == "" {
+		return
+	}
+	if _, err := h.carts.MergeGuestCart(guestCartId, userId); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		fmt.Println("failed to merge guest cart:", err)
+		return
+	}
+	clearGuestCartCookie(ctx, h.guestCookie)
+}
@@ -11,12 +11,14 @@ import (
 )
 
 type CartHandlerStruct struct {
-	service services.CartService
+	service     services.CartService
+	guestCookie GuestCartCookie
 }
 
-func NewCartHandler(service services.CartService) *CartHandlerStruct {
+func NewCartHandler(service services.CartService, guestCookie GuestCartCookie) *CartHandlerStruct {
 	return &CartHandlerStruct{
-		service: service,
+		service:     service,
+		guestCookie: guestCookie,
 	}
 }
 
@@ -72,14 +74,16 @@ func (h *CartHandlerStruct) AddToCartHandler(ctx *gin.Context) {
 
 }
 
+// the cart with product details, prices, totals and warnings
 func (h *CartHandlerStruct) GetMyCart(ctx *gin.Context) {
 	userId := ctx.GetString("userId")
+	currency := requestedCurrency(ctx)
 
 	cartChan := make(chan *model.Cart, 32)
 	errChan := make(chan error, 32)
 
 	go func() {
-		cart, err := h.service.GetCartDetails(userId)
+		cart, err := h.service.GetCartDetails(userId, currency)
 		if err != nil {
 			errChan <- err
 			return
@@ -102,7 +106,7 @@ func (h *CartHandlerStruct) GetMyCart(ctx *gin.Context) {
 			})
 			return
 		case err := <-errChan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"success": false,
 				"error":   err.Error(),
 			})
@@ -223,3 +227,321 @@ func (h *CartHandlerStruct) UpdateCarthandler(ctx *gin.Context) {
 		}
 	}
 }
+
+func (h *CartHandlerStruct) ApplyCouponHandler(ctx *gin.Context) {
+	var payload request.ApplyCouponPayload
+	userId := ctx.GetString("userId")
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	currency := requestedCurrency(ctx)
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.ApplyCoupon(payload.Code, userId, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *CartHandlerStruct) RemoveCouponHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	code := ctx.Param("code")
+	currency := requestedCurrency(ctx)
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.RemoveCoupon(code, userId, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// shipping methods for the cart, for ?address_id=, an estimate from
+// ?country=&region=&postal_code=, or the default shipping address
+func (h *CartHandlerStruct) ShippingOptionsHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	addressId := ctx.Query("address_id")
+	currency := requestedCurrency(ctx)
+
+	var estimate *model.Address
+	if country := ctx.Query("country"); country != "" {
+		estimate = &model.Address{
+			Country:    country,
+			Region:     ctx.Query("region"),
+			PostalCode: ctx.Query("postal_code"),
+		}
+		estimate.Normalize()
+	}
+
+	optionsChan := make(chan *[]model.ShippingOption, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		options, err := h.service.ShippingOptions(userId, addressId, estimate, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		optionsChan <- options
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case options := <-optionsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"methods": options,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// add a product to the guest cart of a shopper who is not logged in
+func (h *CartHandlerStruct) AddToGuestCartHandler(ctx *gin.Context) {
+	var payload request.AddToCartPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	guestCartId := guestCartID(ctx)
+
+	cartChan := make(chan *model.GuestCart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.AddToGuestCart(&payload, guestCartId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		setGuestCartCookie(ctx, h.guestCookie, cart)
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// the guest cart with product details, prices and totals
+func (h *CartHandlerStruct) GetGuestCartHandler(ctx *gin.Context) {
+	guestCartId := guestCartID(ctx)
+	currency := requestedCurrency(ctx)
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.GetGuestCart(guestCartId, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *CartHandlerStruct) RemoveFromGuestCartHandler(ctx *gin.Context) {
+	guestCartId := guestCartID(ctx)
+	productId := ctx.Param("productId")
+
+	cartChan := make(chan *model.GuestCart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.RemoveFromGuestCart(guestCartId, productId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		setGuestCartCookie(ctx, h.guestCookie, cart)
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// abandoned cart reminders and recoveries for ?from=&to=, admin only
+func (h *CartHandlerStruct) AbandonedCartReportHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	reportChan := make(chan *model.AbandonedCartReport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		report, err := h.service.GetAbandonedCartReport(ctx.Query("from"), ctx.Query("to"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reportChan <- report
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case report := <-reportChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"report":  report,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// clear the notices about changes made to the cart once they are shown
+func (h *CartHandlerStruct) DismissNoticesHandler(ctx *gin.Context) {
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.DismissNotices(ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type CategoryHandlerStruct struct {
+	service    services.CategoryService
+	currencies services.CurrencyService
+}
+
+func NewCategoryHandler(service services.CategoryService, currencies services.CurrencyService) *CategoryHandlerStruct {
+	return &CategoryHandlerStruct{
+		service:    service,
+		currencies: currencies,
+	}
+}
+
+func (h *CategoryHandlerStruct) CreateCategoryHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var payload request.CategoryPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		category, err := h.service.CreateCategory(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- category
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case category := <-categoryChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success":  true,
+			"category": category,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// rename, describe, reorder or move a category
+func (h *CategoryHandlerStruct) UpdateCategoryHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var payload request.UpdateCategoryPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		category, err := h.service.UpdateCategory(&payload, ctx.Param("categoryId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- category
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case category := <-categoryChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"category": category,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// delete a category, ?reassign_to=<slug> moves its products first
+func (h *CategoryHandlerStruct) DeleteCategoryHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		category, err := h.service.DeleteCategory(ctx.Param("categoryId"), ctx.Query("reassign_to"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- category
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case category := <-categoryChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"category": category,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *CategoryHandlerStruct) GetCategoryTreeHandler(ctx *gin.Context) {
+	treeChan := make(chan *[]model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		tree, err := h.service.GetCategoryTree()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		treeChan <- tree
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case tree := <-treeChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"categories": tree,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// a category with its subcategories
+func (h *CategoryHandlerStruct) GetCategoryHandler(ctx *gin.Context) {
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		category, err := h.service.GetCategory(ctx.Param("slug"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- category
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case category := <-categoryChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"category": category,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// the path from the root to a category
+func (h *CategoryHandlerStruct) GetBreadcrumbsHandler(ctx *gin.Context) {
+	breadcrumbsChan := make(chan *[]model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		breadcrumbs, err := h.service.GetBreadcrumbs(ctx.Param("slug"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		breadcrumbsChan <- breadcrumbs
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case breadcrumbs := <-breadcrumbsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":     true,
+			"breadcrumbs": breadcrumbs,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// products in a category and its subcategories, ?page=&limit=
+func (h *CategoryHandlerStruct) GetCategoryProductsHandler(ctx *gin.Context) {
+	var query request.CategoryProductsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	resultChan := make(chan *model.CategoryProducts, 32)
+	errChan := make(chan error, 32)
+
+	currency := requestedCurrency(ctx)
+
+	go func() {
+		result, err := h.service.GetCategoryProducts(ctx.Param("slug"), &query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if err := h.currencies.PriceProducts(result.Products, currency); err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case result := <-resultChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"category": result.Category,
+			"products": result.Products,
+			"page":     result.Page,
+			"limit":    result.Limit,
+			"total":    result.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type CouponHandlerStruct struct {
+	service services.CouponService
+}
+
+func NewCouponHandler(service services.CouponService) *CouponHandlerStruct {
+	return &CouponHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: create a coupon code
+func (h *CouponHandlerStruct) CreateCouponHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.CouponPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		coupon, err := h.service.CreateCoupon(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- coupon
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case coupon := <-couponChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"coupon":  coupon,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: all coupons
+func (h *CouponHandlerStruct) GetCouponsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	couponsChan := make(chan *[]model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		coupons, err := h.service.GetCoupons()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponsChan <- coupons
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case coupons := <-couponsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"coupons": coupons,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: change the limits or validity of a coupon
+func (h *CouponHandlerStruct) UpdateCouponHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.UpdateCouponPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	couponId := ctx.Param("couponId")
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		coupon, err := h.service.UpdateCoupon(&payload, couponId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- coupon
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case coupon := <-couponChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"coupon":  coupon,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: delete a coupon
+func (h *CouponHandlerStruct) DeleteCouponHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	couponId := ctx.Param("couponId")
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		coupon, err := h.service.DeleteCoupon(couponId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- coupon
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case coupon := <-couponChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"coupon":  coupon,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type CurrencyHandlerStruct struct {
+	service services.CurrencyService
+}
+
+func NewCurrencyHandler(service services.CurrencyService) *CurrencyHandlerStruct {
+	return &CurrencyHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: create or replace an exchange rate
+func (h *CurrencyHandlerStruct) SetRateHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ExchangeRatePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	rateChan := make(chan *model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rate, err := h.service.SetRate(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- rate
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rate := <-rateChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rate":    rate,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *CurrencyHandlerStruct) GetRatesHandler(ctx *gin.Context) {
+	ratesChan := make(chan *[]model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rates, err := h.service.GetRates()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		ratesChan <- rates
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rates := <-ratesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rates":   rates,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: remove the rate between two currencies
+func (h *CurrencyHandlerStruct) DeleteRateHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	from, to := ctx.Param("from"), ctx.Param("to")
+	rateChan := make(chan *model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rate, err := h.service.DeleteRate(from, to)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- rate
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rate := <-rateChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rate":    rate,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maps a service error to the HTTP status that should be sent back
+func errorStatus(err error) int {
+	var errMsg model.ErrMsg
+	if errors.As(err, &errMsg) && errMsg.Code != 0 {
+		return errMsg.Code
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// currency asked for by the client, the X-Currency header wins over ?currency=
+func requestedCurrency(ctx *gin.Context) string {
+	if currency := ctx.GetHeader("X-Currency"); currency != "" {
+		return currency
+	}
+	return ctx.Query("currency")
+}
+
+const guestCartCookie = "guestCart_golang"
+
+// id of the shopper's guest cart, empty without a cookie or when its
+// signature doesn't match
+func guestCartID(ctx *gin.Context) string {
+	cookie, err := ctx.Cookie(guestCartCookie)
+	if err != nil || cookie == "" {
+		return ""
+	}
+	id, ok := utils.VerifySignedValue(cookie)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// GuestCartCookie is where the guest cart cookie is sent
+type GuestCartCookie struct {
+	Domain string
+	// only sent over HTTPS
+	Secure bool
+}
+
+// keeps the guest cart cookie alive as long as the cart itself
+func setGuestCartCookie(ctx *gin.Context, cookie GuestCartCookie, cart *model.GuestCart) {
+	ctx.SetCookie(
+		guestCartCookie,
+		utils.SignValue(cart.ID.Hex()),
+		int(time.Until(cart.ExpiresAt).Seconds()),
+		"/",
+		cookie.Domain,
+		cookie.Secure,
+		true,
+	)
+}
+
+func clearGuestCartCookie(ctx *gin.Context, cookie GuestCartCookie) {
+	ctx.SetCookie(guestCartCookie, "", -1, "/", cookie.Domain, cookie.Secure, true)
+}
+
+// streamExport sends what export emits as a CSV or JSON Lines download.
+// Nothing is sent before the first row, so an export that fails up front,
+// e.g. on a bad filter, is answered with its error. One that fails halfway
+// has its connection cut, the client then sees a broken download rather than
+// a short file that looks complete.
+func streamExport[T any](ctx *gin.Context, name, format string, header []string, csvRow func(T) []string, export func(emit func(T) error) error) {
+	started := false
+	var w *csv.Writer
+	var enc *json.Encoder
+	start := func() {
+		started = true
+		filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if format == "csv" {
+			ctx.Header("Content-Type", "text/csv; charset=utf-8")
+			ctx.Status(http.StatusOK)
+			w = csv.NewWriter(ctx.Writer)
+			w.Write(header)
+		} else {
+			ctx.Header("Content-Type", "application/x-ndjson")
+			ctx.Status(http.StatusOK)
+			enc = json.NewEncoder(ctx.Writer)
+		}
+	}
+
+	written := 0
+	emit := func(row T) error {
+		if !started {
+			start()
+		}
+		if w != nil {
+			if err := w.Write(csvRow(row)); err != nil {
+				return err
+			}
+		} else if err := enc.Encode(row); err != nil {
+			return err
+		}
+		written++
+		if written%100 == 0 {
+			if w != nil {
+				w.Flush()
+				if err := w.Error(); err != nil {
+					return err
+				}
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	}
+
+	err := export(emit)
+	if err != nil && !started {
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		fmt.Println(name, "export stopped:", err)
+		abortStream(ctx, format)
+		return
+	}
+	if !started {
+		start()
+	}
+	if w != nil {
+		w.Flush()
+	}
+}
+
+// abortStream cuts the connection of a download that already started
+func abortStream(ctx *gin.Context, format string) {
+	if conn, _, err := ctx.Writer.Hijack(); err == nil {
+		conn.Close()
+		return
+	}
+	// HTTP/2 streams can't be taken over, the file ends with a marker instead
+	if format == "csv" {
+		ctx.Writer.WriteString("\n# EXPORT FAILED, this file is incomplete\n")
+	} else {
+		ctx.Writer.WriteString(`{"error":"export failed, this file is incomplete"}` + "\n")
+	}
+}
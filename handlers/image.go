@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ImageHandlerStruct struct {
+	service services.ImageService
+	// largest accepted file in bytes
+	maxBytes int64
+	// most files in one upload
+	maxFiles int
+}
+
+func NewImageHandler(service services.ImageService, maxBytes int64, maxFiles int) *ImageHandlerStruct {
+	return &ImageHandlerStruct{
+		service:  service,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles,
+	}
+}
+
+// upload images of a product as multipart form files named "images", with an
+// optional "alt" text per file in the same order
+func (h *ImageHandlerStruct) UploadProductImagesHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	// room for every file at the limit plus the rest of the form
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxBytes*int64(h.maxFiles)+1<<20)
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("upload at most %d images of %d bytes each", h.maxFiles, h.maxBytes),
+			})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer form.RemoveAll()
+
+	files := form.File["images"]
+	alts := form.Value["alt"]
+	uploads := []request.ImageUpload{}
+	for n, file := range files {
+		if file.Size > h.maxBytes {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("%s is larger than %d bytes", file.Filename, h.maxBytes),
+			})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		upload := request.ImageUpload{Data: data}
+		if n < len(alts) {
+			upload.Alt = alts[n]
+		}
+		uploads = append(uploads, upload)
+	}
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		product, err := h.service.UploadProductImages(ctx.Param("productId"), uploads)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- product
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case product := <-productChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"product": product,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ImageHandlerStruct) ReorderProductImagesHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ReorderImagesPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		product, err := h.service.ReorderProductImages(ctx.Param("productId"), &payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- product
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case product := <-productChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"product": product,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ImageHandlerStruct) DeleteProductImageHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		product, err := h.service.DeleteProductImage(ctx.Param("productId"), ctx.Param("imageId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- product
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case product := <-productChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"product": product,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// serve a stored product image, the keys never change so it may be cached
+// for good
+func (h *ImageHandlerStruct) GetImageHandler(ctx *gin.Context) {
+	file, contentType, err := h.service.OpenImage(ctx.Param("key"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Status(http.StatusOK)
+	if _, err := io.Copy(ctx.Writer, file); err != nil {
+		fmt.Println("image download interrupted:", err)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type InvoiceHandlerStruct struct {
+	service services.InvoiceService
+}
+
+func NewInvoiceHandler(service services.InvoiceService) *InvoiceHandlerStruct {
+	return &InvoiceHandlerStruct{
+		service: service,
+	}
+}
+
+func (h *InvoiceHandlerStruct) GetOrderInvoicesHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	isAdmin := ctx.GetBool("isAdmin")
+	orderId := ctx.Param("orderId")
+
+	invoicesChan := make(chan *[]model.Invoice, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		invoices, err := h.service.GetOrderInvoices(orderId, userId, isAdmin)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		invoicesChan <- invoices
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case invoices := <-invoicesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"invoices": invoices,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: issue the invoice and credit notes of an order that failed when
+// they were due
+func (h *InvoiceHandlerStruct) IssueMissingDocumentsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	invoicesChan := make(chan *[]model.Invoice, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		invoices, err := h.service.IssueMissingDocuments(ctx.Param("orderId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		invoicesChan <- invoices
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case invoices := <-invoicesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"invoices": invoices,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// download the PDF of an invoice or credit note
+func (h *InvoiceHandlerStruct) DownloadInvoiceHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	isAdmin := ctx.GetBool("isAdmin")
+	invoiceId := ctx.Param("invoiceId")
+
+	invoice, file, err := h.service.OpenInvoice(invoiceId, userId, isAdmin)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	ctx.Header("Content-Type", "application/pdf")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoice.Number+".pdf"))
+	ctx.Status(http.StatusOK)
+	if _, err := io.Copy(ctx.Writer, file); err != nil {
+		fmt.Println("invoice download interrupted:", err)
+	}
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/souvikjs01/go-ecommerce/model"
 	"github.com/souvikjs01/go-ecommerce/request"
 	"github.com/souvikjs01/go-ecommerce/services"
+	"github.com/souvikjs01/go-ecommerce/utils"
 )
 
 type OrderHandlerStruct struct {
@@ -33,6 +37,9 @@ func (h *OrderHandlerStruct) CreateOrderHandler(ctx *gin.Context) {
 		})
 		return
 	}
+	if order.Currency == "" {
+		order.Currency = requestedCurrency(ctx)
+	}
 
 	go func() {
 		new_order, err := h.services.CreateOrder(&order, userId)
@@ -58,7 +65,7 @@ func (h *OrderHandlerStruct) CreateOrderHandler(ctx *gin.Context) {
 			})
 			return
 		case err := <-errChan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"error":   err.Error(),
 				"success": false,
 			})
@@ -104,36 +111,213 @@ func (h *OrderHandlerStruct) GetUserOrdersHandler(ctx *gin.Context) {
 	}
 }
 
-// func (h *OrderHandlerStruct) GetOrdersHandler(ctx *gin.Context) {
-// 	orderChan := make(chan *[]model.Order, 32)
-// 	errChan := make(chan error, 32)
+func (h *OrderHandlerStruct) GetOrderByNumberHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	isAdmin := ctx.GetBool("isAdmin")
+	orderNumber := ctx.Param("orderNumber")
 
-// 	go func() {
-// 		orders, err := h.services.GetAllOrders()
-// 		if err != nil {
-// 			errChan <- err
-// 			return
-// 		}
-// 		orderChan <- orders
-// 	}()
+	orderChan := make(chan *model.Order, 32)
+	errChan := make(chan error, 32)
 
-// 	for {
-// 		select {
-// 		case orders := <-orderChan:
-// 			ctx.JSON(http.StatusOK, gin.H{
-// 				"success": true,
-// 				"orders":  orders,
-// 			})
-// 			return
-// 		case err := <-errChan:
-// 			ctx.JSON(http.StatusInternalServerError, gin.H{
-// 				"success": false,
-// 				"error":   err.Error(),
-// 			})
-// 			return
-// 		}
-// 	}
-// }
+	go func() {
+		order, err := h.services.GetOrderByNumber(orderNumber, userId, isAdmin)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		orderChan <- order
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx.JSON(http.StatusRequestTimeout, gin.H{
+				"success": false,
+				"error":   "request time out",
+			})
+			return
+		case order := <-orderChan:
+			ctx.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"order":   order,
+			})
+			return
+		case err := <-errChan:
+			ctx.JSON(errorStatus(err), gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+}
+
+// admin: payment received, this also issues the invoice
+func (h *OrderHandlerStruct) MarkOrderPaidHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	orderId := ctx.Param("orderId")
+
+	type paidResult struct {
+		order   *model.Order
+		invoice *model.Invoice
+	}
+	resultChan := make(chan paidResult, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		order, invoice, err := h.services.MarkOrderPaid(orderId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- paidResult{order: order, invoice: invoice}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx.JSON(http.StatusRequestTimeout, gin.H{
+				"success": false,
+				"error":   "request time out",
+			})
+			return
+		case result := <-resultChan:
+			ctx.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"order":   result.order,
+				"invoice": result.invoice,
+			})
+			return
+		case err := <-errChan:
+			ctx.JSON(errorStatus(err), gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+}
+
+// admin: list all orders with filters, sorting and pagination
+func (h *OrderHandlerStruct) GetOrdersHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var filter request.OrderFilterQuery
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	orderChan := make(chan *model.OrderPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		orders, err := h.services.GetAllOrders(&filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		orderChan <- orders
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx.JSON(http.StatusRequestTimeout, gin.H{
+				"success": false,
+				"error":   "request time out",
+			})
+			return
+		case page := <-orderChan:
+			ctx.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"orders":  page.Orders,
+				"page":    page.Page,
+				"limit":   page.Limit,
+				"total":   page.Total,
+			})
+			return
+		case err := <-errChan:
+			ctx.JSON(errorStatus(err), gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+}
+
+// admin: stream the filtered orders as CSV (default) or JSON Lines (?format=jsonl)
+func (h *OrderHandlerStruct) ExportOrdersHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var filter request.OrderFilterQuery
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "format must be csv or jsonl",
+		})
+		return
+	}
+
+	streamExport(ctx, "orders", format, orderCSVHeader, orderCSVRow, func(emit func(*model.Order) error) error {
+		return h.services.ExportOrders(&filter, emit)
+	})
+}
+
+var orderCSVHeader = []string{"id", "order_number", "user_id", "status", "amount", "currency", "refunded_amount", "items", "address", "created_at", "updated_at"}
+
+func orderCSVRow(order *model.Order) []string {
+	items := make([]string, 0, len(order.Products))
+	for _, p := range order.Products {
+		items = append(items, fmt.Sprintf("%s x%d", p.ProductID.Hex(), p.Quantity))
+	}
+	// addresses and the like are typed by customers, the file goes to finance
+	return utils.CSVSafe([]string{
+		order.ID.Hex(),
+		order.OrderNumber,
+		order.UserId.Hex(),
+		order.Status,
+		order.Amount.Decimal(),
+		order.Amount.Currency,
+		order.RefundedAmount.Decimal(),
+		strings.Join(items, "; "),
+		order.Address,
+		order.CreatedAt.Format(time.RFC3339),
+		order.UpdatedAt.Format(time.RFC3339),
+	})
+}
 
 // func (h *OrderHandlerStruct) DeleteOrderHandler(ctx *gin.Context) {
 // 	orderChan := make(chan *model.Order, 32)
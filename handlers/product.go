@@ -10,12 +10,14 @@ import (
 )
 
 type ProductHandlerStruct struct {
-	service services.ProductService
+	service    services.ProductService
+	currencies services.CurrencyService
 }
 
-func NewProductHandler(service services.ProductService) *ProductHandlerStruct {
+func NewProductHandler(service services.ProductService, currencies services.CurrencyService) *ProductHandlerStruct {
 	return &ProductHandlerStruct{
-		service: service,
+		service:    service,
+		currencies: currencies,
 	}
 }
 
@@ -76,7 +78,7 @@ func (h *ProductHandlerStruct) CreateProductHandler(ctx *gin.Context) {
 			)
 			return
 		case err := <-err_chan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"error":   err.Error(),
 				"success": false,
 			})
@@ -121,6 +123,7 @@ func (h *ProductHandlerStruct) UpdateProductHandler(ctx *gin.Context) {
 		prod, err := h.service.UpdateProductsDetails(&prod_id, &update_product)
 		if err != nil {
 			err_chan <- err
+			return
 		}
 		prod_chan <- prod
 	}()
@@ -134,7 +137,7 @@ func (h *ProductHandlerStruct) UpdateProductHandler(ctx *gin.Context) {
 			})
 			return
 		case err := <-err_chan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"error":   err.Error(),
 				"success": false,
 			})
@@ -145,6 +148,7 @@ func (h *ProductHandlerStruct) UpdateProductHandler(ctx *gin.Context) {
 
 func (h *ProductHandlerStruct) GetProductDetailsByID(ctx *gin.Context) {
 	productId := ctx.Param("productId")
+	currency := requestedCurrency(ctx)
 
 	prodChan := make(chan *model.Product, 32)
 	errChan := make(chan error, 32)
@@ -156,7 +160,12 @@ func (h *ProductHandlerStruct) GetProductDetailsByID(ctx *gin.Context) {
 			errChan <- err
 			return
 		}
-		prodChan <- prod
+		priced := []model.Product{*prod}
+		if err := h.currencies.PriceProducts(priced, currency); err != nil {
+			errChan <- err
+			return
+		}
+		prodChan <- &priced[0]
 	}()
 
 	for {
@@ -174,7 +183,7 @@ func (h *ProductHandlerStruct) GetProductDetailsByID(ctx *gin.Context) {
 			})
 			return
 		case err := <-errChan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"error":   err.Error(),
 				"success": false,
 			})
@@ -251,12 +260,18 @@ func (h *ProductHandlerStruct) LatestProducts(ctx *gin.Context) {
 	productsChan := make(chan *[]model.Product, 32)
 	errChan := make(chan error, 32)
 
+	currency := requestedCurrency(ctx)
+
 	go func() {
 		products, err := h.service.GetLatestProducts()
 		if err != nil {
 			errChan <- err
 			return
 		}
+		if err := h.currencies.PriceProducts(*products, currency); err != nil {
+			errChan <- err
+			return
+		}
 		productsChan <- products
 	}()
 
@@ -276,7 +291,7 @@ func (h *ProductHandlerStruct) LatestProducts(ctx *gin.Context) {
 			return
 		case err := <-errChan:
 			ctx.JSON(
-				http.StatusInternalServerError,
+				errorStatus(err),
 				gin.H{
 					"success": false,
 					"error":   err.Error(),
@@ -293,12 +308,18 @@ func (h *ProductHandlerStruct) AllProducts(ctx *gin.Context) {
 	productsChan := make(chan *[]model.Product, 32)
 	errChan := make(chan error, 32)
 
+	currency := requestedCurrency(ctx)
+
 	go func() {
 		products, err := h.service.GetAllProduct()
 		if err != nil {
 			errChan <- err
 			return
 		}
+		if err := h.currencies.PriceProducts(*products, currency); err != nil {
+			errChan <- err
+			return
+		}
 		productsChan <- products
 	}()
 
@@ -319,7 +340,7 @@ func (h *ProductHandlerStruct) AllProducts(ctx *gin.Context) {
 			return
 		case err := <-errChan:
 			ctx.JSON(
-				http.StatusInternalServerError,
+				errorStatus(err),
 				gin.H{
 					"success": false,
 					"error":   err.Error(),
@@ -336,6 +357,8 @@ func (h *ProductHandlerStruct) ProductByQuery(ctx *gin.Context) {
 	prodChan := make(chan *[]model.Product, 32)
 	errChan := make(chan error, 32)
 
+	currency := requestedCurrency(ctx)
+
 	go func() {
 		prods, err := h.service.GetProductsByQuery(query)
 
@@ -343,6 +366,10 @@ func (h *ProductHandlerStruct) ProductByQuery(ctx *gin.Context) {
 			errChan <- err
 			return
 		}
+		if err := h.currencies.PriceProducts(*prods, currency); err != nil {
+			errChan <- err
+			return
+		}
 		prodChan <- prods
 	}()
 
@@ -361,7 +388,7 @@ func (h *ProductHandlerStruct) ProductByQuery(ctx *gin.Context) {
 			})
 			return
 		case err := <-errChan:
-			ctx.JSON(http.StatusInternalServerError, gin.H{
+			ctx.JSON(errorStatus(err), gin.H{
 				"error":   err.Error(),
 				"success": false,
 			})
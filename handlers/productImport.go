@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ProductImportHandlerStruct struct {
+	service  services.ProductImportService
+	products services.ProductService
+	// largest accepted import file in bytes
+	maxBytes int64
+}
+
+func NewProductImportHandler(service services.ProductImportService, products services.ProductService, maxBytes int64) *ProductImportHandlerStruct {
+	return &ProductImportHandlerStruct{
+		service:  service,
+		products: products,
+		maxBytes: maxBytes,
+	}
+}
+
+// admin: start importing the CSV or JSON Lines "file" of a multipart form,
+// ?format=csv|jsonl&dry_run=true. Poll the returned import for progress.
+func (h *ProductImportHandlerStruct) ImportProductsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var query request.ProductImportQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxBytes+1<<20)
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("the file can be at most %d bytes", h.maxBytes),
+			})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if file.Size > h.maxBytes {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("the file can be at most %d bytes", h.maxBytes),
+		})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	importChan := make(chan *model.ProductImport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		job, err := h.service.StartProductImport(ctx.GetString("userId"), file.Filename, query.Format, query.DryRun, data)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		importChan <- job
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case job := <-importChan:
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"import":  job,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: progress and row errors of an import
+func (h *ProductImportHandlerStruct) GetProductImportHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	importChan := make(chan *model.ProductImport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		job, err := h.service.GetProductImport(ctx.Param("importId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		importChan <- job
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case job := <-importChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"import":  job,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: recent imports, ?page=&limit=
+func (h *ProductImportHandlerStruct) GetProductImportsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var query request.ProductImportsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	pageChan := make(chan *model.ProductImportPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		page, err := h.service.GetProductImports(&query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- page
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case page := <-pageChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"imports": page.Imports,
+			"page":    page.Page,
+			"limit":   page.Limit,
+			"total":   page.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: stream the catalogue as CSV (default) or JSON Lines (?format=jsonl),
+// in the shape the import takes
+func (h *ProductImportHandlerStruct) ExportProductsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "format must be csv or jsonl",
+		})
+		return
+	}
+
+	streamExport(ctx, "products", format, services.ProductCSVHeader, services.ProductCSVRow, func(emit func(*model.Product) error) error {
+		return h.products.ExportProducts(emit)
+	})
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type PromotionHandlerStruct struct {
+	service services.PromotionService
+}
+
+func NewPromotionHandler(service services.PromotionService) *PromotionHandlerStruct {
+	return &PromotionHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: create an automatic promotion
+func (h *PromotionHandlerStruct) CreatePromotionHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.PromotionPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		promotion, err := h.service.CreatePromotion(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- promotion
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case promotion := <-promotionChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success":   true,
+			"promotion": promotion,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: all promotions, in the order they are evaluated
+func (h *PromotionHandlerStruct) GetPromotionsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	promotionsChan := make(chan *[]model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		promotions, err := h.service.GetPromotions()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionsChan <- promotions
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case promotions := <-promotionsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"promotions": promotions,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: change the priority or schedule of a promotion
+func (h *PromotionHandlerStruct) UpdatePromotionHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.UpdatePromotionPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	promotionId := ctx.Param("promotionId")
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		promotion, err := h.service.UpdatePromotion(&payload, promotionId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- promotion
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case promotion := <-promotionChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"promotion": promotion,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: delete a promotion
+func (h *PromotionHandlerStruct) DeletePromotionHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	promotionId := ctx.Param("promotionId")
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		promotion, err := h.service.DeletePromotion(promotionId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- promotion
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case promotion := <-promotionChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"promotion": promotion,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: preview the promotions for a cart without saving anything
+func (h *PromotionHandlerStruct) DryRunHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.DryRunPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if payload.Currency == "" {
+		payload.Currency = requestedCurrency(ctx)
+	}
+
+	resultChan := make(chan *model.PromotionResult, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		result, err := h.service.DryRun(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case result := <-resultChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"result":  result,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
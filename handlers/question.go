@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type QuestionHandlerStruct struct {
+	service services.QuestionService
+}
+
+func NewQuestionHandler(service services.QuestionService) *QuestionHandlerStruct {
+	return &QuestionHandlerStruct{
+		service: service,
+	}
+}
+
+// ask a question about a product
+func (h *QuestionHandlerStruct) AskQuestionHandler(ctx *gin.Context) {
+	var payload request.QuestionPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		question, err := h.service.AskQuestion(&payload, ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questionChan <- question
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case question := <-questionChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success":  true,
+			"question": question,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// published questions of a product with their answers, ?sort=newest|oldest|most_answered&page=&limit=
+func (h *QuestionHandlerStruct) GetProductQuestionsHandler(ctx *gin.Context) {
+	var query request.QuestionQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	pageChan := make(chan *model.QuestionPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		page, err := h.service.GetProductQuestions(ctx.Param("productId"), &query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- page
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case page := <-pageChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"questions": page.Questions,
+			"page":      page.Page,
+			"limit":     page.Limit,
+			"total":     page.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *QuestionHandlerStruct) GetMyQuestionsHandler(ctx *gin.Context) {
+	questionsChan := make(chan *[]model.Question, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		questions, err := h.service.GetMyQuestions(ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questionsChan <- questions
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case questions := <-questionsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"questions": questions,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// delete an own question, admins can delete any
+func (h *QuestionHandlerStruct) DeleteQuestionHandler(ctx *gin.Context) {
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		question, err := h.service.DeleteQuestion(ctx.GetString("userId"), ctx.Param("questionId"), ctx.GetBool("isAdmin"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questionChan <- question
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case question := <-questionChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"question": question,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// answer a question as an admin, the seller or a customer who received the product
+func (h *QuestionHandlerStruct) AnswerQuestionHandler(ctx *gin.Context) {
+	var payload request.AnswerPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		answer, err := h.service.AnswerQuestion(&payload, ctx.GetString("userId"), ctx.Param("questionId"), ctx.GetBool("isAdmin"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case answer := <-answerChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"answer":  answer,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// delete an own answer, admins can delete any
+func (h *QuestionHandlerStruct) DeleteAnswerHandler(ctx *gin.Context) {
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		answer, err := h.service.DeleteAnswer(ctx.GetString("userId"), ctx.Param("answerId"), ctx.GetBool("isAdmin"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case answer := <-answerChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"answer":  answer,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *QuestionHandlerStruct) MarkHelpfulHandler(ctx *gin.Context) {
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		answer, err := h.service.MarkHelpful(ctx.GetString("userId"), ctx.Param("answerId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case answer := <-answerChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"answer":  answer,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *QuestionHandlerStruct) UnmarkHelpfulHandler(ctx *gin.Context) {
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		answer, err := h.service.UnmarkHelpful(ctx.GetString("userId"), ctx.Param("answerId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case answer := <-answerChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"answer":  answer,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// questions waiting for a moderator, ?status=pending|approved|rejected&page=&limit=
+func (h *QuestionHandlerStruct) GetQuestionQueueHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var query request.QAQueueQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	pageChan := make(chan *model.QuestionPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		page, err := h.service.GetQuestionQueue(&query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- page
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case page := <-pageChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"questions": page.Questions,
+			"page":      page.Page,
+			"limit":     page.Limit,
+			"total":     page.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// answers waiting for a moderator, ?status=pending|approved|rejected&page=&limit=
+func (h *QuestionHandlerStruct) GetAnswerQueueHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var query request.QAQueueQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	pageChan := make(chan *model.AnswerPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		page, err := h.service.GetAnswerQueue(&query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- page
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case page := <-pageChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"answers": page.Answers,
+			"page":    page.Page,
+			"limit":   page.Limit,
+			"total":   page.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// approve or reject a question
+func (h *QuestionHandlerStruct) ModerateQuestionHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var payload request.ModeratePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		question, err := h.service.ModerateQuestion(&payload, ctx.GetString("userId"), ctx.Param("questionId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questionChan <- question
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case question := <-questionChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"question": question,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// approve or reject an answer
+func (h *QuestionHandlerStruct) ModerateAnswerHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var payload request.ModeratePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		answer, err := h.service.ModerateAnswer(&payload, ctx.GetString("userId"), ctx.Param("answerId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case answer := <-answerChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"answer":  answer,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ReturnHandlerStruct struct {
+	service services.ReturnService
+}
+
+func NewReturnHandler(service services.ReturnService) *ReturnHandlerStruct {
+	return &ReturnHandlerStruct{
+		service: service,
+	}
+}
+
+func (h *ReturnHandlerStruct) CreateReturnHandler(ctx *gin.Context) {
+	var payload request.CreateReturnPayload
+	userId := ctx.GetString("userId")
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	returnChan := make(chan *model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		ret, err := h.service.CreateReturn(&payload, userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		returnChan <- ret
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case ret := <-returnChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"return":  ret,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReturnHandlerStruct) GetMyReturnsHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+
+	returnsChan := make(chan *[]model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		returns, err := h.service.GetUserReturns(userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		returnsChan <- returns
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case returns := <-returnsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"returns": returns,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReturnHandlerStruct) GetOrderReturnHistoryHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	isAdmin := ctx.GetBool("isAdmin")
+	orderId := ctx.Param("orderId")
+
+	historyChan := make(chan *model.OrderReturnHistory, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		history, err := h.service.GetOrderReturnHistory(orderId, userId, isAdmin)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		historyChan <- history
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case history := <-historyChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"history": history,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: list returns, optionally filtered by ?status=
+func (h *ReturnHandlerStruct) GetAllReturnsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	status := ctx.Query("status")
+	returnsChan := make(chan *[]model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		returns, err := h.service.GetAllReturns(status)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		returnsChan <- returns
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case returns := <-returnsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"returns": returns,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReturnHandlerStruct) ApproveReturnHandler(ctx *gin.Context) {
+	h.reviewReturn(ctx, h.service.ApproveReturn)
+}
+
+func (h *ReturnHandlerStruct) RejectReturnHandler(ctx *gin.Context) {
+	h.reviewReturn(ctx, h.service.RejectReturn)
+}
+
+func (h *ReturnHandlerStruct) reviewReturn(ctx *gin.Context, review func(string, *request.ReviewReturnPayload) (*model.ReturnRequest, error)) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ReviewReturnPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	returnId := ctx.Param("returnId")
+	returnChan := make(chan *model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		ret, err := review(returnId, &payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		returnChan <- ret
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case ret := <-returnChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"return":  ret,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: items are back in the warehouse, refund the customer
+func (h *ReturnHandlerStruct) ReceiveReturnHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ReceiveReturnPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	returnId := ctx.Param("returnId")
+	returnChan := make(chan *model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		ret, err := h.service.ReceiveReturn(returnId, &payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		returnChan <- ret
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case ret := <-returnChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"return":  ret,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
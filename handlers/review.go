@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ReviewHandlerStruct struct {
+	service services.ReviewService
+}
+
+func NewReviewHandler(service services.ReviewService) *ReviewHandlerStruct {
+	return &ReviewHandlerStruct{
+		service: service,
+	}
+}
+
+// review a product, once per customer
+func (h *ReviewHandlerStruct) CreateReviewHandler(ctx *gin.Context) {
+	var payload request.ReviewPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		review, err := h.service.CreateReview(&payload, ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviewChan <- review
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case review := <-reviewChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"review":  review,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReviewHandlerStruct) UpdateReviewHandler(ctx *gin.Context) {
+	var payload request.UpdateReviewPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		review, err := h.service.UpdateReview(&payload, ctx.GetString("userId"), ctx.Param("reviewId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviewChan <- review
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case review := <-reviewChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"review":  review,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// delete an own review, admins can delete any
+func (h *ReviewHandlerStruct) DeleteReviewHandler(ctx *gin.Context) {
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		review, err := h.service.DeleteReview(ctx.GetString("userId"), ctx.Param("reviewId"), ctx.GetBool("isAdmin"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviewChan <- review
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case review := <-reviewChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"review":  review,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReviewHandlerStruct) GetMyReviewsHandler(ctx *gin.Context) {
+	reviewsChan := make(chan *[]model.Review, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		reviews, err := h.service.GetMyReviews(ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviewsChan <- reviews
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case reviews := <-reviewsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"reviews": reviews,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// reviews of a product, ?sort=newest|oldest|highest|lowest&rating=&verified=&page=&limit=
+func (h *ReviewHandlerStruct) GetProductReviewsHandler(ctx *gin.Context) {
+	var query request.ReviewQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	pageChan := make(chan *model.ReviewPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		page, err := h.service.GetProductReviews(ctx.Param("productId"), &query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- page
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case page := <-pageChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"reviews": page.Reviews,
+			"rating":  page.Rating,
+			"page":    page.Page,
+			"limit":   page.Limit,
+			"total":   page.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// report a published review of another customer
+func (h *ReviewHandlerStruct) ReportReviewHandler(ctx *gin.Context) {
+	var payload request.ReportReviewPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	reportChan := make(chan *model.ReviewReport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		report, err := h.service.ReportReview(&payload, ctx.GetString("userId"), ctx.Param("reviewId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reportChan <- report
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case report := <-reportChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"report":  report,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// reviews waiting for a moderator, ?status=pending|approved|rejected&min_reports=&page=&limit=
+func (h *ReviewHandlerStruct) GetModerationQueueHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var query request.ModerationQueueQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	queueChan := make(chan *model.ModerationQueue, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		queue, err := h.service.GetModerationQueue(&query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		queueChan <- queue
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case queue := <-queueChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"reviews": queue.Reviews,
+			"page":    queue.Page,
+			"limit":   queue.Limit,
+			"total":   queue.Total,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// approve or reject a review
+func (h *ReviewHandlerStruct) ModerateReviewHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+	var payload request.ModeratePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		review, err := h.service.ModerateReview(&payload, ctx.GetString("userId"), ctx.Param("reviewId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviewChan <- review
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case review := <-reviewChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"review":  review,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ReviewHandlerStruct) GetReviewReportsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	reportsChan := make(chan *[]model.ReviewReport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		reports, err := h.service.GetReviewReports(ctx.Param("reviewId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reportsChan <- reports
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case reports := <-reportsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"reports": reports,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// moderation decisions, ?review_id= for those of one review
+func (h *ReviewHandlerStruct) GetModerationLogHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	decisionsChan := make(chan *[]model.ModerationDecision, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		decisions, err := h.service.GetModerationLog(ctx.Query("review_id"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		decisionsChan <- decisions
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case decisions := <-decisionsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"decisions": decisions,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ShipmentHandlerStruct struct {
+	service services.ShipmentService
+}
+
+func NewShipmentHandler(service services.ShipmentService) *ShipmentHandlerStruct {
+	return &ShipmentHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: ship some or all lines of an order
+func (h *ShipmentHandlerStruct) CreateShipmentHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.CreateShipmentPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	shipmentChan := make(chan *model.Shipment, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		shipment, err := h.service.CreateShipment(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		shipmentChan <- shipment
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case shipment := <-shipmentChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success":  true,
+			"shipment": shipment,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: the carrier delivered the shipment
+func (h *ShipmentHandlerStruct) MarkDeliveredHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.DeliverShipmentPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	shipmentId := ctx.Param("shipmentId")
+	shipmentChan := make(chan *model.Shipment, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		shipment, err := h.service.MarkDelivered(shipmentId, &payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		shipmentChan <- shipment
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case shipment := <-shipmentChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"shipment": shipment,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *ShipmentHandlerStruct) GetOrderShipmentsHandler(ctx *gin.Context) {
+	userId := ctx.GetString("userId")
+	isAdmin := ctx.GetBool("isAdmin")
+	orderId := ctx.Param("orderId")
+
+	shipmentsChan := make(chan *[]model.Shipment, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		shipments, err := h.service.GetOrderShipments(orderId, userId, isAdmin)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		shipmentsChan <- shipments
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case shipments := <-shipmentsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"shipments": shipments,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type ShippingHandlerStruct struct {
+	service services.ShippingService
+}
+
+func NewShippingHandler(service services.ShippingService) *ShippingHandlerStruct {
+	return &ShippingHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: create a shipping zone
+func (h *ShippingHandlerStruct) CreateZoneHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ShippingZonePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		zone, err := h.service.CreateZone(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zoneChan <- zone
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case zone := <-zoneChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"zone":    zone,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: all shipping zones
+func (h *ShippingHandlerStruct) GetZonesHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	zonesChan := make(chan *[]model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		zones, err := h.service.GetZones()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zonesChan <- zones
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case zones := <-zonesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"zones":   zones,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: change the name or destinations of a zone
+func (h *ShippingHandlerStruct) UpdateZoneHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.UpdateShippingZonePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	zoneId := ctx.Param("zoneId")
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		zone, err := h.service.UpdateZone(&payload, zoneId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zoneChan <- zone
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case zone := <-zoneChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"zone":    zone,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: delete a zone and its methods
+func (h *ShippingHandlerStruct) DeleteZoneHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	zoneId := ctx.Param("zoneId")
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		zone, err := h.service.DeleteZone(zoneId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zoneChan <- zone
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case zone := <-zoneChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"zone":    zone,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: create a shipping method for a zone
+func (h *ShippingHandlerStruct) CreateMethodHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.ShippingMethodPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		method, err := h.service.CreateMethod(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- method
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case method := <-methodChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"method":  method,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: all shipping methods, or those of ?zone_id=
+func (h *ShippingHandlerStruct) GetMethodsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	zoneId := ctx.Query("zone_id")
+	methodsChan := make(chan *[]model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		methods, err := h.service.GetMethods(zoneId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodsChan <- methods
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case methods := <-methodsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"methods": methods,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: change the rates or availability of a method
+func (h *ShippingHandlerStruct) UpdateMethodHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.UpdateShippingMethodPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	methodId := ctx.Param("methodId")
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		method, err := h.service.UpdateMethod(&payload, methodId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- method
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case method := <-methodChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"method":  method,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: delete a shipping method
+func (h *ShippingHandlerStruct) DeleteMethodHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	methodId := ctx.Param("methodId")
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		method, err := h.service.DeleteMethod(methodId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- method
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case method := <-methodChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"method":  method,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+type TaxHandlerStruct struct {
+	service services.TaxService
+}
+
+func NewTaxHandler(service services.TaxService) *TaxHandlerStruct {
+	return &TaxHandlerStruct{
+		service: service,
+	}
+}
+
+// admin: create or replace the tax rate of a country or region
+func (h *TaxHandlerStruct) SetRateHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	var payload request.TaxRatePayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	rateChan := make(chan *model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rate, err := h.service.SetRate(&payload)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- rate
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rate := <-rateChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rate":    rate,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// tax rates, optionally of a single country (?country=)
+func (h *TaxHandlerStruct) GetRatesHandler(ctx *gin.Context) {
+	ratesChan := make(chan *[]model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rates, err := h.service.GetRates(ctx.Query("country"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		ratesChan <- rates
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rates := <-ratesChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rates":   rates,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: remove a tax rate
+func (h *TaxHandlerStruct) DeleteRateHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	rateId := ctx.Param("rateId")
+	rateChan := make(chan *model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		rate, err := h.service.DeleteRate(rateId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- rate
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case rate := <-rateChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"rate":    rate,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -81,7 +81,7 @@ func (h *UserHandlerStruct) UpdateUserProfile(ctx *gin.Context) {
 
 	if err != nil {
 		ctx.JSON(
-			http.StatusInternalServerError,
+			errorStatus(err),
 			gin.H{
 				"success": false,
 				"error":   err.Error(),
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/services"
+)
+
+// The saved for later routes reuse these handlers, they have no :wishlistId
+// so the service gets an empty id and works on the saved for later list.
+type WishlistHandlerStruct struct {
+	service services.WishlistService
+}
+
+func NewWishlistHandler(service services.WishlistService) *WishlistHandlerStruct {
+	return &WishlistHandlerStruct{
+		service: service,
+	}
+}
+
+// create a named wishlist
+func (h *WishlistHandlerStruct) CreateWishlistHandler(ctx *gin.Context) {
+	var payload request.WishlistPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.CreateWishlist(&payload, ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusCreated, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// the user's wishlists
+func (h *WishlistHandlerStruct) GetWishlistsHandler(ctx *gin.Context) {
+	wishlistsChan := make(chan *[]model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlists, err := h.service.GetWishlists(ctx.GetString("userId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistsChan <- wishlists
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlists := <-wishlistsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"wishlists": wishlists,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// a wishlist, or the saved for later list, with its products
+func (h *WishlistHandlerStruct) GetWishlistHandler(ctx *gin.Context) {
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.GetWishlist(ctx.GetString("userId"), ctx.Param("wishlistId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// a public wishlist by its share token, no login needed
+func (h *WishlistHandlerStruct) GetSharedWishlistHandler(ctx *gin.Context) {
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.GetSharedWishlist(ctx.Param("token"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// rename a wishlist or turn sharing on and off
+func (h *WishlistHandlerStruct) UpdateWishlistHandler(ctx *gin.Context) {
+	var payload request.UpdateWishlistPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.UpdateWishlist(&payload, ctx.GetString("userId"), ctx.Param("wishlistId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *WishlistHandlerStruct) DeleteWishlistHandler(ctx *gin.Context) {
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.DeleteWishlist(ctx.GetString("userId"), ctx.Param("wishlistId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *WishlistHandlerStruct) AddToWishlistHandler(ctx *gin.Context) {
+	var payload request.WishlistItemPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.AddToWishlist(&payload, ctx.GetString("userId"), ctx.Param("wishlistId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (h *WishlistHandlerStruct) RemoveFromWishlistHandler(ctx *gin.Context) {
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.RemoveFromWishlist(ctx.GetString("userId"), ctx.Param("wishlistId"), ctx.Param("productId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// move a cart line to the list
+func (h *WishlistHandlerStruct) MoveFromCartHandler(ctx *gin.Context) {
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		wishlist, err := h.service.MoveFromCart(ctx.GetString("userId"), ctx.Param("wishlistId"), ctx.Param("productId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case wishlist := <-wishlistChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"wishlist": wishlist,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// move a product from the list into the cart
+func (h *WishlistHandlerStruct) MoveToCartHandler(ctx *gin.Context) {
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		cart, err := h.service.MoveToCart(ctx.GetString("userId"), ctx.Param("wishlistId"), ctx.Param("productId"))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case cart := <-cartChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"cart":    cart,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// admin: how many lists each product is on, ?limit= defaults to 50
+func (h *WishlistHandlerStruct) ProductWishlistCountsHandler(ctx *gin.Context) {
+	if !ctx.GetBool("isAdmin") {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+
+	countsChan := make(chan *[]model.ProductWishlistCount, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		counts, err := h.service.GetProductWishlistCounts(limit)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		countsChan <- counts
+	}()
+
+	select {
+	case <-ctx.Done():
+		ctx.JSON(http.StatusRequestTimeout, gin.H{
+			"success": false,
+			"error":   "request time out",
+		})
+	case counts := <-countsChan:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"counts":  counts,
+		})
+	case err := <-errChan:
+		ctx.JSON(errorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+}
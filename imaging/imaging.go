@@ -0,0 +1,150 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sort"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// images with more pixels are refused before they are decoded, a small file
+// can still unpack into gigabytes
+const maxPixels = 50_000_000
+
+var (
+	ErrUnsupported = errors.New("unsupported image type, use JPEG, PNG, GIF or WebP")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+// file extensions of the accepted types
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// Image is a decoded upload with its resized derivatives
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Variants    []Variant
+}
+
+// Variant is the image scaled to a width, in the format of the original, or
+// PNG when that can't be written, and as WebP
+type Variant struct {
+	Width       int
+	Height      int
+	ContentType string
+	Ext         string
+	Data        []byte
+	WebP        []byte
+}
+
+// Sniff works out the type of an image from its content, whatever the client
+// claimed it to be
+func Sniff(data []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", "", ErrUnsupported
+	}
+	return contentType, ext, nil
+}
+
+// Process checks an upload and scales it down to each of the widths. Widths
+// at or above the width of the image are left out, an image narrower than all
+// of them gets one variant at its own size.
+func Process(data []byte, widths []int) (*Image, error) {
+	contentType, ext, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	bounds := src.Bounds()
+	result := &Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}
+
+	sizes := []int{}
+	for _, width := range widths {
+		if width > 0 && width < result.Width {
+			sizes = append(sizes, width)
+		}
+	}
+	if len(sizes) == 0 {
+		sizes = append(sizes, result.Width)
+	}
+	sort.Ints(sizes)
+
+	for _, width := range sizes {
+		height := result.Height * width / result.Width
+		if height < 1 {
+			height = 1
+		}
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, bounds, draw.Src, nil)
+
+		variant, err := encode(scaled, contentType)
+		if err != nil {
+			return nil, err
+		}
+		var webp bytes.Buffer
+		if err := nativewebp.Encode(&webp, scaled, nil); err != nil {
+			return nil, err
+		}
+		variant.WebP = webp.Bytes()
+		result.Variants = append(result.Variants, *variant)
+	}
+	return result, nil
+}
+
+// JPEGs stay JPEGs, everything else becomes PNG to keep transparency and
+// colours a GIF palette would lose
+func encode(img image.Image, contentType string) (*Variant, error) {
+	var buf bytes.Buffer
+	variant := &Variant{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	switch contentType {
+	case "image/jpeg":
+		variant.ContentType, variant.Ext = "image/jpeg", "jpg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+	default:
+		variant.ContentType, variant.Ext = "image/png", "png"
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	}
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
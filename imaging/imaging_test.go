@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+func encoded(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, testImage(width, height))
+	case "jpeg":
+		err = jpeg.Encode(&buf, testImage(width, height), nil)
+	case "gif":
+		err = gif.Encode(&buf, testImage(width, height), nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		ext         string
+		err         error
+	}{
+		{"png", encoded(t, "png", 4, 4), "image/png", "png", nil},
+		{"jpeg", encoded(t, "jpeg", 4, 4), "image/jpeg", "jpg", nil},
+		{"gif", encoded(t, "gif", 4, 4), "image/gif", "gif", nil},
+		{"webp", append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...), "image/webp", "webp", nil},
+		{"text", []byte("just some text"), "", "", ErrUnsupported},
+		{"pdf", []byte("%PDF-1.4\n"), "", "", ErrUnsupported},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", "", ErrUnsupported},
+		{"empty", []byte{}, "", "", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, ext, err := Sniff(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if contentType != tt.contentType || ext != tt.ext {
+				t.Errorf("got %q %q, want %q %q", contentType, ext, tt.contentType, tt.ext)
+			}
+		})
+	}
+}
+
+func TestProcessVariants(t *testing.T) {
+	type size struct{ width, height int }
+	tests := []struct {
+		name        string
+		data        []byte
+		widths      []int
+		contentType string
+		want        []size
+	}{
+		{"scaled down and sorted", encoded(t, "png", 600, 300), []int{1024, 480, 0, 160}, "image/png",
+			[]size{{160, 80}, {480, 240}}},
+		{"narrower than every width", encoded(t, "png", 100, 50), []int{160, 480}, "image/png",
+			[]size{{100, 50}}},
+		{"no widths", encoded(t, "png", 100, 50), nil, "image/png",
+			[]size{{100, 50}}},
+		{"width of the image is left out", encoded(t, "png", 160, 40), []int{80, 160}, "image/png",
+			[]size{{80, 20}}},
+		{"jpeg stays jpeg", encoded(t, "jpeg", 200, 100), []int{50}, "image/jpeg",
+			[]size{{50, 25}}},
+		{"gif becomes png", encoded(t, "gif", 200, 100), []int{50}, "image/png",
+			[]size{{50, 25}}},
+		{"height at least one pixel", encoded(t, "png", 400, 1), []int{10}, "image/png",
+			[]size{{10, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Process(tt.data, tt.widths)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if len(img.Variants) != len(tt.want) {
+				t.Fatalf("got %d variants, want %d", len(img.Variants), len(tt.want))
+			}
+			for i, v := range img.Variants {
+				if v.Width != tt.want[i].width || v.Height != tt.want[i].height {
+					t.Errorf("variant %d is %dx%d, want %dx%d", i, v.Width, v.Height, tt.want[i].width, tt.want[i].height)
+				}
+				if v.ContentType != tt.contentType {
+					t.Errorf("variant %d is %s, want %s", i, v.ContentType, tt.contentType)
+				}
+				config, _, err := image.DecodeConfig(bytes.NewReader(v.Data))
+				if err != nil || config.Width != v.Width || config.Height != v.Height {
+					t.Errorf("variant %d data decodes to %+v, %v", i, config, err)
+				}
+				if !bytes.HasPrefix(v.WebP, []byte("RIFF")) {
+					t.Errorf("variant %d has no WebP copy", i)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	// a GIF whose header claims 10000x10000 pixels, refused before decoding
+	huge := encoded(t, "gif", 1, 1)
+	binary.LittleEndian.PutUint16(huge[6:], 10000)
+	binary.LittleEndian.PutUint16(huge[8:], 10000)
+
+	truncated := encoded(t, "png", 50, 50)
+	truncated = truncated[:len(truncated)/2]
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"text", []byte("not an image at all"), ErrUnsupported},
+		{"truncated png", truncated, ErrUnsupported},
+		{"png signature only", []byte("\x89PNG\r\n\x1a\n"), ErrUnsupported},
+		{"too many pixels", huge, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data, []int{160}); !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
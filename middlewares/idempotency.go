@@ -0,0 +1,240 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+const (
+	idempotencyHeader     = "Idempotency-Key"
+	idempotencyTTL        = 24 * time.Hour
+	idempotencyLockTTL    = 30 * time.Second
+	idempotencyInProgress = "in_progress"
+)
+
+// IdempotencyStore keeps the responses of keyed requests between retries
+type IdempotencyStore interface {
+	// Reserve stores value under key unless the key is taken, reporting
+	// whether it did
+	Reserve(key string, value []byte, ttl time.Duration) (bool, error)
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+// NewRedisIdempotencyStore keeps the responses in Redis, one client serves
+// every request
+func NewRedisIdempotencyStore(client *redis.Client) IdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+func (s *redisIdempotencyStore) Reserve(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(key, value, ttl).Result()
+}
+
+func (s *redisIdempotencyStore) Get(key string) ([]byte, error) {
+	return s.client.Get(key).Bytes()
+}
+
+func (s *redisIdempotencyStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(key, value, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Delete(key string) error {
+	return s.client.Del(key).Err()
+}
+
+// stored response for a given Idempotency-Key
+type idempotentResponse struct {
+	State       string `json:"state"`
+	RequestHash string `json:"requestHash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	// the other response headers, Set-Cookie included
+	Headers http.Header `json:"headers"`
+	Body    []byte      `json:"body"`
+}
+
+// headers that describe the original transfer rather than the response
+var unreplayedHeaders = []string{"Content-Type", "Content-Length", "Date", "Idempotent-Replayed"}
+
+// captures whatever the handler writes so it can be replayed later
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the first response for a repeated Idempotency-Key.
+// Keys are scoped per user (or per client IP on public routes), and a retry
+// whose path, query or body differs from the original request is rejected
+// with 422.
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodPost && ctx.Request.Method != http.MethodPut {
+			ctx.Next()
+			return
+		}
+
+		key := ctx.GetHeader(idempotencyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > 255 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Idempotency-Key must be at most 255 characters",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "unable to read request body",
+			})
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		owner := ctx.GetString("userId")
+		if owner == "" {
+			owner = "ip:" + ctx.ClientIP()
+		}
+		redisKey := fmt.Sprintf("idempotency:%s:%s", owner, key)
+		requestHash := hashRequest(ctx.Request.Method, ctx.Request.URL.RequestURI(), body)
+
+		// reserve the key, so that concurrent retries don't both run the handler
+		lock, _ := json.Marshal(idempotentResponse{
+			State:       idempotencyInProgress,
+			RequestHash: requestHash,
+		})
+		acquired, err := store.Reserve(redisKey, lock, idempotencyLockTTL)
+		if err != nil {
+			// the store is unavailable, serve the request without idempotency guarantees
+			fmt.Println("idempotency store unavailable:", err)
+			ctx.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(ctx, store, redisKey, requestHash)
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: ctx.Writer, body: &bytes.Buffer{}}
+		ctx.Writer = writer
+		ctx.Next()
+
+		status := writer.Status()
+		// server errors are not final, let the client retry with the same key
+		if status >= http.StatusInternalServerError {
+			if err := store.Delete(redisKey); err != nil {
+				fmt.Println("failed to release idempotency key:", err)
+			}
+			return
+		}
+
+		stored, _ := json.Marshal(idempotentResponse{
+			RequestHash: requestHash,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Headers:     replayedHeaders(writer.Header()),
+			Body:        writer.body.Bytes(),
+		})
+		if err := store.Set(redisKey, stored, idempotencyTTL); err != nil {
+			fmt.Println("failed to store idempotent response:", err)
+		}
+	}
+}
+
+func replayIdempotentResponse(ctx *gin.Context, store IdempotencyStore, redisKey, requestHash string) {
+	raw, err := store.Get(redisKey)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "a request with this Idempotency-Key is already being processed",
+		})
+		return
+	}
+
+	var previous idempotentResponse
+	if err := json.Unmarshal(raw, &previous); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "corrupted idempotency record",
+		})
+		return
+	}
+
+	if previous.RequestHash != requestHash {
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   "Idempotency-Key was already used with a different request",
+		})
+		return
+	}
+
+	if previous.State == idempotencyInProgress {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "a request with this Idempotency-Key is already being processed",
+		})
+		return
+	}
+
+	for name, values := range previous.Headers {
+		for _, value := range values {
+			ctx.Writer.Header().Add(name, value)
+		}
+	}
+	ctx.Header("Idempotent-Replayed", "true")
+	contentType := previous.ContentType
+	if contentType == "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	ctx.Data(previous.Status, contentType, previous.Body)
+	ctx.Abort()
+}
+
+func replayedHeaders(header http.Header) http.Header {
+	headers := header.Clone()
+	for _, name := range unreplayedHeaders {
+		headers.Del(name)
+	}
+	return headers
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
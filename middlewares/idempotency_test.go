@@ -0,0 +1,212 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryStore stands in for Redis
+type memoryStore struct {
+	values map[string][]byte
+	// every call fails with it when set
+	err error
+}
+
+func (s *memoryStore) Reserve(key string, value []byte, _ time.Duration) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	if _, ok := s.values[key]; ok {
+		return false, nil
+	}
+	s.values[key] = value
+	return true, nil
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	value, ok := s.values[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return value, nil
+}
+
+func (s *memoryStore) Set(key string, value []byte, _ time.Duration) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.values, key)
+	return nil
+}
+
+// idempotencyRouter counts the calls that reach the handler, which answers
+// with the status in ?status= (201 by default)
+func idempotencyRouter(store IdempotencyStore, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("userId", "user-1")
+		ctx.Next()
+	})
+	router.Use(Idempotency(store))
+	handler := func(ctx *gin.Context) {
+		*calls++
+		status := http.StatusCreated
+		if ctx.Query("status") == "500" {
+			status = http.StatusInternalServerError
+		}
+		ctx.Header("X-Call", ctx.Param("id"))
+		ctx.JSON(status, gin.H{"id": ctx.Param("id"), "call": *calls})
+	}
+	router.PUT("/approve/:id", handler)
+	router.GET("/approve/:id", handler)
+	return router
+}
+
+func send(router *gin.Engine, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	calls := 0
+	router := idempotencyRouter(&memoryStore{values: map[string][]byte{}}, &calls)
+
+	first := send(router, http.MethodPut, "/approve/A", "key-1", `{"note":"x"}`)
+	second := send(router, http.MethodPut, "/approve/A", "key-1", `{"note":"x"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is not marked Idempotent-Replayed")
+	}
+	if second.Header().Get("X-Call") != "A" {
+		t.Errorf("replayed X-Call = %q, want the original header", second.Header().Get("X-Call"))
+	}
+}
+
+func TestIdempotencyRejectsAReusedKey(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"other path param", "/approve/B", `{"note":"x"}`},
+		{"other query", "/approve/A?notify=false", `{"note":"x"}`},
+		{"other body", "/approve/A", `{"note":"y"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			router := idempotencyRouter(&memoryStore{values: map[string][]byte{}}, &calls)
+
+			send(router, http.MethodPut, "/approve/A", "key-1", `{"note":"x"}`)
+			w := send(router, http.MethodPut, tc.target, "key-1", tc.body)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want 422", w.Code)
+			}
+			if calls != 1 {
+				t.Errorf("handler ran %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRequestInFlight(t *testing.T) {
+	calls := 0
+	store := &memoryStore{values: map[string][]byte{}}
+	router := idempotencyRouter(store, &calls)
+
+	// reserve the key the way a request that is still running does
+	stalled := gin.New()
+	stalled.Use(func(ctx *gin.Context) {
+		ctx.Set("userId", "user-1")
+		ctx.Next()
+	})
+	stalled.Use(Idempotency(store))
+	stalled.PUT("/approve/:id", func(ctx *gin.Context) {
+		w := send(router, http.MethodPut, "/approve/A", "key-1", "")
+		if w.Code != http.StatusConflict {
+			t.Errorf("status while in flight = %d, want 409", w.Code)
+		}
+		ctx.Status(http.StatusNoContent)
+	})
+	send(stalled, http.MethodPut, "/approve/A", "key-1", "")
+
+	if calls != 0 {
+		t.Errorf("handler ran %d times while the key was in flight, want 0", calls)
+	}
+}
+
+func TestIdempotencyLetsServerErrorsBeRetried(t *testing.T) {
+	calls := 0
+	router := idempotencyRouter(&memoryStore{values: map[string][]byte{}}, &calls)
+
+	send(router, http.MethodPut, "/approve/A?status=500", "key-1", "")
+	w := send(router, http.MethodPut, "/approve/A?status=500", "key-1", "")
+
+	if w.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+		t.Errorf("retry after a server error was replayed, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotencyPassesThrough(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		method string
+		key    string
+		store  *memoryStore
+	}{
+		{"without a key", http.MethodPut, "", &memoryStore{values: map[string][]byte{}}},
+		{"on GET", http.MethodGet, "key-1", &memoryStore{values: map[string][]byte{}}},
+		{"when the store is down", http.MethodPut, "key-1", &memoryStore{values: map[string][]byte{}, err: errors.New("down")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			router := idempotencyRouter(tc.store, &calls)
+
+			send(router, tc.method, "/approve/A", tc.key, "")
+			send(router, tc.method, "/approve/A", tc.key, "")
+
+			if calls != 2 {
+				t.Errorf("handler ran %d times, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	calls := 0
+	router := idempotencyRouter(&memoryStore{values: map[string][]byte{}}, &calls)
+
+	w := send(router, http.MethodPut, "/approve/A", strings.Repeat("k", 256), "")
+	if w.Code != http.StatusBadRequest || calls != 0 {
+		t.Errorf("status = %d with %d calls, want 400 without calling the handler", w.Code, calls)
+	}
+}
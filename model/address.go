@@ -0,0 +1,125 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Address struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id"`
+	UserId            primitive.ObjectID `json:"userId"`
+	Name              string             `json:"name"`
+	Line1             string             `json:"line1"`
+	Line2             string             `json:"line2"`
+	City              string             `json:"city"`
+	Region            string             `json:"region"`
+	PostalCode        string             `json:"postalCode"`
+	Country           string             `json:"country"`
+	Phone             string             `json:"phone"`
+	IsDefaultShipping bool               `json:"isDefaultShipping"`
+	IsDefaultBilling  bool               `json:"isDefaultBilling"`
+	CreatedAt         time.Time          `json:"createdAt"`
+	UpdatedAt         time.Time          `json:"updatedAt"`
+}
+
+func NewAddress(userId primitive.ObjectID, name, line1, line2, city, region, postalCode, country, phone string) *Address {
+	address := &Address{
+		ID:         primitive.NewObjectID(),
+		UserId:     userId,
+		Name:       name,
+		Line1:      line1,
+		Line2:      line2,
+		City:       city,
+		Region:     region,
+		PostalCode: postalCode,
+		Country:    country,
+		Phone:      phone,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	address.Normalize()
+	return address
+}
+
+// per-country address rules, countries not listed here only get the generic checks
+type countryRule struct {
+	postalCode     *regexp.Regexp
+	regionRequired bool
+}
+
+var countryRules = map[string]countryRule{
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`), regionRequired: true},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`)},
+	"IN": {postalCode: regexp.MustCompile(`^\d{6}$`), regionRequired: true},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Za-z]{2}$`)},
+	"AU": {postalCode: regexp.MustCompile(`^\d{4}$`), regionRequired: true},
+	"JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), regionRequired: true},
+}
+
+var (
+	countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	phonePattern       = regexp.MustCompile(`^\+?[0-9 ()-]{6,20}$`)
+	postalCodePattern  = regexp.MustCompile(`^[A-Za-z0-9 -]{2,10}$`)
+)
+
+// Normalize trims every field and upper-cases the country code
+func (a *Address) Normalize() {
+	a.Name = strings.TrimSpace(a.Name)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.Region = strings.TrimSpace(a.Region)
+	a.PostalCode = strings.TrimSpace(a.PostalCode)
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	a.Phone = strings.TrimSpace(a.Phone)
+}
+
+// Validate checks the address against the generic and per-country rules
+func (a *Address) Validate() error {
+	switch {
+	case a.Name == "":
+		return fmt.Errorf("name is required")
+	case a.Line1 == "":
+		return fmt.Errorf("line1 is required")
+	case a.City == "":
+		return fmt.Errorf("city is required")
+	case !countryCodePattern.MatchString(a.Country):
+		return fmt.Errorf("country must be an ISO 3166-1 alpha-2 code")
+	case a.Phone != "" && !phonePattern.MatchString(a.Phone):
+		return fmt.Errorf("invalid phone number")
+	}
+
+	rule, ok := countryRules[a.Country]
+	if !ok {
+		if a.PostalCode != "" && !postalCodePattern.MatchString(a.PostalCode) {
+			return fmt.Errorf("invalid postal code")
+		}
+		return nil
+	}
+	if rule.regionRequired && a.Region == "" {
+		return fmt.Errorf("region is required for %s", a.Country)
+	}
+	if !rule.postalCode.MatchString(a.PostalCode) {
+		return fmt.Errorf("invalid postal code for %s", a.Country)
+	}
+	return nil
+}
+
+// String formats the address on a single line
+func (a *Address) String() string {
+	parts := []string{a.Name, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country}
+	nonEmpty := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressValidate(t *testing.T) {
+	address := func(country, region, postalCode string) *Address {
+		return &Address{Name: "Ada Lovelace", Line1: "12 Main St", City: "Springfield", Country: country, Region: region, PostalCode: postalCode}
+	}
+
+	tests := []struct {
+		name    string
+		address *Address
+		// part of the error, empty when the address is valid
+		err string
+	}{
+		{"us zip", address("US", "IL", "62701"), ""},
+		{"us zip+4", address("US", "IL", "62701-1234"), ""},
+		{"us short zip", address("US", "IL", "6270"), "invalid postal code for US"},
+		{"us without state", address("US", "", "62701"), "region is required for US"},
+		{"us without zip", address("US", "IL", ""), "invalid postal code for US"},
+		{"canada", address("CA", "ON", "K1A 0B1"), ""},
+		{"canada without space", address("CA", "ON", "k1a0b1"), ""},
+		{"canada digits only", address("CA", "ON", "123456"), "invalid postal code for CA"},
+		{"uk", address("GB", "", "SW1A 1AA"), ""},
+		{"uk short outward code", address("GB", "", "M1 1AE"), ""},
+		{"uk bad", address("GB", "", "12345"), "invalid postal code for GB"},
+		{"india", address("IN", "KA", "560001"), ""},
+		{"india without state", address("IN", "", "560001"), "region is required for IN"},
+		{"germany without region", address("DE", "", "10115"), ""},
+		{"germany bad", address("DE", "", "1011"), "invalid postal code for DE"},
+		{"netherlands", address("NL", "", "1012 AB"), ""},
+		{"australia", address("AU", "NSW", "2000"), ""},
+		{"japan with dash", address("JP", "Tokyo", "100-0001"), ""},
+		{"japan without dash", address("JP", "Tokyo", "1000001"), ""},
+		{"other country without postal code", address("IE", "", ""), ""},
+		{"other country postal code", address("IE", "", "D02 X285"), ""},
+		{"other country bad postal code", address("IE", "", "D02#X285"), "invalid postal code"},
+		{"lower case country", address("us", "IL", "62701"), "country must be an ISO 3166-1 alpha-2 code"},
+		{"three letter country", address("USA", "IL", "62701"), "country must be an ISO 3166-1 alpha-2 code"},
+		{"missing name", &Address{Line1: "12 Main St", City: "Springfield", Country: "DE", PostalCode: "10115"}, "name is required"},
+		{"missing line1", &Address{Name: "Ada", City: "Springfield", Country: "DE", PostalCode: "10115"}, "line1 is required"},
+		{"missing city", &Address{Name: "Ada", Line1: "12 Main St", Country: "DE", PostalCode: "10115"}, "city is required"},
+		{"phone", &Address{Name: "Ada", Line1: "12 Main St", City: "Berlin", Country: "DE", PostalCode: "10115", Phone: "+49 (30) 123-456"}, ""},
+		{"bad phone", &Address{Name: "Ada", Line1: "12 Main St", City: "Berlin", Country: "DE", PostalCode: "10115", Phone: "call me"}, "invalid phone number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.address.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAddressNormalize(t *testing.T) {
+	a := &Address{Name: " Ada ", Line1: " 12 Main St ", City: " Berlin\t", Country: " de ", PostalCode: " 10115 "}
+	a.Normalize()
+	if a.Name != "Ada" || a.Line1 != "12 Main St" || a.City != "Berlin" || a.Country != "DE" || a.PostalCode != "10115" {
+		t.Errorf("got %+v", a)
+	}
+	if err := a.Validate(); err != nil {
+		t.Errorf("normalized address is invalid: %v", err)
+	}
+}
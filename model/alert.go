@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// product alert types
+const (
+	AlertBackInStock = "back_in_stock"
+	AlertPriceDrop   = "price_drop"
+)
+
+// ProductAlert is a user's subscription to a change of a product. Stock is
+// tracked per product, the variant is only passed on in the notification.
+type ProductAlert struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserId    primitive.ObjectID `json:"userId"`
+	ProductID primitive.ObjectID `json:"productId"`
+	Type      string             `json:"type"`
+	Size      string             `json:"size"`
+	Color     string             `json:"color"`
+	// waiting for the change, a back in stock alert fires once
+	Active bool `json:"active"`
+	// a price drop is a price below this one, it moves down with every
+	// notification so the same drop isn't reported twice
+	ReferencePrice Money `json:"referencePrice"`
+	// identifies the alert in unsubscribe links
+	UnsubscribeToken string     `json:"-"`
+	SubscribedAt     time.Time  `json:"subscribedAt"`
+	LastNotifiedAt   *time.Time `json:"lastNotifiedAt"`
+}
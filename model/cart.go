@@ -1,14 +1,176 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cart warning codes
+const (
+	CartWarningProductDeleted   = "product_deleted"
+	CartWarningOutOfStock       = "out_of_stock"
+	CartWarningPriceUnavailable = "price_unavailable"
+	CartWarningCouponInvalid    = "coupon_invalid"
+)
+
+// line status of a cart line that can be ordered as it is, the other
+// statuses are the warning and notice codes
+const CartLineOK = "ok"
+
+// cart notice codes, changes the cart reconciliation made
+const (
+	CartNoticeProductRemoved = "product_removed"
+	// the quantity was cut down to the stock left
+	CartNoticeQuantityReduced = "quantity_reduced"
+	CartNoticeOverLineLimit   = "over_line_limit"
+)
+
+// how a guest cart is merged into a saved cart on login
+const (
+	CartMergeSum    = "sum"
+	CartMergeLatest = "latest"
+)
 
 type ProductDetails struct {
 	ProductID primitive.ObjectID `json:"product_id"`
 	Quantity  int                `json:"quantity"`
+	// set by the cart reconciliation while the product can't be ordered,
+	// e.g. "out_of_stock"
+	Status string `json:"status,omitempty"`
 }
 
 type Cart struct {
 	ID       primitive.ObjectID `json:"id" bson:"_id"`
 	Products []ProductDetails   `json:"productDetails"`
 	UserId   primitive.ObjectID `json:"userId"`
+	// coupon codes applied to the cart, redeemed when the order is placed
+	CouponCodes []string  `json:"couponCodes"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// last change to the items or coupons, abandoned carts are found by it
+	UpdatedAt time.Time `json:"updatedAt"`
+	// abandoned cart reminders sent since the last change
+	RemindersSent  int        `json:"remindersSent"`
+	LastRemindedAt *time.Time `json:"lastRemindedAt"`
+	// what the cart reconciliation changed, until the customer dismisses them
+	Notices []CartNotice `json:"notices"`
+	// when the cart is removed unless it is changed before, never stored
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" bson:"-"`
+	// promotions and coupon discounts on the current cart, never stored
+	Pricing *PromotionResult `json:"pricing,omitempty" bson:"-"`
+	// the items with their products as they are now and what is wrong with
+	// them, never stored
+	Lines    []CartLine    `json:"lines,omitempty" bson:"-"`
+	Warnings []CartWarning `json:"warnings,omitempty" bson:"-"`
+}
+
+// CartLine is a cart item with its current product details and prices.
+// Lines that are not Available are left out of the totals.
+type CartLine struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	Quantity  int                `json:"quantity"`
+	Title     string             `json:"title"`
+	Img       string             `json:"img"`
+	InStock   bool               `json:"instock"`
+	Available bool               `json:"available"`
+	UnitPrice Money              `json:"unitPrice"`
+	Total     Money              `json:"total"`
+	Discount  Money              `json:"discount"`
+	Tax       Money              `json:"tax"`
+	// CartLineOK or why the line needs the customer's attention, with a
+	// message to show next to it
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// CartWarning tells the customer about something that stops the cart from
+// being ordered as it is
+type CartWarning struct {
+	Code      string              `json:"code"`
+	Message   string              `json:"message"`
+	ProductID *primitive.ObjectID `json:"product_id,omitempty"`
+}
+
+// CartNotice tells the customer about a change made to their cart without
+// them, e.g. a product that is no longer sold being removed
+type CartNotice struct {
+	Code      string             `json:"code"`
+	Message   string             `json:"message"`
+	ProductID primitive.ObjectID `json:"product_id"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// GuestCart holds the items of a shopper who is not logged in. It is found
+// through a signed cookie and removed once ExpiresAt passes.
+type GuestCart struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Products  []ProductDetails   `json:"productDetails"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+}
+
+// MergeCartItems merges guest items into saved ones with the strategy. Lines
+// are capped at maxQuantity, 0 means no cap.
+func MergeCartItems(saved, guest []ProductDetails, strategy string, maxQuantity int) []ProductDetails {
+	merged := make([]ProductDetails, len(saved))
+	copy(merged, saved)
+	for _, item := range guest {
+		found := false
+		for i := range merged {
+			if merged[i].ProductID != item.ProductID {
+				continue
+			}
+			found = true
+			if strategy == CartMergeLatest {
+				merged[i].Quantity = item.Quantity
+			} else {
+				merged[i].Quantity += item.Quantity
+			}
+			break
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	if maxQuantity > 0 {
+		for i := range merged {
+			if merged[i].Quantity > maxQuantity {
+				merged[i].Quantity = maxQuantity
+			}
+		}
+	}
+	return merged
+}
+
+// CartReminder records an abandoned cart reminder. It is marked recovered
+// when the user orders soon after it.
+type CartReminder struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	CartID primitive.ObjectID `json:"cartId"`
+	UserId primitive.ObjectID `json:"userId"`
+	// 1 for the first reminder of a cart
+	Stage       int                 `json:"stage"`
+	SentAt      time.Time           `json:"sentAt"`
+	RecoveredAt *time.Time          `json:"recoveredAt"`
+	OrderID     *primitive.ObjectID `json:"orderId"`
+}
+
+// AbandonedCartReport sums up the reminders sent in a period
+type AbandonedCartReport struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	RemindersSent  int64     `json:"remindersSent"`
+	CartsReminded  int64     `json:"cartsReminded"`
+	CartsRecovered int64     `json:"cartsRecovered"`
+	// share of reminded carts that led to an order, 0 to 1
+	RecoveryRate float64              `json:"recoveryRate"`
+	Stages       []AbandonedCartStage `json:"stages"`
+}
+
+type AbandonedCartStage struct {
+	Stage        int     `json:"stage"`
+	Sent         int64   `json:"sent"`
+	Recovered    int64   `json:"recovered"`
+	RecoveryRate float64 `json:"recoveryRate"`
 }
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeCartItems(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	line := func(id primitive.ObjectID, quantity int) ProductDetails {
+		return ProductDetails{ProductID: id, Quantity: quantity}
+	}
+
+	tests := []struct {
+		name        string
+		saved       []ProductDetails
+		guest       []ProductDetails
+		strategy    string
+		maxQuantity int
+		want        []ProductDetails
+	}{
+		{"sum adds quantities", []ProductDetails{line(a, 2), line(b, 1)}, []ProductDetails{line(a, 3)}, CartMergeSum, 0,
+			[]ProductDetails{line(a, 5), line(b, 1)}},
+		{"latest takes the guest quantity", []ProductDetails{line(a, 2), line(b, 1)}, []ProductDetails{line(a, 3)}, CartMergeLatest, 0,
+			[]ProductDetails{line(a, 3), line(b, 1)}},
+		{"latest can lower a quantity", []ProductDetails{line(a, 5)}, []ProductDetails{line(a, 1)}, CartMergeLatest, 0,
+			[]ProductDetails{line(a, 1)}},
+		{"new products are appended", []ProductDetails{line(a, 1)}, []ProductDetails{line(b, 2), line(c, 1)}, CartMergeSum, 0,
+			[]ProductDetails{line(a, 1), line(b, 2), line(c, 1)}},
+		{"empty saved cart", []ProductDetails{}, []ProductDetails{line(a, 2)}, CartMergeLatest, 0,
+			[]ProductDetails{line(a, 2)}},
+		{"repeated guest lines are merged", []ProductDetails{}, []ProductDetails{line(a, 2), line(a, 3)}, CartMergeSum, 0,
+			[]ProductDetails{line(a, 5)}},
+		{"sum is capped", []ProductDetails{line(a, 8)}, []ProductDetails{line(a, 5)}, CartMergeSum, 10,
+			[]ProductDetails{line(a, 10)}},
+		{"cap applies to saved lines too", []ProductDetails{line(a, 12), line(b, 1)}, []ProductDetails{line(b, 1)}, CartMergeLatest, 10,
+			[]ProductDetails{line(a, 10), line(b, 1)}},
+		{"cap applies to new lines", []ProductDetails{}, []ProductDetails{line(a, 50)}, CartMergeSum, 10,
+			[]ProductDetails{line(a, 10)}},
+		{"zero means no cap", []ProductDetails{line(a, 500)}, []ProductDetails{line(a, 500)}, CartMergeSum, 0,
+			[]ProductDetails{line(a, 1000)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := append([]ProductDetails{}, tt.saved...)
+			got := MergeCartItems(tt.saved, tt.guest, tt.strategy, tt.maxQuantity)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.saved, saved) {
+				t.Errorf("saved items were changed to %+v", tt.saved)
+			}
+		})
+	}
+}
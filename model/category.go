@@ -0,0 +1,49 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	slugPattern   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Category is a node of the category tree. Products list the slugs of their
+// categories, so a slug is unique across the whole tree.
+type Category struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id"`
+	Slug        string              `json:"slug"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	ParentID    *primitive.ObjectID `json:"parentId"`
+	// ids of the ancestors, root first, to find a subtree in one query
+	Path []primitive.ObjectID `json:"path"`
+	// siblings are ordered by position, then name
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// filled in when a tree is returned
+	Children []Category `json:"children,omitempty" bson:"-"`
+}
+
+// Slugify turns a name into a slug, e.g. "Men's T-Shirts" into "men-s-t-shirts"
+func Slugify(name string) string {
+	return strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+type CategoryProducts struct {
+	Category Category  `json:"category"`
+	Products []Product `json:"products"`
+	Page     int       `json:"page"`
+	Limit    int       `json:"limit"`
+	Total    int64     `json:"total"`
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// coupon types
+const (
+	CouponPercentage   = "percentage"
+	CouponFixedAmount  = "fixed_amount"
+	CouponFreeShipping = "free_shipping"
+)
+
+type Coupon struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	Code string             `json:"code"`
+	Type string             `json:"type"`
+	// percentage coupons, 1 to 100
+	PercentOff int `json:"percentOff"`
+	// fixed amount coupons, converted when the order is in another currency
+	AmountOff Money  `json:"amountOff"`
+	MinSpend  *Money `json:"minSpend"`
+	// when set, only these products or categories are discounted
+	ProductIDs []primitive.ObjectID `json:"productIds"`
+	Categories []string             `json:"categories"`
+	StartsAt   *time.Time           `json:"startsAt"`
+	EndsAt     *time.Time           `json:"endsAt"`
+	// 0 means unlimited
+	UsageLimit       int `json:"usageLimit"`
+	PerCustomerLimit int `json:"perCustomerLimit"`
+	UsedCount        int `json:"usedCount"`
+	// a coupon that is not stackable can't be combined with any other coupon
+	Stackable bool      `json:"stackable"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NormalizeCouponCode makes codes case insensitive
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func NewCoupon(coupon *Coupon) *Coupon {
+	newCoupon := *coupon
+	newCoupon.ID = primitive.NewObjectID()
+	newCoupon.Code = NormalizeCouponCode(coupon.Code)
+	newCoupon.UsedCount = 0
+	if newCoupon.ProductIDs == nil {
+		newCoupon.ProductIDs = []primitive.ObjectID{}
+	}
+	if newCoupon.Categories == nil {
+		newCoupon.Categories = []string{}
+	}
+	newCoupon.CreatedAt = time.Now()
+	newCoupon.UpdatedAt = time.Now()
+	return &newCoupon
+}
+
+// Validate checks the coupon definition, not whether it applies to an order
+func (c *Coupon) Validate() error {
+	if len(c.Code) < 3 || len(c.Code) > 32 {
+		return fmt.Errorf("code must be between 3 and 32 characters")
+	}
+	switch c.Type {
+	case CouponPercentage:
+		if c.PercentOff < 1 || c.PercentOff > 100 {
+			return fmt.Errorf("percentOff must be between 1 and 100")
+		}
+	case CouponFixedAmount:
+		if !IsValidCurrency(c.AmountOff.Currency) || c.AmountOff.Amount <= 0 {
+			return fmt.Errorf("amountOff must be a positive amount in a supported currency")
+		}
+	case CouponFreeShipping:
+	default:
+		return fmt.Errorf("unknown coupon type %q", c.Type)
+	}
+	if c.MinSpend != nil && (!IsValidCurrency(c.MinSpend.Currency) || c.MinSpend.IsNegative()) {
+		return fmt.Errorf("minSpend must be a positive amount in a supported currency")
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+	if c.UsageLimit < 0 || c.PerCustomerLimit < 0 {
+		return fmt.Errorf("usage limits can't be negative")
+	}
+	return nil
+}
+
+// IsValidAt reports whether the coupon can be used at the given time
+func (c *Coupon) IsValidAt(now time.Time) bool {
+	if !c.Active {
+		return false
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && !now.Before(*c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Applies reports whether a product is eligible for the coupon
+func (c *Coupon) Applies(productID primitive.ObjectID, categories []string) bool {
+	return inScope(c.ProductIDs, c.Categories, productID, categories)
+}
+
+// an empty scope covers every product
+func inScope(productIDs []primitive.ObjectID, scopeCategories []string, productID primitive.ObjectID, categories []string) bool {
+	if len(productIDs) == 0 && len(scopeCategories) == 0 {
+		return true
+	}
+	for _, id := range productIDs {
+		if id == productID {
+			return true
+		}
+	}
+	for _, category := range scopeCategories {
+		for _, productCategory := range categories {
+			if strings.EqualFold(category, productCategory) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AppliedDiscount is a coupon as it was applied to a cart or an order
+type AppliedDiscount struct {
+	CouponID     primitive.ObjectID `json:"couponId"`
+	Code         string             `json:"code"`
+	Type         string             `json:"type"`
+	Amount       Money              `json:"amount"`
+	FreeShipping bool               `json:"freeShipping"`
+}
+
+// CouponRedemption records a coupon used by an order
+type CouponRedemption struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	CouponID  primitive.ObjectID `json:"couponId"`
+	Code      string             `json:"code"`
+	OrderID   primitive.ObjectID `json:"orderId"`
+	UserId    primitive.ObjectID `json:"userId"`
+	Discount  Money              `json:"discount"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// CouponUsage counts the orders of one customer that used a coupon, the per
+// customer limit is checked against it
+type CouponUsage struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	CouponID primitive.ObjectID `json:"couponId"`
+	UserId   primitive.ObjectID `json:"userId"`
+	Count    int                `json:"count"`
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// exchange rates are stored as fixed point numbers with six decimals
+const RateScale = 1_000_000
+
+// ExchangeRate converts one unit of From into RateMicros/RateScale units of To
+type ExchangeRate struct {
+	ID         string    `json:"id" bson:"_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	RateMicros int64     `json:"rateMicros"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// AppliedRate records the rate that was used to price an order
+type AppliedRate struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	RateMicros int64  `json:"rateMicros"`
+}
+
+func ExchangeRateID(from, to string) string {
+	return from + ":" + to
+}
+
+func NewExchangeRate(from, to string, rate float64) *ExchangeRate {
+	return &ExchangeRate{
+		ID:         ExchangeRateID(from, to),
+		From:       from,
+		To:         to,
+		RateMicros: int64(math.Round(rate * RateScale)),
+		UpdatedAt:  time.Now(),
+	}
+}
+
+// Convert changes the amount into another currency using a fixed point rate,
+// taking the different number of decimals of both currencies into account.
+func (m Money) Convert(to string, rateMicros int64) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	if rateMicros <= 0 {
+		return Money{}, fmt.Errorf("invalid exchange rate")
+	}
+	num := rateMicros * int64(math.Pow10(CurrencyExponent(to)))
+	den := int64(RateScale) * int64(math.Pow10(CurrencyExponent(m.Currency)))
+	converted, err := m.MulRat(num, den)
+	if err != nil {
+		return Money{}, err
+	}
+	converted.Currency = to
+	return converted, nil
+}
+
+// PriceIn returns the explicit price of the product in the currency, if it has one
+func (p *Product) PriceIn(currency string) (Money, bool) {
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return Money{}, false
+}
@@ -8,3 +8,7 @@ type ErrMsg struct {
 func (e ErrMsg) Error() string {
 	return e.Err.Error()
 }
+
+func (e ErrMsg) Unwrap() error {
+	return e.Err
+}
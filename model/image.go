@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProductImage is an uploaded image of a product with its resized copies
+type ProductImage struct {
+	ID          primitive.ObjectID `json:"id"`
+	URL         string             `json:"url"`
+	ContentType string             `json:"contentType"`
+	Width       int                `json:"width"`
+	Height      int                `json:"height"`
+	// size of the original in bytes
+	Size int64  `json:"size"`
+	Alt  string `json:"alt"`
+	// smallest first
+	Variants  []ImageVariant `json:"variants"`
+	CreatedAt time.Time      `json:"createdAt"`
+	// where the original is stored
+	Key string `json:"-"`
+}
+
+// ImageVariant is an image scaled to a width, in its own format and as WebP
+type ImageVariant struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	URL     string `json:"url"`
+	WebPURL string `json:"webpUrl"`
+	Key     string `json:"-"`
+	WebPKey string `json:"-"`
+}
+
+// Keys of all the stored files of the image
+func (i *ProductImage) Keys() []string {
+	keys := []string{i.Key}
+	for _, variant := range i.Variants {
+		keys = append(keys, variant.Key, variant.WebPKey)
+	}
+	return keys
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// invoice document types
+const (
+	InvoiceTypeInvoice    = "invoice"
+	InvoiceTypeCreditNote = "credit_note"
+)
+
+type InvoiceLine struct {
+	ProductID   primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Description string             `json:"description"`
+	Quantity    int                `json:"quantity"`
+	UnitPrice   Money              `json:"unitPrice"`
+	Total       Money              `json:"total"`
+}
+
+type Invoice struct {
+	ID              primitive.ObjectID  `json:"id" bson:"_id"`
+	Number          string              `json:"number"`
+	Type            string              `json:"type"`
+	OrderID         primitive.ObjectID  `json:"orderId"`
+	OrderNumber     string              `json:"orderNumber"`
+	UserId          primitive.ObjectID  `json:"userId"`
+	RefundID        *primitive.ObjectID `json:"refundId,omitempty"`
+	Lines           []InvoiceLine       `json:"lines"`
+	Subtotal        Money               `json:"subtotal"`
+	Discount        Money               `json:"discount"`
+	Shipping        Money               `json:"shipping"`
+	Tax             Money               `json:"tax"`
+	TaxInclusive    bool                `json:"taxInclusive"`
+	Total           Money               `json:"total"`
+	BillingAddress  *Address            `json:"billingAddress"`
+	ShippingAddress *Address            `json:"shippingAddress"`
+	StorageKey      string              `json:"-"`
+	CreatedAt       time.Time           `json:"createdAt"`
+}
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money amount overflow")
+)
+
+// DefaultCurrency is used for amounts stored before currencies existed. It is
+// set from the configuration at startup.
+var DefaultCurrency = "USD"
+
+// number of minor units per major unit (10^exponent), ISO 4217
+var currencyExponents = map[string]int{
+	"USD": 2, "EUR": 2, "GBP": 2, "INR": 2, "CAD": 2, "AUD": 2, "CHF": 2,
+	"CNY": 2, "SEK": 2, "NOK": 2, "DKK": 2, "PLN": 2, "BRL": 2, "MXN": 2,
+	"SGD": 2, "HKD": 2, "NZD": 2, "ZAR": 2, "AED": 2,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+	"KWD": 3, "BHD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// Money is an amount in the minor unit of its currency, e.g. cents for USD
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+func ZeroMoney(currency string) Money {
+	return NewMoney(0, currency)
+}
+
+// IsValidCurrency reports whether the ISO 4217 code is supported
+func IsValidCurrency(code string) bool {
+	_, ok := currencyExponents[strings.ToUpper(code)]
+	return ok
+}
+
+// CurrencyExponent is the number of decimals of the currency
+func CurrencyExponent(code string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(code)]; ok {
+		return exp
+	}
+	return 2
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns m + o, both amounts must be in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.currency(o)}, nil
+}
+
+// Sub returns m - o, both amounts must be in the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul multiplies the amount by a whole number, e.g. a quantity
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && m.Amount != 0 {
+		product := m.Amount * n
+		if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+			return Money{}, ErrMoneyOverflow
+		}
+		return Money{Amount: product, Currency: m.Currency}, nil
+	}
+	return Money{Amount: 0, Currency: m.Currency}, nil
+}
+
+// MulRat multiplies the amount by num/den and rounds half to even (banker's
+// rounding), which keeps sums of many rounded values unbiased.
+func (m Money) MulRat(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, errors.New("division by zero")
+	}
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num)),
+		big.NewInt(den),
+	)
+	rounded := roundHalfEven(r)
+	if !rounded.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: rounded.Int64(), Currency: m.Currency}, nil
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Min returns the smaller of two amounts of the same currency
+func (m Money) Min(o Money) (Money, error) {
+	c, err := m.Cmp(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if c <= 0 {
+		return m, nil
+	}
+	return o, nil
+}
+
+// Decimal renders the amount in major units without the currency, e.g. "19.99"
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exp == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+	div := int64(math.Pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/div, exp, amount%div)
+}
+
+// ParseDecimal reads an amount in major units, the reverse of Decimal
+func ParseDecimal(value, currency string) (Money, error) {
+	if !IsValidCurrency(currency) {
+		return Money{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+	value = strings.TrimSpace(value)
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || strings.ContainsAny(value, "/eE") {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	exp := CurrencyExponent(currency)
+	r.Mul(r, new(big.Rat).SetInt64(int64(math.Pow10(exp))))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("%s has at most %d decimals", strings.ToUpper(currency), exp)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return NewMoney(r.Num().Int64(), currency), nil
+}
+
+// Format renders the amount in major units, e.g. "19.99 USD"
+func (m Money) Format() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) String() string {
+	return m.Format()
+}
+
+// responses carry the formatted amount next to the raw values
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount    int64  `json:"amount"`
+		Currency  string `json:"currency"`
+		Formatted string `json:"formatted"`
+	}{m.Amount, m.Currency, m.Format()})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   *int64 `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("money must be an object with amount (in minor units) and currency")
+	}
+	if raw.Amount == nil {
+		return fmt.Errorf("money amount is required")
+	}
+	if !IsValidCurrency(raw.Currency) {
+		return fmt.Errorf("unsupported currency %q", raw.Currency)
+	}
+	*m = NewMoney(*raw.Amount, raw.Currency)
+	return nil
+}
+
+// UnmarshalBSONValue also accepts the bare numbers stored before amounts had a
+// currency. Those were whole units of the default currency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	var legacy int64
+	switch t {
+	case bsontype.EmbeddedDocument:
+		type plain Money
+		var p plain
+		if err := bson.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		*m = Money(p)
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+		return nil
+	case bsontype.Int32:
+		v, _, ok := bsoncore.ReadInt32(data)
+		if !ok {
+			return errors.New("invalid int32 money value")
+		}
+		legacy = int64(v)
+	case bsontype.Int64:
+		v, _, ok := bsoncore.ReadInt64(data)
+		if !ok {
+			return errors.New("invalid int64 money value")
+		}
+		legacy = v
+	case bsontype.Double:
+		v, _, ok := bsoncore.ReadDouble(data)
+		if !ok {
+			return errors.New("invalid double money value")
+		}
+		legacy = int64(math.Round(v))
+	default:
+		return fmt.Errorf("cannot decode %s into money", t)
+	}
+
+	amount, err := NewMoney(legacy, DefaultCurrency).Mul(int64(math.Pow10(CurrencyExponent(DefaultCurrency))))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+func (m Money) sameCurrency(o Money) error {
+	// a zero value without currency can be combined with anything, an amount
+	// without one can't be trusted to be in the other currency
+	if m.Currency == o.Currency || (m.Currency == "" && m.Amount == 0) || (o.Currency == "" && o.Amount == 0) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+func (m Money) currency(o Money) string {
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return o.Currency
+}
+
+func roundHalfEven(r *big.Rat) *big.Int {
+	num, den := r.Num(), r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// compare 2*|rem| with the denominator to find out which side of .5 we are on
+	twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+	step := big.NewInt(int64(num.Sign()))
+	switch twice.Cmp(den) {
+	case 1:
+		quo.Add(quo, step)
+	case 0:
+		if quo.Bit(0) == 1 {
+			quo.Add(quo, step)
+		}
+	}
+	return quo
+}
@@ -0,0 +1,180 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMoneyAddSubMul(t *testing.T) {
+	usd := func(amount int64) Money { return NewMoney(amount, "USD") }
+
+	tests := []struct {
+		name string
+		op   func() (Money, error)
+		want Money
+		err  error
+	}{
+		{"add", func() (Money, error) { return usd(150).Add(usd(250)) }, usd(400), nil},
+		{"add negative", func() (Money, error) { return usd(150).Add(usd(-250)) }, usd(-100), nil},
+		{"add overflow", func() (Money, error) { return usd(math.MaxInt64).Add(usd(1)) }, Money{}, ErrMoneyOverflow},
+		{"add underflow", func() (Money, error) { return usd(math.MinInt64).Add(usd(-1)) }, Money{}, ErrMoneyOverflow},
+		{"add zero without currency", func() (Money, error) { return usd(150).Add(Money{}) }, usd(150), nil},
+		{"add to zero without currency", func() (Money, error) { return Money{}.Add(usd(150)) }, usd(150), nil},
+		{"add amount without currency", func() (Money, error) { return usd(150).Add(Money{Amount: 1}) }, Money{}, ErrCurrencyMismatch},
+		{"add other currency", func() (Money, error) { return usd(150).Add(NewMoney(1, "EUR")) }, Money{}, ErrCurrencyMismatch},
+		{"sub", func() (Money, error) { return usd(250).Sub(usd(100)) }, usd(150), nil},
+		{"sub overflow", func() (Money, error) { return usd(math.MaxInt64).Sub(usd(-1)) }, Money{}, ErrMoneyOverflow},
+		{"sub min int", func() (Money, error) { return usd(0).Sub(usd(math.MinInt64)) }, Money{}, ErrMoneyOverflow},
+		{"sub underflow", func() (Money, error) { return usd(math.MinInt64).Sub(usd(1)) }, Money{}, ErrMoneyOverflow},
+		{"mul", func() (Money, error) { return usd(1999).Mul(3) }, usd(5997), nil},
+		{"mul by zero", func() (Money, error) { return usd(math.MaxInt64).Mul(0) }, usd(0), nil},
+		{"mul negative", func() (Money, error) { return usd(-5).Mul(-4) }, usd(20), nil},
+		{"mul overflow", func() (Money, error) { return usd(math.MaxInt64 / 2).Mul(3) }, Money{}, ErrMoneyOverflow},
+		{"mul min int by -1", func() (Money, error) { return usd(math.MinInt64).Mul(-1) }, Money{}, ErrMoneyOverflow},
+		{"mul -1 by min int", func() (Money, error) { return usd(-1).Mul(math.MinInt64) }, Money{}, ErrMoneyOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMulRat(t *testing.T) {
+	tests := []struct {
+		amount, num, den int64
+		want             int64
+	}{
+		{100, 1, 3, 33},
+		{200, 1, 3, 67},
+		// halves go to the even neighbour
+		{5, 1, 2, 2},
+		{15, 1, 2, 8},
+		{25, 1, 2, 12},
+		{-5, 1, 2, -2},
+		{-15, 1, 2, -8},
+		{-25, 1, 2, -12},
+		{1, 5, 2, 2},
+		{1, 7, 2, 4},
+		// just off the half rounds to the nearest
+		{251, 1, 10, 25},
+		{259, 1, 10, 26},
+		{1999, 2, 5, 800},
+		{0, 7, 3, 0},
+	}
+	for _, tt := range tests {
+		got, err := NewMoney(tt.amount, "USD").MulRat(tt.num, tt.den)
+		if err != nil {
+			t.Fatalf("%d * %d/%d: %v", tt.amount, tt.num, tt.den, err)
+		}
+		if got.Amount != tt.want {
+			t.Errorf("%d * %d/%d = %d, want %d", tt.amount, tt.num, tt.den, got.Amount, tt.want)
+		}
+	}
+
+	if _, err := NewMoney(1, "USD").MulRat(1, 0); err == nil {
+		t.Error("division by zero succeeded")
+	}
+	if _, err := NewMoney(math.MaxInt64, "USD").MulRat(3, 2); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("overflow error = %v, want %v", err, ErrMoneyOverflow)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value, currency string
+		want            Money
+		wantErr         bool
+	}{
+		{"19.99", "USD", NewMoney(1999, "USD"), false},
+		{" 19.9 ", "usd", NewMoney(1990, "USD"), false},
+		{"19", "USD", NewMoney(1900, "USD"), false},
+		{"-0.5", "EUR", NewMoney(-50, "EUR"), false},
+		{"1500", "JPY", NewMoney(1500, "JPY"), false},
+		{"1.234", "KWD", NewMoney(1234, "KWD"), false},
+		{"19.999", "USD", Money{}, true},
+		{"1.5", "JPY", Money{}, true},
+		{"1e3", "USD", Money{}, true},
+		{"1/2", "USD", Money{}, true},
+		{"abc", "USD", Money{}, true},
+		{"", "USD", Money{}, true},
+		{"1.00", "XXX", Money{}, true},
+		{"92233720368547758.08", "USD", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.value, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDecimal(%q, %q) error = %v, want error %v", tt.value, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDecimal(%q, %q) = %+v, want %+v", tt.value, tt.currency, got, tt.want)
+		}
+		if !tt.wantErr {
+			if back, _ := ParseDecimal(got.Decimal(), got.Currency); back != got {
+				t.Errorf("ParseDecimal(%q) does not round trip: %+v", got.Decimal(), back)
+			}
+		}
+	}
+}
+
+func TestMoneyLegacyBSON(t *testing.T) {
+	defer func(currency string) { DefaultCurrency = currency }(DefaultCurrency)
+	DefaultCurrency = "USD"
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  Money
+	}{
+		{"document", bson.M{"amount": int64(1999), "currency": "EUR"}, NewMoney(1999, "EUR")},
+		{"int32", int32(12), NewMoney(1200, "USD")},
+		{"int64", int64(-3), NewMoney(-300, "USD")},
+		{"double", 2.5, NewMoney(300, "USD")},
+		{"double rounded down", 19.2, NewMoney(1900, "USD")},
+		{"null", nil, Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(bson.M{"price": tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc struct {
+				Price Money `bson:"price"`
+			}
+			if err := bson.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.Price != tt.want {
+				t.Errorf("got %+v, want %+v", doc.Price, tt.want)
+			}
+		})
+	}
+
+	data, _ := bson.Marshal(bson.M{"price": "12"})
+	var doc struct {
+		Price Money `bson:"price"`
+	}
+	if err := bson.Unmarshal(data, &doc); err == nil {
+		t.Error("a string decoded into money")
+	}
+
+	DefaultCurrency = "JPY"
+	data, _ = bson.Marshal(bson.M{"price": int32(12)})
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if want := NewMoney(12, "JPY"); doc.Price != want {
+		t.Errorf("got %+v, want %+v", doc.Price, want)
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// queued notification states
+const (
+	NotificationPending = "pending"
+	NotificationSending = "sending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	// the alert it was for was unsubscribed before it went out
+	NotificationCancelled = "cancelled"
+)
+
+// Notification is a message waiting in the notification queue
+type Notification struct {
+	ID primitive.ObjectID `json:"id" bson:"_id"`
+	// only one notification is queued per key
+	DedupKey string              `json:"dedupKey"`
+	Kind     string              `json:"kind"`
+	UserId   primitive.ObjectID  `json:"userId"`
+	To       string              `json:"to"`
+	Subject  string              `json:"subject"`
+	Body     string              `json:"body"`
+	Data     map[string]string   `json:"data"`
+	AlertID  *primitive.ObjectID `json:"alertId"`
+	Status   string              `json:"status"`
+	Attempts int                 `json:"attempts"`
+	// when it may be tried again, also the lease of a notification being sent
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	LastError     string     `json:"lastError"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SentAt        *time.Time `json:"sentAt"`
+}
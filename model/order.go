@@ -1,37 +1,190 @@
 package model
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// order status values
+const (
+	OrderStatusCreated          = "created"
+	OrderStatusPartiallyShipped = "partially_shipped"
+	OrderStatusShipped          = "shipped"
+	OrderStatusDelivered        = "delivered"
+)
+
+// payment status values
+const (
+	PaymentStatusPending = "pending"
+	PaymentStatusPaid    = "paid"
+)
+
+// fulfilment status of a single order line
+const (
+	LineUnfulfilled        = "unfulfilled"
+	LinePartiallyShipped   = "partially_shipped"
+	LineShipped            = "shipped"
+	LinePartiallyDelivered = "partially_delivered"
+	LineDelivered          = "delivered"
+)
+
 type ProductInfo struct {
 	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
 	Quantity  int                `json:"quantity"`
+	// unit price at the time of the order
+	Price Money `json:"price"`
+	// share of the order's coupon discounts given on this line
+	Discount Money `json:"discount"`
+	// tax charged on the line, already part of Price when prices include tax
+	Tax               Money  `json:"tax"`
+	ShippedQuantity   int    `json:"shippedQuantity"`
+	DeliveredQuantity int    `json:"deliveredQuantity"`
+	FulfilmentStatus  string `json:"fulfilmentStatus"`
+}
+
+// recomputes the line status from the shipped and delivered quantities
+func (p *ProductInfo) UpdateFulfilment() {
+	switch {
+	case p.DeliveredQuantity >= p.Quantity:
+		p.FulfilmentStatus = LineDelivered
+	case p.DeliveredQuantity > 0:
+		p.FulfilmentStatus = LinePartiallyDelivered
+	case p.ShippedQuantity >= p.Quantity:
+		p.FulfilmentStatus = LineShipped
+	case p.ShippedQuantity > 0:
+		p.FulfilmentStatus = LinePartiallyShipped
+	default:
+		p.FulfilmentStatus = LineUnfulfilled
+	}
+}
+
+// TaxFor is the part of the line tax that belongs to some of its units
+func (p *ProductInfo) TaxFor(quantity int) (Money, error) {
+	if p.Quantity == 0 || p.Tax.IsZero() {
+		return ZeroMoney(p.Price.Currency), nil
+	}
+	return p.Tax.MulRat(int64(quantity), int64(p.Quantity))
+}
+
+// DiscountFor is the part of the line discount that belongs to some of its units
+func (p *ProductInfo) DiscountFor(quantity int) (Money, error) {
+	if p.Quantity == 0 || p.Discount.IsZero() {
+		return ZeroMoney(p.Price.Currency), nil
+	}
+	return p.Discount.MulRat(int64(quantity), int64(p.Quantity))
 }
 
 type Order struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id"`
-	Products  []ProductInfo      `json:"products"`
-	Amount    int                `json:"amount"`
-	UserId    primitive.ObjectID `json:"userId"`
-	Address   string             `json:"address"`
-	Status    string             `json:"status"`
-	CreatedAt time.Time          `json:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt"`
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	OrderNumber string             `json:"orderNumber"`
+	Products    []ProductInfo      `json:"products"`
+	Amount      Money              `json:"amount"`
+	// price of the products before discounts, Amount is what is charged
+	Subtotal       Money             `json:"subtotal"`
+	DiscountAmount Money             `json:"discountAmount"`
+	Discounts      []AppliedDiscount `json:"discounts"`
+	Tax            Money             `json:"tax"`
+	TaxLines       []TaxLine         `json:"taxLines"`
+	// prices and Amount already contained the tax, it was not added on top
+	TaxInclusive bool `json:"taxInclusive"`
+	// automatic promotions the order received
+	Promotions   []PromotionDiscount `json:"promotions"`
+	FreeShipping bool                `json:"freeShipping"`
+	// the delivery method chosen at checkout, ShippingCost is part of Amount
+	ShippingMethod *ShippingOption `json:"shippingMethod"`
+	ShippingCost   Money           `json:"shippingCost"`
+	// rates locked in when the order was priced from another currency
+	ExchangeRates []AppliedRate      `json:"exchangeRates"`
+	UserId        primitive.ObjectID `json:"userId"`
+	Address       string             `json:"address"`
+	// snapshots of the addresses at the time of the order
+	ShippingAddress *Address             `json:"shippingAddress"`
+	BillingAddress  *Address             `json:"billingAddress"`
+	Status          string               `json:"status"`
+	PaymentStatus   string               `json:"paymentStatus"`
+	PaidAt          *time.Time           `json:"paidAt"`
+	ReturnIDs       []primitive.ObjectID `json:"returnIds"`
+	Refunds         []Refund             `json:"refunds"`
+	RefundedAmount  Money                `json:"refundedAmount"`
+	// only filled when reading orders, shipments live in their own collection
+	Shipments []Shipment `json:"shipments,omitempty" bson:"shipments,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// FormatOrderNumber builds the customer facing order number, e.g. ORD-2026-000042
+func FormatOrderNumber(prefix string, year int, seq int64) string {
+	return fmt.Sprintf("%s-%d-%06d", prefix, year, seq)
+}
+
+// recomputes the order status from its lines
+func (o *Order) UpdateFulfilment() {
+	shipped, delivered, touched := true, true, false
+	for i := range o.Products {
+		line := &o.Products[i]
+		line.UpdateFulfilment()
+		if line.DeliveredQuantity < line.Quantity {
+			delivered = false
+		}
+		if line.ShippedQuantity < line.Quantity {
+			shipped = false
+		}
+		if line.ShippedQuantity > 0 {
+			touched = true
+		}
+	}
+
+	switch {
+	case delivered:
+		o.Status = OrderStatusDelivered
+	case shipped:
+		o.Status = OrderStatusShipped
+	case touched:
+		o.Status = OrderStatusPartiallyShipped
+	}
 }
 
 func NewOrder(order *Order) *Order {
+	for i := range (*order).Products {
+		(*order).Products[i].UpdateFulfilment()
+	}
 	new_order := Order{
-		ID:        primitive.NewObjectID(),
-		Products:  (*order).Products,
-		Amount:    (*order).Amount,
-		UserId:    (*order).UserId,
-		Address:   (*order).Address,
-		Status:    "created",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:              primitive.NewObjectID(),
+		OrderNumber:     (*order).OrderNumber,
+		Products:        (*order).Products,
+		Amount:          (*order).Amount,
+		Subtotal:        (*order).Subtotal,
+		DiscountAmount:  (*order).DiscountAmount,
+		Discounts:       (*order).Discounts,
+		Promotions:      (*order).Promotions,
+		Tax:             (*order).Tax,
+		TaxLines:        (*order).TaxLines,
+		TaxInclusive:    (*order).TaxInclusive,
+		FreeShipping:    (*order).FreeShipping,
+		ShippingMethod:  (*order).ShippingMethod,
+		ShippingCost:    (*order).ShippingCost,
+		ExchangeRates:   (*order).ExchangeRates,
+		UserId:          (*order).UserId,
+		Address:         (*order).Address,
+		ShippingAddress: (*order).ShippingAddress,
+		BillingAddress:  (*order).BillingAddress,
+		Status:          OrderStatusCreated,
+		PaymentStatus:   PaymentStatusPending,
+		ReturnIDs:       []primitive.ObjectID{},
+		Refunds:         []Refund{},
+		RefundedAmount:  ZeroMoney((*order).Amount.Currency),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 	return &new_order
 }
+
+// one page of the admin order listing
+type OrderPage struct {
+	Orders []Order `json:"orders"`
+	Page   int     `json:"page"`
+	Limit  int     `json:"limit"`
+	Total  int64   `json:"total"`
+}
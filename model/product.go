@@ -5,19 +5,38 @@ import (
 )
 
 type Product struct {
-	ID         primitive.ObjectID `bson:"_id" json:"_id"`
-	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Title      string             `json:"title"`
-	Desc       string             `json:"desc"`
-	Img        string             `json:"img"`
-	Categories []string           `json:"categories"`
-	Size       []string           `json:"size"`
-	Color      []string           `json:"color"`
-	Price      int                `json:"price"`
-	InStock    bool               `json:"instock"`
+	ID     primitive.ObjectID `bson:"_id" json:"_id"`
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	SKU    string             `json:"sku" bson:"sku,omitempty"`
+	Title  string             `json:"title"`
+	Desc   string             `json:"desc"`
+	// the first of Images once images are uploaded
+	Img        string   `json:"img"`
+	Categories []string `json:"categories"`
+	Size       []string `json:"size"`
+	Color      []string `json:"color"`
+	Price      Money    `json:"price"`
+	// explicit prices in other currencies, anything else is converted from Price
+	Prices []Money `json:"prices"`
+	// Price shown to the customer in their currency, never stored
+	DisplayPrice *Money `json:"displayPrice,omitempty" bson:"-"`
+	InStock      bool   `json:"instock"`
+	// units left to sell, orders take from it and InStock follows it. nil
+	// when the stock isn't counted and InStock alone decides.
+	Stock *int `json:"stock" bson:"stock,omitempty"`
+	// which tax rates apply, see TaxRate
+	TaxCategory string `json:"taxCategory"`
+	// packed weight and size, used to price shipping
+	WeightGrams int         `json:"weightGrams"`
+	Dimensions  *Dimensions `json:"dimensions"`
+	// kept up to date by the reviews, left out of product updates so they
+	// can't overwrite a review posted at the same time
+	Rating *RatingSummary `json:"rating" bson:"rating,omitempty"`
+	// uploaded images in display order, also left out of product updates
+	Images []ProductImage `json:"images" bson:"images,omitempty"`
 }
 
-func NewProduct(title *string, description *string, image *string, categories *[]string, size *[]string, color *[]string, price *int, inStock *bool, userId *primitive.ObjectID) *Product {
+func NewProduct(title *string, description *string, image *string, categories *[]string, size *[]string, color *[]string, price *Money, inStock *bool, userId *primitive.ObjectID) *Product {
 	return &Product{
 		ID:         primitive.NewObjectID(),
 		Title:      *title,
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// states of a product import job
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// at most this many row errors are kept on an import, Failed still counts
+// all of them
+const MaxImportErrors = 1000
+
+// ProductImport is a bulk import of products from a CSV or JSON Lines file,
+// run in the background. Rows with an id update that product, rows with a
+// known sku update the product with that sku, the rest create products.
+type ProductImport struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	UserId   primitive.ObjectID `json:"userId"`
+	Filename string             `json:"filename"`
+	// csv or jsonl
+	Format string `json:"format"`
+	// rows are only checked, the counts say what a real run would do
+	DryRun    bool             `json:"dryRun"`
+	Status    string           `json:"status"`
+	Total     int              `json:"total"`
+	Processed int              `json:"processed"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	Errors    []ImportRowError `json:"errors"`
+	// why the whole import stopped, e.g. the database went away
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// ImportRowError is a problem with one row of an import, the row is skipped
+type ImportRowError struct {
+	// line in the file, the CSV header is line 1
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	SKU   string `json:"sku,omitempty"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+type ProductImportPage struct {
+	Imports []ProductImport `json:"imports"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Total   int64           `json:"total"`
+}
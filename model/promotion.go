@@ -0,0 +1,190 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// promotion rule types
+const (
+	PromotionBuyXGetY = "buy_x_get_y"
+	PromotionTiered   = "tiered"
+	PromotionBundle   = "bundle"
+)
+
+// PromotionTier gives a discount once the eligible spend reaches MinSpend
+type PromotionTier struct {
+	MinSpend   Money  `json:"minSpend"`
+	PercentOff int    `json:"percentOff"`
+	AmountOff  *Money `json:"amountOff"`
+}
+
+// Promotion is a discount applied automatically to every cart that qualifies
+type Promotion struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Type        string             `json:"type"`
+	// higher priorities are evaluated first
+	Priority int `json:"priority"`
+	// an exclusive promotion only applies when no other promotion did, and
+	// stops everything after it
+	Exclusive bool       `json:"exclusive"`
+	Active    bool       `json:"active"`
+	StartsAt  *time.Time `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt"`
+	// when set, only these products or categories take part
+	ProductIDs []primitive.ObjectID `json:"productIds"`
+	Categories []string             `json:"categories"`
+	// buy x get y: every BuyQuantity units give GetQuantity of the cheapest
+	// units at GetPercentOff (100 is free)
+	BuyQuantity   int `json:"buyQuantity"`
+	GetQuantity   int `json:"getQuantity"`
+	GetPercentOff int `json:"getPercentOff"`
+	// tiered: the highest tier reached applies
+	Tiers []PromotionTier `json:"tiers"`
+	// bundle: one of each product for BundlePrice, or BundlePercentOff
+	BundleProductIDs []primitive.ObjectID `json:"bundleProductIds"`
+	BundlePrice      *Money               `json:"bundlePrice"`
+	BundlePercentOff int                  `json:"bundlePercentOff"`
+	CreatedAt        time.Time            `json:"createdAt"`
+	UpdatedAt        time.Time            `json:"updatedAt"`
+}
+
+func NewPromotion(promotion *Promotion) *Promotion {
+	newPromotion := *promotion
+	newPromotion.ID = primitive.NewObjectID()
+	if newPromotion.ProductIDs == nil {
+		newPromotion.ProductIDs = []primitive.ObjectID{}
+	}
+	if newPromotion.Categories == nil {
+		newPromotion.Categories = []string{}
+	}
+	if newPromotion.Tiers == nil {
+		newPromotion.Tiers = []PromotionTier{}
+	}
+	if newPromotion.BundleProductIDs == nil {
+		newPromotion.BundleProductIDs = []primitive.ObjectID{}
+	}
+	newPromotion.CreatedAt = time.Now()
+	newPromotion.UpdatedAt = time.Now()
+	return &newPromotion
+}
+
+// Validate checks the rule definition
+func (p *Promotion) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch p.Type {
+	case PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return fmt.Errorf("buyQuantity and getQuantity must be at least 1")
+		}
+		if p.GetPercentOff < 1 || p.GetPercentOff > 100 {
+			return fmt.Errorf("getPercentOff must be between 1 and 100")
+		}
+	case PromotionTiered:
+		if len(p.Tiers) == 0 {
+			return fmt.Errorf("a tiered promotion needs at least one tier")
+		}
+		for _, tier := range p.Tiers {
+			if !IsValidCurrency(tier.MinSpend.Currency) || tier.MinSpend.IsNegative() {
+				return fmt.Errorf("tier minSpend must be a positive amount in a supported currency")
+			}
+			hasAmount := tier.AmountOff != nil
+			if hasAmount == (tier.PercentOff != 0) {
+				return fmt.Errorf("a tier needs either percentOff or amountOff")
+			}
+			if tier.PercentOff < 0 || tier.PercentOff > 100 {
+				return fmt.Errorf("tier percentOff must be between 1 and 100")
+			}
+			if hasAmount && (!IsValidCurrency(tier.AmountOff.Currency) || tier.AmountOff.Amount <= 0) {
+				return fmt.Errorf("tier amountOff must be a positive amount in a supported currency")
+			}
+		}
+	case PromotionBundle:
+		if len(p.BundleProductIDs) < 2 {
+			return fmt.Errorf("a bundle needs at least two products")
+		}
+		if (p.BundlePrice != nil) == (p.BundlePercentOff != 0) {
+			return fmt.Errorf("a bundle needs either bundlePrice or bundlePercentOff")
+		}
+		if p.BundlePercentOff < 0 || p.BundlePercentOff > 100 {
+			return fmt.Errorf("bundlePercentOff must be between 1 and 100")
+		}
+		if p.BundlePrice != nil && (!IsValidCurrency(p.BundlePrice.Currency) || p.BundlePrice.IsNegative()) {
+			return fmt.Errorf("bundlePrice must be a positive amount in a supported currency")
+		}
+	default:
+		return fmt.Errorf("unknown promotion type %q", p.Type)
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+	return nil
+}
+
+// IsValidAt reports whether the promotion runs at the given time
+func (p *Promotion) IsValidAt(now time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Applies reports whether a product takes part in the promotion
+func (p *Promotion) Applies(productID primitive.ObjectID, categories []string) bool {
+	return inScope(p.ProductIDs, p.Categories, productID, categories)
+}
+
+// PromotionDiscount explains a discount a promotion gave
+type PromotionDiscount struct {
+	PromotionID primitive.ObjectID   `json:"promotionId"`
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	Amount      Money                `json:"amount"`
+	Explanation string               `json:"explanation"`
+	ProductIDs  []primitive.ObjectID `json:"productIds"`
+}
+
+// PromotionSkip explains why a promotion did not apply
+type PromotionSkip struct {
+	PromotionID primitive.ObjectID `json:"promotionId"`
+	Name        string             `json:"name"`
+	Reason      string             `json:"reason"`
+}
+
+// PromotionResult is the outcome of running the promotions over a cart
+type PromotionResult struct {
+	Currency string `json:"currency"`
+	Subtotal Money  `json:"subtotal"`
+	Discount Money  `json:"discount"`
+	Tax      Money  `json:"tax"`
+	// Tax is part of the prices instead of added to the total
+	TaxInclusive bool                `json:"taxInclusive"`
+	TaxLines     []TaxLine           `json:"taxLines"`
+	Total        Money               `json:"total"`
+	Applied      []PromotionDiscount `json:"applied"`
+	Skipped      []PromotionSkip     `json:"skipped"`
+	Coupons      []AppliedDiscount   `json:"coupons"`
+	Lines        []PromotionLine     `json:"lines"`
+}
+
+// PromotionLine is a cart line with the discounts it received
+type PromotionLine struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	Quantity  int                `json:"quantity"`
+	UnitPrice Money              `json:"unitPrice"`
+	Total     Money              `json:"total"`
+	Discount  Money              `json:"discount"`
+	Tax       Money              `json:"tax"`
+}
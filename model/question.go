@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// who answered a question, shown next to the answer
+const (
+	AnswerByAdmin         = "admin"
+	AnswerBySeller        = "seller"
+	AnswerByVerifiedBuyer = "verified_buyer"
+)
+
+// Question is a shopper asking about a product. Questions and answers are
+// screened and moderated like reviews and use the same statuses.
+type Question struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ProductID primitive.ObjectID `json:"productId"`
+	UserId    primitive.ObjectID `json:"userId"`
+	Author    string             `json:"author"`
+	Body      string             `json:"body"`
+	Status    string             `json:"status"`
+	// why the question was held for a moderator
+	HoldReasons []string `json:"holdReasons"`
+	// published answers
+	AnswerCount int `json:"answerCount"`
+	// the last moderator decision
+	ModeratedBy    *primitive.ObjectID `json:"moderatedBy"`
+	ModeratedAt    *time.Time          `json:"moderatedAt"`
+	ModerationNote string              `json:"moderationNote"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+	// the published answers, most helpful first, filled in on listings
+	Answers []Answer `json:"answers,omitempty" bson:"-"`
+}
+
+func (q *Question) Published() bool {
+	return q.Status == ReviewApproved
+}
+
+// Answer to a question, given by an admin, the seller of the product or a
+// customer who received it
+type Answer struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	QuestionID primitive.ObjectID `json:"questionId"`
+	ProductID  primitive.ObjectID `json:"productId"`
+	UserId     primitive.ObjectID `json:"userId"`
+	Author     string             `json:"author"`
+	// admin, seller or verified_buyer
+	AnsweredAs  string   `json:"answeredAs"`
+	Body        string   `json:"body"`
+	Status      string   `json:"status"`
+	HoldReasons []string `json:"holdReasons"`
+	// number of customers who found it helpful
+	Helpful        int                 `json:"helpful"`
+	ModeratedBy    *primitive.ObjectID `json:"moderatedBy"`
+	ModeratedAt    *time.Time          `json:"moderatedAt"`
+	ModerationNote string              `json:"moderationNote"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+}
+
+func (a *Answer) Published() bool {
+	return a.Status == ReviewApproved
+}
+
+// AnswerVote marks an answer as helpful, once per customer and answer
+type AnswerVote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	AnswerID  primitive.ObjectID `json:"answerId"`
+	UserId    primitive.ObjectID `json:"userId"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+type QuestionPage struct {
+	Questions []Question `json:"questions"`
+	Page      int        `json:"page"`
+	Limit     int        `json:"limit"`
+	Total     int64      `json:"total"`
+}
+
+type AnswerPage struct {
+	Answers []Answer `json:"answers"`
+	Page    int      `json:"page"`
+	Limit   int      `json:"limit"`
+	Total   int64    `json:"total"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// return request status values
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusRefunded  = "refunded"
+)
+
+type ReturnItem struct {
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Quantity  int                `json:"quantity"`
+	Reason    string             `json:"reason"`
+	// set by the admin when the item is received back
+	Restocked bool `json:"restocked"`
+}
+
+type ReturnRequest struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	OrderID      primitive.ObjectID `json:"orderId"`
+	OrderNumber  string             `json:"orderNumber"`
+	UserId       primitive.ObjectID `json:"userId"`
+	Items        []ReturnItem       `json:"items"`
+	Status       string             `json:"status"`
+	AdminNote    string             `json:"adminNote"`
+	RefundAmount Money              `json:"refundAmount"`
+	ReceivedAt   *time.Time         `json:"receivedAt"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	UpdatedAt    time.Time          `json:"updatedAt"`
+}
+
+// Refund is recorded on the order each time money is given back
+type Refund struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ReturnID  primitive.ObjectID `json:"returnId"`
+	Amount    Money              `json:"amount"`
+	Reason    string             `json:"reason"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+func NewReturnRequest(orderId, userId primitive.ObjectID, items []ReturnItem) *ReturnRequest {
+	return &ReturnRequest{
+		ID:        primitive.NewObjectID(),
+		OrderID:   orderId,
+		UserId:    userId,
+		Items:     items,
+		Status:    ReturnStatusRequested,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// OrderReturnHistory groups every return and refund made against one order
+type OrderReturnHistory struct {
+	OrderID        primitive.ObjectID `json:"orderId"`
+	Returns        []ReturnRequest    `json:"returns"`
+	Refunds        []Refund           `json:"refunds"`
+	RefundedAmount Money              `json:"refundedAmount"`
+}
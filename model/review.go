@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// moderation statuses of a review, only approved reviews are shown and
+// counted in the rating
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+	// only in the moderation log, an admin removed the review
+	ReviewDeleted = "deleted"
+)
+
+// Review is a customer's rating of a product, one per customer and product
+type Review struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ProductID primitive.ObjectID `json:"productId"`
+	UserId    primitive.ObjectID `json:"userId"`
+	// the author's first name when the review was written
+	Author string `json:"author"`
+	// 1 to 5 stars
+	Rating int      `json:"rating"`
+	Title  string   `json:"title"`
+	Body   string   `json:"body"`
+	Images []string `json:"images"`
+	// the author has a delivered order with the product
+	VerifiedPurchase bool   `json:"verifiedPurchase"`
+	Status           string `json:"status"`
+	// why the review was held for a moderator
+	HoldReasons []string `json:"holdReasons"`
+	// reports since a moderator last looked at the review
+	ReportCount int        `json:"reportCount"`
+	ModeratedAt *time.Time `json:"moderatedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// Published reviews are listed on the product and counted in its rating
+func (r *Review) Published() bool {
+	return r.Status == ReviewApproved
+}
+
+// ReviewReport is a customer flagging a review, once per customer and review
+type ReviewReport struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	ReviewID primitive.ObjectID `json:"reviewId"`
+	UserId   primitive.ObjectID `json:"userId"`
+	// spam, offensive, off_topic, fake or other
+	Reason    string    `json:"reason"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ModerationDecision records a moderator approving or rejecting a review
+type ModerationDecision struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	ReviewID    primitive.ObjectID `json:"reviewId"`
+	ModeratorID primitive.ObjectID `json:"moderatorId"`
+	// the status before and after the decision
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Note        string    `json:"note"`
+	HoldReasons []string  `json:"holdReasons"`
+	ReportCount int       `json:"reportCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ModerationQueue is a page of reviews waiting for a moderator
+type ModerationQueue struct {
+	Reviews []Review `json:"reviews"`
+	Page    int      `json:"page"`
+	Limit   int      `json:"limit"`
+	Total   int64    `json:"total"`
+}
+
+// RatingSummary is kept on the product and updated with every review
+type RatingSummary struct {
+	Count   int64   `json:"count"`
+	Sum     int64   `json:"sum"`
+	Average float64 `json:"average"`
+	// number of reviews per star, "1" to "5"
+	Histogram map[string]int64 `json:"histogram"`
+}
+
+type ReviewPage struct {
+	Reviews []Review       `json:"reviews"`
+	Rating  *RatingSummary `json:"rating"`
+	Page    int            `json:"page"`
+	Limit   int            `json:"limit"`
+	Total   int64          `json:"total"`
+}
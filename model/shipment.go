@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shipment status values
+const (
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+)
+
+type ShipmentLine struct {
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Quantity  int                `json:"quantity"`
+}
+
+type Shipment struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	OrderID        primitive.ObjectID `json:"orderId"`
+	OrderNumber    string             `json:"orderNumber"`
+	Lines          []ShipmentLine     `json:"lines"`
+	Carrier        string             `json:"carrier"`
+	TrackingNumber string             `json:"trackingNumber"`
+	Status         string             `json:"status"`
+	ShippedAt      time.Time          `json:"shippedAt"`
+	DeliveredAt    *time.Time         `json:"deliveredAt"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
+
+func NewShipment(orderId primitive.ObjectID, lines []ShipmentLine, carrier, trackingNumber string, shippedAt time.Time) *Shipment {
+	return &Shipment{
+		ID:             primitive.NewObjectID(),
+		OrderID:        orderId,
+		Lines:          lines,
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		Status:         ShipmentStatusShipped,
+		ShippedAt:      shippedAt,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
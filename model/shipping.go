@@ -0,0 +1,277 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shipping rate types
+const (
+	ShippingRateFlat           = "flat"
+	ShippingRateWeight         = "weight"
+	ShippingRatePriceThreshold = "price_threshold"
+)
+
+// ShippingZone groups the destinations that share the same shipping methods
+type ShippingZone struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Name      string             `json:"name"`
+	Countries []string           `json:"countries"`
+	// when set, only postal codes starting with one of these are in the zone
+	PostalPrefixes []string  `json:"postalPrefixes"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func NewShippingZone(name string, countries, postalPrefixes []string) *ShippingZone {
+	zone := &ShippingZone{
+		ID:             primitive.NewObjectID(),
+		Name:           strings.TrimSpace(name),
+		Countries:      countries,
+		PostalPrefixes: postalPrefixes,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	zone.Normalize()
+	return zone
+}
+
+// Normalize upper-cases the countries and strips spaces from the prefixes
+func (z *ShippingZone) Normalize() {
+	countries := make([]string, 0, len(z.Countries))
+	for _, country := range z.Countries {
+		countries = append(countries, strings.ToUpper(strings.TrimSpace(country)))
+	}
+	z.Countries = countries
+	prefixes := make([]string, 0, len(z.PostalPrefixes))
+	for _, prefix := range z.PostalPrefixes {
+		if prefix = normalizePostalCode(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	z.PostalPrefixes = prefixes
+}
+
+func (z *ShippingZone) Validate() error {
+	if z.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(z.Countries) == 0 {
+		return fmt.Errorf("a zone needs at least one country")
+	}
+	for _, country := range z.Countries {
+		if !countryCodePattern.MatchString(country) {
+			return fmt.Errorf("country %q must be an ISO 3166-1 alpha-2 code", country)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the address is in the zone
+func (z *ShippingZone) Matches(address *Address) bool {
+	inCountry := false
+	for _, country := range z.Countries {
+		if country == address.Country {
+			inCountry = true
+			break
+		}
+	}
+	if !inCountry {
+		return false
+	}
+	if len(z.PostalPrefixes) == 0 {
+		return true
+	}
+	postalCode := normalizePostalCode(address.PostalCode)
+	for _, prefix := range z.PostalPrefixes {
+		if strings.HasPrefix(postalCode, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizePostalCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// WeightRate is the price of parcels up to a weight
+type WeightRate struct {
+	UpToGrams int   `json:"upToGrams"`
+	Price     Money `json:"price"`
+}
+
+// ThresholdRate is the price once the order is worth at least MinSubtotal
+type ThresholdRate struct {
+	MinSubtotal Money `json:"minSubtotal"`
+	Price       Money `json:"price"`
+}
+
+// ShippingMethod is a way of delivering to a zone and how it is priced. All
+// of its amounts are in Currency and converted for orders in another one.
+type ShippingMethod struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	ZoneID      primitive.ObjectID `json:"zoneId"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Type        string             `json:"type"`
+	Currency    string             `json:"currency"`
+	// flat: the same price for every order
+	FlatRate *Money `json:"flatRate"`
+	// weight: the first bracket the parcel fits in, heavier parcels can't use
+	// the method
+	WeightRates []WeightRate `json:"weightRates"`
+	// price threshold: the highest threshold the order reaches, e.g. free
+	// shipping over 50.00
+	PriceThresholds []ThresholdRate `json:"priceThresholds"`
+	// delivery estimate in days
+	MinDays   int       `json:"minDays"`
+	MaxDays   int       `json:"maxDays"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func NewShippingMethod(method *ShippingMethod) *ShippingMethod {
+	newMethod := *method
+	newMethod.ID = primitive.NewObjectID()
+	newMethod.Currency = strings.ToUpper(method.Currency)
+	if newMethod.WeightRates == nil {
+		newMethod.WeightRates = []WeightRate{}
+	}
+	if newMethod.PriceThresholds == nil {
+		newMethod.PriceThresholds = []ThresholdRate{}
+	}
+	newMethod.SortRates()
+	newMethod.CreatedAt = time.Now()
+	newMethod.UpdatedAt = time.Now()
+	return &newMethod
+}
+
+// SortRates orders the weight brackets and thresholds from the lowest
+func (m *ShippingMethod) SortRates() {
+	sort.SliceStable(m.WeightRates, func(i, j int) bool {
+		return m.WeightRates[i].UpToGrams < m.WeightRates[j].UpToGrams
+	})
+	sort.SliceStable(m.PriceThresholds, func(i, j int) bool {
+		return m.PriceThresholds[i].MinSubtotal.Amount < m.PriceThresholds[j].MinSubtotal.Amount
+	})
+}
+
+// Validate checks the rate definition
+func (m *ShippingMethod) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !IsValidCurrency(m.Currency) {
+		return fmt.Errorf("unsupported currency %q", m.Currency)
+	}
+	price := func(p Money, field string) error {
+		if p.Currency != m.Currency || p.IsNegative() {
+			return fmt.Errorf("%s must be a positive amount in %s", field, m.Currency)
+		}
+		return nil
+	}
+	switch m.Type {
+	case ShippingRateFlat:
+		if m.FlatRate == nil {
+			return fmt.Errorf("a flat method needs flatRate")
+		}
+		if err := price(*m.FlatRate, "flatRate"); err != nil {
+			return err
+		}
+	case ShippingRateWeight:
+		if len(m.WeightRates) == 0 {
+			return fmt.Errorf("a weight method needs at least one weight rate")
+		}
+		for _, rate := range m.WeightRates {
+			if rate.UpToGrams < 1 {
+				return fmt.Errorf("upToGrams must be at least 1")
+			}
+			if err := price(rate.Price, "weight rate price"); err != nil {
+				return err
+			}
+		}
+	case ShippingRatePriceThreshold:
+		if len(m.PriceThresholds) == 0 {
+			return fmt.Errorf("a price threshold method needs at least one threshold")
+		}
+		for _, rate := range m.PriceThresholds {
+			if err := price(rate.MinSubtotal, "minSubtotal"); err != nil {
+				return err
+			}
+			if err := price(rate.Price, "threshold price"); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown shipping rate type %q", m.Type)
+	}
+	if m.MinDays < 0 || m.MaxDays < m.MinDays {
+		return fmt.Errorf("maxDays can't be less than minDays")
+	}
+	return nil
+}
+
+// Cost prices a parcel in the method's currency. The subtotal must be in the
+// same currency. It reports false when the method can't take the parcel.
+func (m *ShippingMethod) Cost(weightGrams int, subtotal Money) (Money, bool) {
+	switch m.Type {
+	case ShippingRateFlat:
+		return *m.FlatRate, true
+	case ShippingRateWeight:
+		for _, rate := range m.WeightRates {
+			if weightGrams <= rate.UpToGrams {
+				return rate.Price, true
+			}
+		}
+	case ShippingRatePriceThreshold:
+		found := false
+		var cost Money
+		for _, rate := range m.PriceThresholds {
+			if subtotal.Amount >= rate.MinSubtotal.Amount {
+				cost, found = rate.Price, true
+			}
+		}
+		return cost, found
+	}
+	return Money{}, false
+}
+
+// ShippingOption is a method available for an address with its cost in the
+// cart's currency
+type ShippingOption struct {
+	MethodID    primitive.ObjectID `json:"methodId"`
+	ZoneID      primitive.ObjectID `json:"zoneId"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Cost        Money              `json:"cost"`
+	MinDays     int                `json:"minDays"`
+	MaxDays     int                `json:"maxDays"`
+}
+
+// Dimensions of a packed product in millimetres
+type Dimensions struct {
+	LengthMm int `json:"lengthMm"`
+	WidthMm  int `json:"widthMm"`
+	HeightMm int `json:"heightMm"`
+}
+
+// volumetric weight divisor, 5000 cm³ per kg is 5000 mm³ per gram
+const volumetricDivisor = 5000
+
+// ShippingWeight is what a unit weighs for shipping: its weight, or the space
+// it takes up when that is more
+func (p *Product) ShippingWeight() int {
+	weight := p.WeightGrams
+	if d := p.Dimensions; d != nil {
+		if volumetric := d.LengthMm * d.WidthMm * d.HeightMm / volumetricDivisor; volumetric > weight {
+			weight = volumetric
+		}
+	}
+	return weight
+}
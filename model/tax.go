@@ -0,0 +1,63 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultTaxCategory is used for products without a tax category
+const DefaultTaxCategory = "standard"
+
+// TaxRate is a rate of a country, or of a region within it, for one tax
+// category. Country and region rates add up, e.g. GST and PST in Canada.
+type TaxRate struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Country  string             `json:"country"`
+	Region   string             `json:"region"`
+	Category string             `json:"category"`
+	Name     string             `json:"name"`
+	// rate in basis points, 1900 is 19%
+	RateBasisPoints int64     `json:"rateBasisPoints"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func NewTaxRate(country, region, category, name string, basisPoints int64) *TaxRate {
+	return &TaxRate{
+		ID:              primitive.NewObjectID(),
+		Country:         strings.ToUpper(strings.TrimSpace(country)),
+		Region:          strings.ToUpper(strings.TrimSpace(region)),
+		Category:        NormalizeTaxCategory(category),
+		Name:            strings.TrimSpace(name),
+		RateBasisPoints: basisPoints,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+}
+
+func NormalizeTaxCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		return DefaultTaxCategory
+	}
+	return category
+}
+
+// AppliedTax is one rate charged on a line
+type AppliedTax struct {
+	Name            string `json:"name"`
+	RateBasisPoints int64  `json:"rateBasisPoints"`
+	Amount          Money  `json:"amount"`
+}
+
+// TaxLine is the tax breakdown of a cart or order line
+type TaxLine struct {
+	ProductID   primitive.ObjectID `json:"product_id" bson:"product_id"`
+	TaxCategory string             `json:"taxCategory"`
+	// amount the tax is computed on, after discounts and without tax
+	Taxable Money        `json:"taxable"`
+	Tax     Money        `json:"tax"`
+	Rates   []AppliedTax `json:"rates"`
+}
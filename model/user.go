@@ -26,8 +26,10 @@ type User struct {
 	ProfileImage *string            `json:"profileImage"`
 	Password     string             `json:"password"`
 	IsAdmin      bool               `json:"isAdmin"`
-	CreatedAt    time.Time          `json:"createdAt"`
-	UpdatedAt    time.Time          `json:"UpdatedAt"`
+	// preferred currency, ISO 4217
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
 }
 
 func NewUser(username, firstName, lastName, email, gender, profileImage, password *string) *User {
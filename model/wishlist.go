@@ -0,0 +1,89 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// wishlist kinds, every user has at most one saved for later list
+const (
+	WishlistKindWishlist      = "wishlist"
+	WishlistKindSavedForLater = "saved_for_later"
+)
+
+type WishlistItem struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	// how many to put back in the cart when it is moved there
+	Quantity int       `json:"quantity"`
+	AddedAt  time.Time `json:"addedAt"`
+	// the product as it is now, never stored
+	Product *Product `json:"product,omitempty" bson:"-"`
+}
+
+// Wishlist is a named list of products a user parks outside the cart
+type Wishlist struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	UserId primitive.ObjectID `json:"userId"`
+	Name   string             `json:"name"`
+	Kind   string             `json:"kind"`
+	Items  []WishlistItem     `json:"items"`
+	// anyone with the token can view a public list
+	Public     bool      `json:"public"`
+	ShareToken *string   `json:"shareToken"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func NewWishlist(userId primitive.ObjectID, name, kind string) *Wishlist {
+	return &Wishlist{
+		ID:        primitive.NewObjectID(),
+		UserId:    userId,
+		Name:      strings.TrimSpace(name),
+		Kind:      kind,
+		Items:     []WishlistItem{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// Add puts a product on the list, a product already there gets the new
+// quantity
+func (w *Wishlist) Add(productId primitive.ObjectID, quantity int) {
+	if quantity < 1 {
+		quantity = 1
+	}
+	for i := range w.Items {
+		if w.Items[i].ProductID == productId {
+			w.Items[i].Quantity = quantity
+			return
+		}
+	}
+	w.Items = append(w.Items, WishlistItem{
+		ProductID: productId,
+		Quantity:  quantity,
+		AddedAt:   time.Now(),
+	})
+}
+
+// Remove takes a product off the list and reports whether it was there
+func (w *Wishlist) Remove(productId primitive.ObjectID) (WishlistItem, bool) {
+	for i, item := range w.Items {
+		if item.ProductID == productId {
+			w.Items = append(w.Items[:i], w.Items[i+1:]...)
+			return item, true
+		}
+	}
+	return WishlistItem{}, false
+}
+
+// ProductWishlistCount is how many lists a product is on, for merchandising
+type ProductWishlistCount struct {
+	ProductID     primitive.ObjectID `json:"productId" bson:"_id"`
+	Title         string             `json:"title"`
+	Wishlists     int64              `json:"wishlists"`
+	SavedForLater int64              `json:"savedForLater"`
+	// distinct users with the product on any list
+	Users int64 `json:"users"`
+}
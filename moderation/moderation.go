@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// links with a scheme or www, and bare domains such as shop.example.com
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+(\.[a-z0-9-]+)*\.(com|net|org|info|biz|io|co|ru|cn|xyz|shop|store|online|site|top|link|click)\b`)
+
+// Policy decides which texts customers post are held for a moderator before
+// anyone else sees them
+type Policy struct {
+	// words and phrases, matched as whole words regardless of case
+	bannedWords []string
+	holdLinks   bool
+}
+
+func NewPolicy(bannedWords []string, holdLinks bool) *Policy {
+	words := []string{}
+	for _, word := range bannedWords {
+		if word = normalize(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return &Policy{
+		bannedWords: words,
+		holdLinks:   holdLinks,
+	}
+}
+
+// Check returns why the texts have to be held, nothing when they can be
+// published
+func (p *Policy) Check(texts ...string) []string {
+	reasons := []string{}
+	seen := map[string]bool{}
+	link := false
+	for _, text := range texts {
+		words := " " + normalize(text) + " "
+		for _, word := range p.bannedWords {
+			if !seen[word] && strings.Contains(words, " "+word+" ") {
+				seen[word] = true
+				reasons = append(reasons, fmt.Sprintf("contains the banned word %q", word))
+			}
+		}
+		if p.holdLinks && !link && linkPattern.MatchString(text) {
+			link = true
+			reasons = append(reasons, "contains a link")
+		}
+	}
+	return reasons
+}
+
+// lower case words separated by single spaces
+func normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
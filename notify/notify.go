@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Message is a notification for a single user
+type Message struct {
+	// what the notification is about, e.g. "abandoned_cart"
+	Kind    string            `json:"kind"`
+	UserID  string            `json:"userId"`
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	Body    string            `json:"body"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers messages to users over some channel (email, push, ...)
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New picks the webhook notifier when a URL is configured and the log
+// notifier otherwise
+func New(webhookURL string) Notifier {
+	if webhookURL != "" {
+		return NewWebhookNotifier(webhookURL)
+	}
+	return &LogNotifier{}
+}
+
+// LogNotifier only prints the messages, for development
+type LogNotifier struct{}
+
+func (l *LogNotifier) Send(ctx context.Context, msg *Message) error {
+	fmt.Printf("notification %s to %s: %s\n", msg.Kind, msg.To, msg.Subject)
+	return nil
+}
+
+// WebhookNotifier posts every message as JSON to a URL, e.g. the endpoint of
+// an email or push provider
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *WebhookNotifier) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", res.Status)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package request
 
 import (
+	"time"
+
 	"github.com/souvikjs01/go-ecommerce/model"
 )
 
@@ -24,37 +26,437 @@ type UpdateRequest struct {
 	FirstName    *string `json:"firstName"`
 	LastName     *string `json:"lastName"`
 	ProfileImage *string `json:"profileImage"`
+	Currency     *string `json:"currency"`
 }
 
 type ProductPayload struct {
-	Title      string   `json:"title" binding:"required"`
-	Desc       string   `json:"desc" binding:"required"`
-	Img        string   `json:"img" binding:"required"`
-	Categories []string `json:"categories" binding:"required"`
-	Size       []string `json:"size" binding:"required"`
-	Color      []string `json:"color" binding:"required"`
-	Price      int      `json:"price" binding:"required"`
-	InStock    bool     `json:"instock" binding:"required"`
+	SKU         string             `json:"sku" binding:"max=64"`
+	Title       string             `json:"title" binding:"required"`
+	Desc        string             `json:"desc" binding:"required"`
+	Img         string             `json:"img"`
+	Categories  []string           `json:"categories" binding:"required"`
+	Size        []string           `json:"size" binding:"required"`
+	Color       []string           `json:"color" binding:"required"`
+	Price       model.Money        `json:"price" binding:"required"`
+	Prices      []model.Money      `json:"prices"`
+	TaxCategory string             `json:"taxCategory" binding:"max=50"`
+	WeightGrams int                `json:"weightGrams" binding:"min=0"`
+	Dimensions  *DimensionsPayload `json:"dimensions"`
+	InStock     bool               `json:"instock" binding:"required"`
+	// units left to sell, leave out for products whose stock isn't counted
+	Stock *int `json:"stock" binding:"omitempty,min=0"`
+}
+
+type DimensionsPayload struct {
+	LengthMm int `json:"lengthMm" binding:"required,min=1"`
+	WidthMm  int `json:"widthMm" binding:"required,min=1"`
+	HeightMm int `json:"heightMm" binding:"required,min=1"`
 }
 
 type UpdateProductPayload struct {
-	Title      *string   `json:"title"`
-	Desc       *string   `json:"desc"`
-	Img        *string   `json:"img"`
-	Categories *[]string `json:"categories"`
-	Size       *[]string `json:"size"`
-	Color      *[]string `json:"color"`
-	Price      *int      `json:"price"`
-	InStock    *bool     `json:"instock"`
+	SKU         *string            `json:"sku" binding:"omitempty,max=64"`
+	Title       *string            `json:"title"`
+	Desc        *string            `json:"desc"`
+	Img         *string            `json:"img"`
+	Categories  *[]string          `json:"categories"`
+	Size        *[]string          `json:"size"`
+	Color       *[]string          `json:"color"`
+	Price       *model.Money       `json:"price"`
+	Prices      *[]model.Money     `json:"prices"`
+	TaxCategory *string            `json:"taxCategory" binding:"omitempty,max=50"`
+	WeightGrams *int               `json:"weightGrams" binding:"omitempty,min=0"`
+	Dimensions  *DimensionsPayload `json:"dimensions"`
+	InStock     *bool              `json:"instock"`
+	Stock       *int               `json:"stock" binding:"omitempty,min=0"`
 }
 
 type CreateOrderPayload struct {
 	Products []model.ProductInfo `json:"products" binding:"required"`
-	Address  string              `json:"address" binding:"required,min=4,max=20"`
-	Status   string              `json:"status" binding:"required"`
+	// free-text address, only used when no structured address is given
+	Address string `json:"address" binding:"omitempty,min=4,max=500"`
+	// saved addresses from the address book
+	AddressID        string `json:"address_id"`
+	BillingAddressID string `json:"billing_address_id"`
+	// one-off address that is not saved to the address book
+	ShippingAddress *AddressPayload `json:"shipping_address"`
+	// currency to charge in, defaults to the X-Currency header or the profile
+	Currency string `json:"currency"`
+	// coupons to redeem, defaults to the codes applied to the cart
+	CouponCodes []string `json:"coupon_codes"`
+	// one of the methods listed for the shipping address, required when the
+	// address has any
+	ShippingMethodID string `json:"shipping_method_id"`
+	Status           string `json:"status" binding:"required"`
 }
 
 type AddToCartPayload struct {
 	ProductID string `json:"product_id" binding:"required"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
 }
+
+type WishlistPayload struct {
+	Name   string `json:"name" binding:"required,min=1,max=100"`
+	Public bool   `json:"public"`
+}
+
+type UpdateWishlistPayload struct {
+	Name   *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Public *bool   `json:"public"`
+}
+
+type WishlistItemPayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"omitempty,min=1"`
+}
+
+// size and color pick a variant, both are optional
+type ProductAlertPayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Type      string `json:"type" binding:"required,oneof=back_in_stock price_drop"`
+	Size      string `json:"size"`
+	Color     string `json:"color"`
+}
+
+type ReviewPayload struct {
+	ProductID string   `json:"product_id" binding:"required"`
+	Rating    int      `json:"rating" binding:"required,min=1,max=5"`
+	Title     string   `json:"title" binding:"required,min=1,max=150"`
+	Body      string   `json:"body" binding:"required,min=1,max=5000"`
+	Images    []string `json:"images" binding:"max=5,dive,url"`
+}
+
+type UpdateReviewPayload struct {
+	Rating *int      `json:"rating" binding:"omitempty,min=1,max=5"`
+	Title  *string   `json:"title" binding:"omitempty,min=1,max=150"`
+	Body   *string   `json:"body" binding:"omitempty,min=1,max=5000"`
+	Images *[]string `json:"images" binding:"omitempty,max=5,dive,url"`
+}
+
+// query parameters of the review listing of a product
+type ReviewQuery struct {
+	Sort string `form:"sort" binding:"omitempty,oneof=newest oldest highest lowest"`
+	// only reviews with this many stars
+	Rating   int  `form:"rating" binding:"omitempty,min=1,max=5"`
+	Verified bool `form:"verified"`
+	Page     int  `form:"page" binding:"omitempty,min=1"`
+	Limit    int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ReportReviewPayload struct {
+	Reason  string `json:"reason" binding:"required,oneof=spam offensive off_topic fake other"`
+	Comment string `json:"comment" binding:"max=1000"`
+}
+
+// ModeratePayload approves or rejects a review, question or answer
+type ModeratePayload struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+// query parameters of the moderation queue, pending reviews by default
+type ModerationQueueQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	// only reviews reported at least this often
+	MinReports int `form:"min_reports" binding:"omitempty,min=1"`
+	Page       int `form:"page" binding:"omitempty,min=1"`
+	Limit      int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type QuestionPayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Body      string `json:"body" binding:"required,min=3,max=1000"`
+}
+
+type AnswerPayload struct {
+	Body string `json:"body" binding:"required,min=1,max=2000"`
+}
+
+// query parameters of the questions of a product
+type QuestionQuery struct {
+	Sort  string `form:"sort" binding:"omitempty,oneof=newest oldest most_answered"`
+	Page  int    `form:"page" binding:"omitempty,min=1"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// query parameters of the question and answer moderation queues, pending by
+// default
+type QAQueueQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// the slug is made from the name when left out, a category without parent
+// is a root
+type CategoryPayload struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Slug        string `json:"slug" binding:"max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	ParentID    string `json:"parent_id"`
+	Position    int    `json:"position"`
+}
+
+// an empty parent_id moves the category to the root
+type UpdateCategoryPayload struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Slug        *string `json:"slug" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	ParentID    *string `json:"parent_id"`
+	Position    *int    `json:"position"`
+}
+
+type CategoryProductsQuery struct {
+	Page  int `form:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ApplyCouponPayload struct {
+	Code string `json:"code" binding:"required,min=3,max=32"`
+}
+
+type CouponPayload struct {
+	Code             string       `json:"code" binding:"required,min=3,max=32"`
+	Type             string       `json:"type" binding:"required,oneof=percentage fixed_amount free_shipping"`
+	PercentOff       int          `json:"percent_off" binding:"min=0,max=100"`
+	AmountOff        model.Money  `json:"amount_off"`
+	MinSpend         *model.Money `json:"min_spend"`
+	ProductIDs       []string     `json:"product_ids"`
+	Categories       []string     `json:"categories"`
+	StartsAt         *time.Time   `json:"starts_at"`
+	EndsAt           *time.Time   `json:"ends_at"`
+	UsageLimit       int          `json:"usage_limit" binding:"min=0"`
+	PerCustomerLimit int          `json:"per_customer_limit" binding:"min=0"`
+	Stackable        bool         `json:"stackable"`
+	Active           *bool        `json:"active"`
+}
+
+type UpdateCouponPayload struct {
+	MinSpend         *model.Money `json:"min_spend"`
+	StartsAt         *time.Time   `json:"starts_at"`
+	EndsAt           *time.Time   `json:"ends_at"`
+	UsageLimit       *int         `json:"usage_limit" binding:"omitempty,min=0"`
+	PerCustomerLimit *int         `json:"per_customer_limit" binding:"omitempty,min=0"`
+	Stackable        *bool        `json:"stackable"`
+	Active           *bool        `json:"active"`
+}
+
+type PromotionTierPayload struct {
+	MinSpend   model.Money  `json:"min_spend" binding:"required"`
+	PercentOff int          `json:"percent_off" binding:"min=0,max=100"`
+	AmountOff  *model.Money `json:"amount_off"`
+}
+
+type PromotionPayload struct {
+	Name             string                 `json:"name" binding:"required,min=3,max=100"`
+	Description      string                 `json:"description" binding:"max=500"`
+	Type             string                 `json:"type" binding:"required,oneof=buy_x_get_y tiered bundle"`
+	Priority         int                    `json:"priority"`
+	Exclusive        bool                   `json:"exclusive"`
+	Active           *bool                  `json:"active"`
+	StartsAt         *time.Time             `json:"starts_at"`
+	EndsAt           *time.Time             `json:"ends_at"`
+	ProductIDs       []string               `json:"product_ids"`
+	Categories       []string               `json:"categories"`
+	BuyQuantity      int                    `json:"buy_quantity" binding:"min=0"`
+	GetQuantity      int                    `json:"get_quantity" binding:"min=0"`
+	GetPercentOff    int                    `json:"get_percent_off" binding:"min=0,max=100"`
+	Tiers            []PromotionTierPayload `json:"tiers" binding:"dive"`
+	BundleProductIDs []string               `json:"bundle_product_ids"`
+	BundlePrice      *model.Money           `json:"bundle_price"`
+	BundlePercentOff int                    `json:"bundle_percent_off" binding:"min=0,max=100"`
+}
+
+type UpdatePromotionPayload struct {
+	Name        *string    `json:"name" binding:"omitempty,min=3,max=100"`
+	Description *string    `json:"description" binding:"omitempty,max=500"`
+	Priority    *int       `json:"priority"`
+	Exclusive   *bool      `json:"exclusive"`
+	Active      *bool      `json:"active"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+}
+
+// DryRunPayload previews the promotions for a saved cart, the cart of a user
+// or a list of products. Draft rules are evaluated next to the active ones.
+type DryRunPayload struct {
+	CartID      string             `json:"cart_id"`
+	UserID      string             `json:"user_id"`
+	Products    []AddToCartPayload `json:"products" binding:"dive"`
+	Currency    string             `json:"currency"`
+	CouponCodes []string           `json:"coupon_codes"`
+	Drafts      []PromotionPayload `json:"drafts" binding:"dive"`
+}
+
+type ShippingZonePayload struct {
+	Name      string   `json:"name" binding:"required,max=100"`
+	Countries []string `json:"countries" binding:"required,min=1,dive,len=2"`
+	// e.g. "SW1" or "750", matched against the start of the postal code
+	PostalPrefixes []string `json:"postal_prefixes" binding:"dive,max=10"`
+}
+
+type UpdateShippingZonePayload struct {
+	Name           *string   `json:"name" binding:"omitempty,max=100"`
+	Countries      *[]string `json:"countries" binding:"omitempty,min=1,dive,len=2"`
+	PostalPrefixes *[]string `json:"postal_prefixes" binding:"omitempty,dive,max=10"`
+}
+
+type WeightRatePayload struct {
+	UpToGrams int         `json:"up_to_grams" binding:"required,min=1"`
+	Price     model.Money `json:"price" binding:"required"`
+}
+
+type ThresholdRatePayload struct {
+	MinSubtotal model.Money `json:"min_subtotal" binding:"required"`
+	Price       model.Money `json:"price" binding:"required"`
+}
+
+type ShippingMethodPayload struct {
+	ZoneID          string                 `json:"zone_id" binding:"required"`
+	Name            string                 `json:"name" binding:"required,max=100"`
+	Description     string                 `json:"description" binding:"max=500"`
+	Type            string                 `json:"type" binding:"required,oneof=flat weight price_threshold"`
+	Currency        string                 `json:"currency" binding:"required,len=3"`
+	FlatRate        *model.Money           `json:"flat_rate"`
+	WeightRates     []WeightRatePayload    `json:"weight_rates" binding:"dive"`
+	PriceThresholds []ThresholdRatePayload `json:"price_thresholds" binding:"dive"`
+	MinDays         int                    `json:"min_days" binding:"min=0"`
+	MaxDays         int                    `json:"max_days" binding:"min=0"`
+	Active          *bool                  `json:"active"`
+}
+
+// the zone, type and currency of a method can't change
+type UpdateShippingMethodPayload struct {
+	Name            *string                 `json:"name" binding:"omitempty,max=100"`
+	Description     *string                 `json:"description" binding:"omitempty,max=500"`
+	FlatRate        *model.Money            `json:"flat_rate"`
+	WeightRates     *[]WeightRatePayload    `json:"weight_rates" binding:"omitempty,dive"`
+	PriceThresholds *[]ThresholdRatePayload `json:"price_thresholds" binding:"omitempty,dive"`
+	MinDays         *int                    `json:"min_days" binding:"omitempty,min=0"`
+	MaxDays         *int                    `json:"max_days" binding:"omitempty,min=0"`
+	Active          *bool                   `json:"active"`
+}
+
+type TaxRatePayload struct {
+	Country string `json:"country" binding:"required,len=2"`
+	Region  string `json:"region" binding:"max=10"`
+	// defaults to the standard category
+	Category string `json:"category" binding:"max=50"`
+	Name     string `json:"name" binding:"required,max=50"`
+	// 1900 is 19%
+	RateBasisPoints int64 `json:"rate_basis_points" binding:"min=0,max=10000"`
+}
+
+type ReturnItemPayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	Reason    string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type CreateReturnPayload struct {
+	OrderID string              `json:"order_id" binding:"required"`
+	Items   []ReturnItemPayload `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReviewReturnPayload struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+type ReceiveReturnPayload struct {
+	// products from the return that go back into stock
+	RestockProductIDs []string `json:"restock_product_ids"`
+}
+
+type ShipmentLinePayload struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+type CreateShipmentPayload struct {
+	OrderID        string                `json:"order_id" binding:"required"`
+	Lines          []ShipmentLinePayload `json:"lines" binding:"required,min=1,dive"`
+	Carrier        string                `json:"carrier" binding:"required"`
+	TrackingNumber string                `json:"tracking_number" binding:"required"`
+	ShippedAt      *time.Time            `json:"shipped_at"`
+}
+
+type DeliverShipmentPayload struct {
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
+
+type AddressPayload struct {
+	Name              string `json:"name" binding:"required,max=100"`
+	Line1             string `json:"line1" binding:"required,max=200"`
+	Line2             string `json:"line2" binding:"max=200"`
+	City              string `json:"city" binding:"required,max=100"`
+	Region            string `json:"region" binding:"max=100"`
+	PostalCode        string `json:"postal_code" binding:"max=20"`
+	Country           string `json:"country" binding:"required,len=2"`
+	Phone             string `json:"phone" binding:"max=20"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+type UpdateAddressPayload struct {
+	Name              *string `json:"name"`
+	Line1             *string `json:"line1"`
+	Line2             *string `json:"line2"`
+	City              *string `json:"city"`
+	Region            *string `json:"region"`
+	PostalCode        *string `json:"postal_code"`
+	Country           *string `json:"country"`
+	Phone             *string `json:"phone"`
+	IsDefaultShipping *bool   `json:"is_default_shipping"`
+	IsDefaultBilling  *bool   `json:"is_default_billing"`
+}
+
+// query parameters of the admin order listing and export
+type OrderFilterQuery struct {
+	Status      string `form:"status"`
+	OrderNumber string `form:"order_number"`
+	From        string `form:"from"`
+	To          string `form:"to"`
+	Customer    string `form:"customer"`
+	// amounts are in minor units, e.g. cents
+	MinAmount *int64 `form:"min_amount" binding:"omitempty,min=0"`
+	MaxAmount *int64 `form:"max_amount" binding:"omitempty,min=0"`
+	ProductID string `form:"product_id"`
+	SortBy    string `form:"sort_by" binding:"omitempty,oneof=createdAt updatedAt amount status"`
+	SortDir   string `form:"sort_dir" binding:"omitempty,oneof=asc desc"`
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ExchangeRatePayload struct {
+	From string  `json:"from" binding:"required,len=3"`
+	To   string  `json:"to" binding:"required,len=3"`
+	Rate float64 `json:"rate" binding:"required,gt=0"`
+}
+
+// an image file of a multipart upload
+type ImageUpload struct {
+	Data []byte
+	Alt  string
+}
+
+type ReorderImagesPayload struct {
+	// every image of the product, in the new order
+	ImageIDs []string `json:"imageIds" binding:"required,min=1"`
+}
+
+// a product of a JSON Lines import, new unless the id or sku matches one
+type ProductImportRow struct {
+	ID string `json:"_id"`
+	ProductPayload
+}
+
+type ProductImportQuery struct {
+	// taken from the file name when left out
+	Format string `form:"format" binding:"omitempty,oneof=csv jsonl"`
+	// check every row without writing anything
+	DryRun bool `form:"dry_run"`
+}
+
+type ProductImportsQuery struct {
+	Page  int `form:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
@@ -3,40 +3,97 @@ package routes
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/souvikjs01/go-ecommerce/config"
 	"github.com/souvikjs01/go-ecommerce/handlers"
 	"github.com/souvikjs01/go-ecommerce/middlewares"
 	"github.com/souvikjs01/go-ecommerce/services"
+	"github.com/souvikjs01/go-ecommerce/storage"
+	"github.com/souvikjs01/go-ecommerce/tax"
+	"github.com/souvikjs01/go-ecommerce/utils"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupRoutes(db *mongo.Client) *gin.Engine {
+func SetupRoutes(db *mongo.Client, cfg *config.Config) *gin.Engine {
 	router := gin.Default()
 	// CORS Setup
 	conf := cors.DefaultConfig()
 	conf.AllowAllOrigins = true
 	conf.AllowCredentials = true
 	conf.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	conf.AddAllowHeaders("Idempotency-Key", "X-Currency")
 
 	router.Use(cors.New(conf))
 
+	// one Redis client for the idempotency records of every request
+	idempotency := middlewares.Idempotency(middlewares.NewRedisIdempotencyStore(utils.GetRedis()))
+
 	// Setup Prometheus
 	// middlewares.PrometheusInit()
 
+	// file storage for generated documents and uploaded images
+	var fileStore storage.Storage = storage.NewLocalStorage(cfg.STORAGE_DIR)
+	if cfg.STORAGE_DRIVER == "s3" {
+		fileStore = storage.NewS3Storage(storage.S3Config{
+			Endpoint:  cfg.S3_ENDPOINT,
+			Region:    cfg.S3_REGION,
+			Bucket:    cfg.S3_BUCKET,
+			AccessKey: cfg.S3_ACCESS_KEY,
+			SecretKey: cfg.S3_SECRET_KEY,
+			PathStyle: cfg.S3_PATH_STYLE,
+		})
+	}
+
 	// services
+	taxService := services.NewTaxService(db)
+	taxCalculator := tax.NewTableCalculator(taxService.FindRates, cfg.PRICES_INCLUDE_TAX)
+	invoiceService := services.NewInvoiceService(db, fileStore)
 	authService := services.NewAuthService(db)
 	userService := services.NewUserService(db)
-	productService := services.NewProductService(db)
-	orderService := services.NewOrderService(db)
-	cartService := services.NewCartService(db)
+	productService := services.NewProductService(db, fileStore)
+	productImportService := services.NewProductImportService(db)
+	imageService := services.NewImageService(db, fileStore, services.NewImageOptions(cfg))
+	categoryService := services.NewCategoryService(db)
+	orderService := services.NewOrderService(db, invoiceService, taxCalculator, cfg.ORDER_NUMBER_PREFIX)
+	cartService := services.NewCartService(db, taxCalculator, services.NewCartOptions(cfg))
+	returnService := services.NewReturnService(db, invoiceService)
+	shipmentService := services.NewShipmentService(db)
+	addressService := services.NewAddressService(db)
+	currencyService := services.NewCurrencyService(db)
+	couponService := services.NewCouponService(db)
+	promotionService := services.NewPromotionService(db, taxCalculator)
+	shippingService := services.NewShippingService(db)
+	wishlistService := services.NewWishlistService(db, cfg.CART_MAX_LINE_QUANTITY)
+	alertService := services.NewAlertService(db)
+	reviewOptions := services.NewReviewOptions(cfg)
+	reviewService := services.NewReviewService(db, reviewOptions)
+	questionService := services.NewQuestionService(db, reviewOptions.Policy, reviewOptions.AutoApprove)
 
 	// handlers
-	authhandler := handlers.NewAuthHandler(authService)
+	guestCookie := handlers.GuestCartCookie{Domain: cfg.GUEST_CART_COOKIE_DOMAIN, Secure: cfg.GUEST_CART_COOKIE_SECURE}
+	authhandler := handlers.NewAuthHandler(authService, cartService, guestCookie)
 	userHandler := handlers.NewUserHandler(userService)
-	productHandler := handlers.NewProductHandler(productService)
+	productHandler := handlers.NewProductHandler(productService, currencyService)
+	productImportHandler := handlers.NewProductImportHandler(productImportService, productService, cfg.IMPORT_MAX_BYTES)
+	imageHandler := handlers.NewImageHandler(imageService, cfg.IMAGE_MAX_BYTES, cfg.IMAGE_MAX_PER_PRODUCT)
+	categoryHandler := handlers.NewCategoryHandler(categoryService, currencyService)
 	orderHandler := handlers.NewOrderHandler(orderService)
-	cartHandler := handlers.NewCartHandler(cartService)
+	cartHandler := handlers.NewCartHandler(cartService, guestCookie)
+	returnHandler := handlers.NewReturnHandler(returnService)
+	shipmentHandler := handlers.NewShipmentHandler(shipmentService)
+	addressHandler := handlers.NewAddressHandler(addressService)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+	currencyHandler := handlers.NewCurrencyHandler(currencyService)
+	couponHandler := handlers.NewCouponHandler(couponService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	taxHandler := handlers.NewTaxHandler(taxService)
+	shippingHandler := handlers.NewShippingHandler(shippingService)
+	wishlistHandler := handlers.NewWishlistHandler(wishlistService)
+	alertHandler := handlers.NewAlertHandler(alertService)
+	reviewHandler := handlers.NewReviewHandler(reviewService)
+	questionHandler := handlers.NewQuestionHandler(questionService)
 
 	// Public Routes  -- *** Modification ***
+	// no Idempotency here, replaying a login would hand out a cached session
 	publicAuthRoute := router.Group("/api/v1/auth")
 	publicAuthRoute.Use(middlewares.Rate_lim())
 	{
@@ -45,10 +102,21 @@ func SetupRoutes(db *mongo.Client) *gin.Engine {
 		publicAuthRoute.GET("/logout", authhandler.Logout)
 	}
 
+	// guest cart routes, the cart is found through a signed cookie
+	guest_cart_routes := router.Group("/api/v1/guest-cart")
+	guest_cart_routes.Use(middlewares.Rate_lim())
+	guest_cart_routes.Use(idempotency)
+	{
+		guest_cart_routes.POST("/add-to-cart", cartHandler.AddToGuestCartHandler)
+		guest_cart_routes.GET("/my-cart", cartHandler.GetGuestCartHandler)
+		guest_cart_routes.DELETE("/remove-item/:productId", cartHandler.RemoveFromGuestCartHandler)
+	}
+
 	// Private Routes    user routes
 	user_private_routes := router.Group("/api/v1/user")
 	user_private_routes.Use(middlewares.RequireAuth())
 	user_private_routes.Use(middlewares.Rate_lim())
+	user_private_routes.Use(idempotency)
 	{
 		user_private_routes.GET("/me", userHandler.GetMyProfile)
 		user_private_routes.PUT("/update_me", userHandler.UpdateUserProfile)
@@ -73,20 +141,58 @@ func SetupRoutes(db *mongo.Client) *gin.Engine {
 	private_product_routes := router.Group("/api/v1/products")
 	private_product_routes.Use(middlewares.RequireAuth())
 	private_product_routes.Use(middlewares.Rate_lim())
+	private_product_routes.Use(idempotency)
 	{
 		private_product_routes.POST("/create-product", productHandler.CreateProductHandler)
 		private_product_routes.PUT("/update-product/:productId", productHandler.UpdateProductHandler)
 		private_product_routes.DELETE("/delete-product/:productId", productHandler.DeleteProduct)
+		private_product_routes.POST("/upload-images/:productId", imageHandler.UploadProductImagesHandler)
+		private_product_routes.PUT("/reorder-images/:productId", imageHandler.ReorderProductImagesHandler)
+		private_product_routes.DELETE("/delete-image/:productId/:imageId", imageHandler.DeleteProductImageHandler)
+		private_product_routes.POST("/import-products", productImportHandler.ImportProductsHandler)
+		private_product_routes.GET("/imports", productImportHandler.GetProductImportsHandler)
+		private_product_routes.GET("/imports/:importId", productImportHandler.GetProductImportHandler)
+		private_product_routes.GET("/export-products", productImportHandler.ExportProductsHandler)
+	}
+
+	// image routes, not rate limited as a single page shows dozens of them
+	image_routes := router.Group("/api/v1/images")
+	{
+		image_routes.GET("/*key", imageHandler.GetImageHandler)
+	}
+
+	// category routes
+	public_category_routes := router.Group("/api/v1/categories")
+	public_category_routes.Use(middlewares.Rate_lim())
+	{
+		public_category_routes.GET("/tree", categoryHandler.GetCategoryTreeHandler)
+		public_category_routes.GET("/category/:slug", categoryHandler.GetCategoryHandler)
+		public_category_routes.GET("/breadcrumbs/:slug", categoryHandler.GetBreadcrumbsHandler)
+		public_category_routes.GET("/products/:slug", categoryHandler.GetCategoryProductsHandler)
+	}
+	category_routes := router.Group("/api/v1/categories")
+	category_routes.Use(middlewares.RequireAuth())
+	category_routes.Use(middlewares.Rate_lim())
+	category_routes.Use(idempotency)
+	{
+		category_routes.POST("/create-category", categoryHandler.CreateCategoryHandler)
+		category_routes.PUT("/update-category/:categoryId", categoryHandler.UpdateCategoryHandler)
+		category_routes.DELETE("/delete-category/:categoryId", categoryHandler.DeleteCategoryHandler)
 	}
 
 	// order routes
 	order_Routes := router.Group("/api/v1/orders")
 	order_Routes.Use(middlewares.RequireAuth())
 	order_Routes.Use(middlewares.Rate_lim())
+	order_Routes.Use(idempotency)
 	{
 		order_Routes.POST("/create-order", orderHandler.CreateOrderHandler)
 		order_Routes.GET("/user-orders", orderHandler.GetUserOrdersHandler)
-		// order_Routes.GET("/orders", orderHandler.GetOrdersHandler)
+		order_Routes.GET("/by-number/:orderNumber", orderHandler.GetOrderByNumberHandler)
+		// admin
+		order_Routes.GET("/orders", orderHandler.GetOrdersHandler)
+		order_Routes.GET("/export", orderHandler.ExportOrdersHandler)
+		order_Routes.PUT("/mark-paid/:orderId", orderHandler.MarkOrderPaidHandler)
 		// order_Routes.DELETE("/order/:orderId", orderHandler.DeleteOrderHandler)
 		// order_Routes.PUT("/order/:orderId", orderHandler.UpdateOrderHandler)
 	}
@@ -95,12 +201,228 @@ func SetupRoutes(db *mongo.Client) *gin.Engine {
 	cart_routes := router.Group("/api/v1/cart")
 	cart_routes.Use(middlewares.RequireAuth())
 	cart_routes.Use(middlewares.Rate_lim())
+	cart_routes.Use(idempotency)
 	{
 		cart_routes.POST("/add-to-cart", cartHandler.AddToCartHandler)
 		cart_routes.GET("/my-cart", cartHandler.GetMyCart)
 		cart_routes.DELETE("/delete-my-cart/:cartId", cartHandler.DeleteCartHandler)
 		cart_routes.GET("/all-carts", cartHandler.GetCartshandler)
 		cart_routes.PUT("/update-cart/:cartID", cartHandler.UpdateCarthandler)
+		cart_routes.POST("/apply-coupon", cartHandler.ApplyCouponHandler)
+		cart_routes.DELETE("/remove-coupon/:code", cartHandler.RemoveCouponHandler)
+		cart_routes.GET("/shipping-methods", cartHandler.ShippingOptionsHandler)
+		cart_routes.DELETE("/dismiss-notices", cartHandler.DismissNoticesHandler)
+		cart_routes.GET("/abandoned-report", cartHandler.AbandonedCartReportHandler)
+	}
+
+	// wishlist routes
+	wishlist_routes := router.Group("/api/v1/wishlist")
+	wishlist_routes.Use(middlewares.RequireAuth())
+	wishlist_routes.Use(middlewares.Rate_lim())
+	wishlist_routes.Use(idempotency)
+	{
+		wishlist_routes.POST("/create-wishlist", wishlistHandler.CreateWishlistHandler)
+		wishlist_routes.GET("/my-wishlists", wishlistHandler.GetWishlistsHandler)
+		wishlist_routes.GET("/wishlist/:wishlistId", wishlistHandler.GetWishlistHandler)
+		wishlist_routes.PUT("/update-wishlist/:wishlistId", wishlistHandler.UpdateWishlistHandler)
+		wishlist_routes.DELETE("/delete-wishlist/:wishlistId", wishlistHandler.DeleteWishlistHandler)
+		wishlist_routes.POST("/add-item/:wishlistId", wishlistHandler.AddToWishlistHandler)
+		wishlist_routes.DELETE("/remove-item/:wishlistId/:productId", wishlistHandler.RemoveFromWishlistHandler)
+		wishlist_routes.POST("/move-from-cart/:wishlistId/:productId", wishlistHandler.MoveFromCartHandler)
+		wishlist_routes.POST("/move-to-cart/:wishlistId/:productId", wishlistHandler.MoveToCartHandler)
+		// saved for later
+		wishlist_routes.GET("/saved-for-later", wishlistHandler.GetWishlistHandler)
+		wishlist_routes.POST("/saved-for-later/add-item", wishlistHandler.AddToWishlistHandler)
+		wishlist_routes.DELETE("/saved-for-later/remove-item/:productId", wishlistHandler.RemoveFromWishlistHandler)
+		wishlist_routes.POST("/save-for-later/:productId", wishlistHandler.MoveFromCartHandler)
+		wishlist_routes.POST("/saved-for-later/move-to-cart/:productId", wishlistHandler.MoveToCartHandler)
+		// admin
+		wishlist_routes.GET("/product-counts", wishlistHandler.ProductWishlistCountsHandler)
+	}
+
+	// shared wishlists, anyone with the link can view them
+	shared_wishlist_routes := router.Group("/api/v1/shared-wishlist")
+	shared_wishlist_routes.Use(middlewares.Rate_lim())
+	{
+		shared_wishlist_routes.GET("/:token", wishlistHandler.GetSharedWishlistHandler)
+	}
+
+	// back in stock and price drop alerts
+	alert_routes := router.Group("/api/v1/alerts")
+	alert_routes.Use(middlewares.Rate_lim())
+	{
+		// the link in every alert notification, works without logging in
+		alert_routes.GET("/unsubscribe/:token", alertHandler.UnsubscribeHandler)
+	}
+	private_alert_routes := router.Group("/api/v1/alerts")
+	private_alert_routes.Use(middlewares.RequireAuth())
+	private_alert_routes.Use(middlewares.Rate_lim())
+	private_alert_routes.Use(idempotency)
+	{
+		private_alert_routes.POST("/subscribe", alertHandler.SubscribeHandler)
+		private_alert_routes.GET("/my-alerts", alertHandler.GetMyAlertsHandler)
+		private_alert_routes.DELETE("/delete-alert/:alertId", alertHandler.DeleteAlertHandler)
+	}
+
+	// review routes
+	public_review_routes := router.Group("/api/v1/reviews")
+	public_review_routes.Use(middlewares.Rate_lim())
+	{
+		public_review_routes.GET("/product/:productId", reviewHandler.GetProductReviewsHandler)
+	}
+	review_routes := router.Group("/api/v1/reviews")
+	review_routes.Use(middlewares.RequireAuth())
+	review_routes.Use(middlewares.Rate_lim())
+	review_routes.Use(idempotency)
+	{
+		review_routes.POST("/create-review", reviewHandler.CreateReviewHandler)
+		review_routes.GET("/my-reviews", reviewHandler.GetMyReviewsHandler)
+		review_routes.PUT("/update-review/:reviewId", reviewHandler.UpdateReviewHandler)
+		review_routes.DELETE("/delete-review/:reviewId", reviewHandler.DeleteReviewHandler)
+		review_routes.POST("/report-review/:reviewId", reviewHandler.ReportReviewHandler)
+		review_routes.GET("/moderation-queue", reviewHandler.GetModerationQueueHandler)
+		review_routes.PUT("/moderate-review/:reviewId", reviewHandler.ModerateReviewHandler)
+		review_routes.GET("/reports/:reviewId", reviewHandler.GetReviewReportsHandler)
+		review_routes.GET("/moderation-log", reviewHandler.GetModerationLogHandler)
+	}
+
+	// question and answer routes
+	public_question_routes := router.Group("/api/v1/questions")
+	public_question_routes.Use(middlewares.Rate_lim())
+	{
+		public_question_routes.GET("/product/:productId", questionHandler.GetProductQuestionsHandler)
+	}
+	question_routes := router.Group("/api/v1/questions")
+	question_routes.Use(middlewares.RequireAuth())
+	question_routes.Use(middlewares.Rate_lim())
+	question_routes.Use(idempotency)
+	{
+		question_routes.POST("/ask-question", questionHandler.AskQuestionHandler)
+		question_routes.GET("/my-questions", questionHandler.GetMyQuestionsHandler)
+		question_routes.DELETE("/delete-question/:questionId", questionHandler.DeleteQuestionHandler)
+		question_routes.POST("/answer/:questionId", questionHandler.AnswerQuestionHandler)
+		question_routes.DELETE("/delete-answer/:answerId", questionHandler.DeleteAnswerHandler)
+		question_routes.POST("/helpful/:answerId", questionHandler.MarkHelpfulHandler)
+		question_routes.DELETE("/helpful/:answerId", questionHandler.UnmarkHelpfulHandler)
+		question_routes.GET("/question-queue", questionHandler.GetQuestionQueueHandler)
+		question_routes.GET("/answer-queue", questionHandler.GetAnswerQueueHandler)
+		question_routes.PUT("/moderate-question/:questionId", questionHandler.ModerateQuestionHandler)
+		question_routes.PUT("/moderate-answer/:answerId", questionHandler.ModerateAnswerHandler)
+	}
+
+	// return routes
+	return_routes := router.Group("/api/v1/returns")
+	return_routes.Use(middlewares.RequireAuth())
+	return_routes.Use(middlewares.Rate_lim())
+	return_routes.Use(idempotency)
+	{
+		return_routes.POST("/request-return", returnHandler.CreateReturnHandler)
+		return_routes.GET("/my-returns", returnHandler.GetMyReturnsHandler)
+		return_routes.GET("/order/:orderId", returnHandler.GetOrderReturnHistoryHandler)
+		// admin
+		return_routes.GET("/all-returns", returnHandler.GetAllReturnsHandler)
+		return_routes.PUT("/approve/:returnId", returnHandler.ApproveReturnHandler)
+		return_routes.PUT("/reject/:returnId", returnHandler.RejectReturnHandler)
+		return_routes.PUT("/receive/:returnId", returnHandler.ReceiveReturnHandler)
+	}
+
+	// shipment routes
+	shipment_routes := router.Group("/api/v1/shipments")
+	shipment_routes.Use(middlewares.RequireAuth())
+	shipment_routes.Use(middlewares.Rate_lim())
+	shipment_routes.Use(idempotency)
+	{
+		shipment_routes.GET("/order/:orderId", shipmentHandler.GetOrderShipmentsHandler)
+		// admin
+		shipment_routes.POST("/create-shipment", shipmentHandler.CreateShipmentHandler)
+		shipment_routes.PUT("/deliver/:shipmentId", shipmentHandler.MarkDeliveredHandler)
+	}
+
+	// address book routes
+	address_routes := router.Group("/api/v1/addresses")
+	address_routes.Use(middlewares.RequireAuth())
+	address_routes.Use(middlewares.Rate_lim())
+	address_routes.Use(idempotency)
+	{
+		address_routes.POST("/add-address", addressHandler.AddAddressHandler)
+		address_routes.GET("/my-addresses", addressHandler.GetMyAddressesHandler)
+		address_routes.PUT("/update-address/:addressId", addressHandler.UpdateAddressHandler)
+		address_routes.DELETE("/delete-address/:addressId", addressHandler.DeleteAddressHandler)
+	}
+
+	// invoice routes
+	invoice_routes := router.Group("/api/v1/invoices")
+	invoice_routes.Use(middlewares.RequireAuth())
+	invoice_routes.Use(middlewares.Rate_lim())
+	{
+		invoice_routes.GET("/order/:orderId", invoiceHandler.GetOrderInvoicesHandler)
+		invoice_routes.GET("/download/:invoiceId", invoiceHandler.DownloadInvoiceHandler)
+		invoice_routes.POST("/order/:orderId/issue-missing", invoiceHandler.IssueMissingDocumentsHandler)
+	}
+
+	// exchange rate routes
+	currency_routes := router.Group("/api/v1/currency")
+	currency_routes.Use(middlewares.RequireAuth())
+	currency_routes.Use(middlewares.Rate_lim())
+	currency_routes.Use(idempotency)
+	{
+		currency_routes.GET("/rates", currencyHandler.GetRatesHandler)
+		// admin
+		currency_routes.PUT("/set-rate", currencyHandler.SetRateHandler)
+		currency_routes.DELETE("/delete-rate/:from/:to", currencyHandler.DeleteRateHandler)
+	}
+
+	// coupon routes, admin only
+	coupon_routes := router.Group("/api/v1/coupons")
+	coupon_routes.Use(middlewares.RequireAuth())
+	coupon_routes.Use(middlewares.Rate_lim())
+	coupon_routes.Use(idempotency)
+	{
+		coupon_routes.POST("/create-coupon", couponHandler.CreateCouponHandler)
+		coupon_routes.GET("/all-coupons", couponHandler.GetCouponsHandler)
+		coupon_routes.PUT("/update-coupon/:couponId", couponHandler.UpdateCouponHandler)
+		coupon_routes.DELETE("/delete-coupon/:couponId", couponHandler.DeleteCouponHandler)
+	}
+
+	// promotion routes, admin only
+	promotion_routes := router.Group("/api/v1/promotions")
+	promotion_routes.Use(middlewares.RequireAuth())
+	promotion_routes.Use(middlewares.Rate_lim())
+	promotion_routes.Use(idempotency)
+	{
+		promotion_routes.POST("/create-promotion", promotionHandler.CreatePromotionHandler)
+		promotion_routes.GET("/all-promotions", promotionHandler.GetPromotionsHandler)
+		promotion_routes.PUT("/update-promotion/:promotionId", promotionHandler.UpdatePromotionHandler)
+		promotion_routes.DELETE("/delete-promotion/:promotionId", promotionHandler.DeletePromotionHandler)
+		promotion_routes.POST("/dry-run", promotionHandler.DryRunHandler)
+	}
+
+	// tax rate routes
+	tax_routes := router.Group("/api/v1/tax")
+	tax_routes.Use(middlewares.RequireAuth())
+	tax_routes.Use(middlewares.Rate_lim())
+	tax_routes.Use(idempotency)
+	{
+		tax_routes.GET("/rates", taxHandler.GetRatesHandler)
+		// admin
+		tax_routes.PUT("/set-rate", taxHandler.SetRateHandler)
+		tax_routes.DELETE("/delete-rate/:rateId", taxHandler.DeleteRateHandler)
+	}
+
+	// shipping zone and method routes, admin only
+	shipping_routes := router.Group("/api/v1/shipping")
+	shipping_routes.Use(middlewares.RequireAuth())
+	shipping_routes.Use(middlewares.Rate_lim())
+	shipping_routes.Use(idempotency)
+	{
+		shipping_routes.POST("/create-zone", shippingHandler.CreateZoneHandler)
+		shipping_routes.GET("/all-zones", shippingHandler.GetZonesHandler)
+		shipping_routes.PUT("/update-zone/:zoneId", shippingHandler.UpdateZoneHandler)
+		shipping_routes.DELETE("/delete-zone/:zoneId", shippingHandler.DeleteZoneHandler)
+		shipping_routes.POST("/create-method", shippingHandler.CreateMethodHandler)
+		shipping_routes.GET("/all-methods", shippingHandler.GetMethodsHandler)
+		shipping_routes.PUT("/update-method/:methodId", shippingHandler.UpdateMethodHandler)
+		shipping_routes.DELETE("/delete-method/:methodId", shippingHandler.DeleteMethodHandler)
 	}
 
 	return router
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// an order placed this long after a reminder counts as recovered by it
+const reminderRecoveryWindow = 7 * 24 * time.Hour
+
+// AbandonedCartJob reminds users of carts they stopped changing. The n-th
+// reminder goes out once a cart has been idle for the n-th threshold. A cart
+// that is already idle past several thresholds, e.g. after downtime, only
+// gets the reminder of the latest one.
+type AbandonedCartJob struct {
+	db         *mongo.Client
+	notifier   notify.Notifier
+	thresholds []time.Duration
+}
+
+func NewAbandonedCartJob(db *mongo.Client, notifier notify.Notifier, thresholds []time.Duration) *AbandonedCartJob {
+	sorted := append([]time.Duration{}, thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &AbandonedCartJob{
+		db:         db,
+		notifier:   notifier,
+		thresholds: sorted,
+	}
+}
+
+// Start runs the job every interval until the context is cancelled
+func (j *AbandonedCartJob) Start(ctx context.Context, interval time.Duration) {
+	if len(j.thresholds) == 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(ctx, interval)
+				sent, err := j.Run(runCtx)
+				cancel()
+				if err != nil {
+					fmt.Println("failed to send abandoned cart reminders:", err)
+				} else if sent > 0 {
+					fmt.Println("abandoned cart reminders sent:", sent)
+				}
+			}
+		}
+	}()
+}
+
+// Run sends the reminders that are due, at most one per cart, and returns how
+// many went out
+func (j *AbandonedCartJob) Run(ctx context.Context) (int, error) {
+	if len(j.thresholds) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	cur, err := j.db.Database("go-ecomm").Collection("carts").Find(ctx, bson.M{
+		"updatedat": bson.M{"$lte": now.Add(-j.thresholds[0])},
+		// also carts from before reminders existed, they have no count yet
+		"reminderssent": bson.M{"$not": bson.M{"$gte": len(j.thresholds)}},
+		"products.0":    bson.M{"$exists": true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	carts := []model.Cart{}
+	if err := cur.All(ctx, &carts); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range carts {
+		stage := j.dueStage(now.Sub(carts[i].UpdatedAt))
+		if stage < carts[i].RemindersSent {
+			// the reminder of this threshold went out, the next isn't due yet
+			continue
+		}
+		ok, err := j.remind(ctx, &carts[i], stage)
+		if err != nil {
+			fmt.Println("failed to remind cart", carts[i].ID.Hex()+":", err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// the latest reminder a cart idle for so long is due for, -1 for none
+func (j *AbandonedCartJob) dueStage(idle time.Duration) int {
+	stage := -1
+	for n, threshold := range j.thresholds {
+		if idle >= threshold {
+			stage = n
+		}
+	}
+	return stage
+}
+
+// carts from before reminders existed have no count yet
+func remindersSentFilter(sent int) bson.M {
+	if sent == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return bson.M{"$eq": sent}
+}
+
+// sends the reminder of the stage, skipping the earlier ones that weren't
+// sent. False when it was suppressed or another run took it.
+func (j *AbandonedCartJob) remind(ctx context.Context, cart *model.Cart, stage int) (bool, error) {
+	db := j.db.Database("go-ecomm")
+	carts := db.Collection("carts")
+
+	// the user ordered since the last change, no more reminders for this cart
+	ordered, err := db.Collection("orders").CountDocuments(ctx, bson.M{
+		"userid":    cart.UserId,
+		"createdat": bson.M{"$gte": cart.UpdatedAt},
+	})
+	if err != nil {
+		return false, err
+	}
+	if ordered > 0 {
+		_, err := carts.UpdateOne(ctx, bson.M{"_id": cart.ID, "updatedat": cart.UpdatedAt},
+			bson.M{"$set": bson.M{"reminderssent": len(j.thresholds)}})
+		return false, err
+	}
+
+	// claim the reminder first so that a second instance can't send it too
+	now := time.Now()
+	claimed, err := carts.UpdateOne(ctx,
+		bson.M{"_id": cart.ID, "updatedat": cart.UpdatedAt, "reminderssent": remindersSentFilter(cart.RemindersSent)},
+		bson.M{"$set": bson.M{"reminderssent": stage + 1, "lastremindedat": now}},
+	)
+	if err != nil || claimed.ModifiedCount == 0 {
+		return false, err
+	}
+
+	msg, err := j.message(ctx, cart, stage)
+	if err == nil {
+		err = j.notifier.Send(ctx, msg)
+	}
+	if err != nil {
+		// give the reminder back so the next run retries it
+		carts.UpdateOne(ctx, bson.M{"_id": cart.ID, "reminderssent": stage + 1},
+			bson.M{"$set": bson.M{"reminderssent": cart.RemindersSent}})
+		return false, err
+	}
+
+	_, err = db.Collection("cart_reminders").InsertOne(ctx, model.CartReminder{
+		ID:     primitive.NewObjectID(),
+		CartID: cart.ID,
+		UserId: cart.UserId,
+		Stage:  stage + 1,
+		SentAt: now,
+	})
+	if err != nil {
+		fmt.Println("failed to record cart reminder:", err)
+	}
+	return true, nil
+}
+
+func (j *AbandonedCartJob) message(ctx context.Context, cart *model.Cart, stage int) (*notify.Message, error) {
+	db := j.db.Database("go-ecomm")
+
+	var user model.User
+	err := db.Collection("users").FindOne(ctx, bson.M{"_id": cart.UserId},
+		options.FindOne().SetProjection(bson.M{"email": 1, "firstname": 1})).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cart owner: %w", err)
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(cart.Products))
+	for _, item := range cart.Products {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	cur, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}},
+		options.Find().SetProjection(bson.M{"title": 1}))
+	if err != nil {
+		return nil, err
+	}
+	products := []model.Product{}
+	if err := cur.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	titles := make([]string, 0, len(products))
+	for _, prod := range products {
+		titles = append(titles, prod.Title)
+	}
+
+	return &notify.Message{
+		Kind:    "abandoned_cart",
+		UserID:  cart.UserId.Hex(),
+		To:      user.Email,
+		Subject: "You left something in your cart",
+		Body: fmt.Sprintf("Hi %s, your cart still has %s waiting for you.",
+			user.FirstName, strings.Join(titles, ", ")),
+		Data: map[string]string{
+			"cartId": cart.ID.Hex(),
+			"stage":  fmt.Sprint(stage + 1),
+		},
+	}, nil
+}
+
+// Marks the recent reminders of a user as recovered by their order
+func markCartsRecovered(ctx context.Context, db *mongo.Client, userId, orderId primitive.ObjectID) error {
+	now := time.Now()
+	_, err := db.Database("go-ecomm").Collection("cart_reminders").UpdateMany(ctx,
+		bson.M{
+			"userid":      userId,
+			"recoveredat": nil,
+			"sentat":      bson.M{"$gte": now.Add(-reminderRecoveryWindow)},
+		},
+		bson.M{"$set": bson.M{"recoveredat": now, "orderid": orderId}},
+	)
+	return err
+}
+
+// GetAbandonedCartReport counts the reminders sent between from and to and
+// the carts they recovered. Dates default to the last 30 days.
+func (c *CartServiceStruct) GetAbandonedCartReport(from, to string) (*model.AbandonedCartReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	report := &model.AbandonedCartReport{
+		From:   time.Now().AddDate(0, 0, -30),
+		To:     time.Now(),
+		Stages: []model.AbandonedCartStage{},
+	}
+	if from != "" {
+		t, err := parseFilterTime(from, false)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid from date"), Code: 400}
+		}
+		report.From = t
+	}
+	if to != "" {
+		t, err := parseFilterTime(to, true)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid to date"), Code: 400}
+		}
+		report.To = t
+	}
+
+	reportChan := make(chan *model.AbandonedCartReport, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		reminders := c.db.Database("go-ecomm").Collection("cart_reminders")
+		match := bson.M{"sentat": bson.M{"$gte": report.From, "$lte": report.To}}
+
+		cur, err := reminders.Aggregate(ctx, bson.A{
+			bson.M{"$match": match},
+			bson.M{"$group": bson.M{
+				"_id":  "$stage",
+				"sent": bson.M{"$sum": 1},
+				"recovered": bson.M{"$sum": bson.M{
+					"$cond": bson.A{bson.M{"$ifNull": bson.A{"$recoveredat", false}}, 1, 0},
+				}},
+			}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		var stages []struct {
+			Stage     int   `bson:"_id"`
+			Sent      int64 `bson:"sent"`
+			Recovered int64 `bson:"recovered"`
+		}
+		if err := cur.All(ctx, &stages); err != nil {
+			errChan <- err
+			return
+		}
+		for _, stage := range stages {
+			report.RemindersSent += stage.Sent
+			report.Stages = append(report.Stages, model.AbandonedCartStage{
+				Stage:        stage.Stage,
+				Sent:         stage.Sent,
+				Recovered:    stage.Recovered,
+				RecoveryRate: ratio(stage.Recovered, stage.Sent),
+			})
+		}
+
+		// a cart reminded several times counts once
+		reminded, err := reminders.Distinct(ctx, "cartid", match)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		match["recoveredat"] = bson.M{"$ne": nil}
+		recovered, err := reminders.Distinct(ctx, "cartid", match)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		report.CartsReminded = int64(len(reminded))
+		report.CartsRecovered = int64(len(recovered))
+		report.RecoveryRate = ratio(report.CartsRecovered, report.CartsReminded)
+
+		reportChan <- report
+	}()
+
+	select {
+	case report := <-reportChan:
+		return report, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
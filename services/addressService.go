@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AddressService interface {
+	AddAddress(payload *request.AddressPayload, userId string) (*model.Address, error)
+	GetUserAddresses(userId string) (*[]model.Address, error)
+	UpdateAddress(payload *request.UpdateAddressPayload, userId, addressId string) (*model.Address, error)
+	DeleteAddress(userId, addressId string) (*model.Address, error)
+}
+
+type AddressServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewAddressService(db *mongo.Client) *AddressServiceStruct {
+	return &AddressServiceStruct{
+		db: db,
+	}
+}
+
+// Save a new address to the user's address book
+func (a *AddressServiceStruct) AddAddress(payload *request.AddressPayload, userId string) (*model.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	address := model.NewAddress(userObjID, payload.Name, payload.Line1, payload.Line2, payload.City,
+		payload.Region, payload.PostalCode, payload.Country, payload.Phone)
+	if err := address.Validate(); err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+	address.IsDefaultShipping = payload.IsDefaultShipping
+	address.IsDefaultBilling = payload.IsDefaultBilling
+
+	go func() {
+		defer close(errChan)
+		defer close(addressChan)
+
+		collection := a.db.Database("go-ecomm").Collection("addresses")
+
+		// the first address becomes the default for everything
+		count, err := collection.CountDocuments(ctx, bson.M{"userid": userObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+
+		if err := a.clearDefaults(ctx, userObjID, address); err != nil {
+			errChan <- err
+			return
+		}
+
+		_, err = collection.InsertOne(ctx, address)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- address
+	}()
+
+	select {
+	case address := <-addressChan:
+		return address, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (a *AddressServiceStruct) GetUserAddresses(userId string) (*[]model.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	addressesChan := make(chan *[]model.Address, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(addressesChan)
+
+		cur, err := a.db.Database("go-ecomm").Collection("addresses").Find(ctx,
+			bson.M{"userid": userObjID},
+			options.Find().SetSort(bson.M{"createdat": 1}),
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		addresses := []model.Address{}
+		if err := cur.All(ctx, &addresses); err != nil {
+			errChan <- err
+			return
+		}
+		addressesChan <- &addresses
+	}()
+
+	select {
+	case addresses := <-addressesChan:
+		return addresses, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (a *AddressServiceStruct) UpdateAddress(payload *request.UpdateAddressPayload, userId, addressId string) (*model.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	addressObjID, err := primitive.ObjectIDFromHex(addressId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid addressId"), Code: 400}
+	}
+
+	filter := bson.M{"_id": addressObjID, "userid": userObjID}
+
+	go func() {
+		defer close(errChan)
+		defer close(addressChan)
+
+		collection := a.db.Database("go-ecomm").Collection("addresses")
+
+		var address model.Address
+		err := collection.FindOne(ctx, filter).Decode(&address)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("address not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.Name != nil {
+			address.Name = *payload.Name
+		}
+		if payload.Line1 != nil {
+			address.Line1 = *payload.Line1
+		}
+		if payload.Line2 != nil {
+			address.Line2 = *payload.Line2
+		}
+		if payload.City != nil {
+			address.City = *payload.City
+		}
+		if payload.Region != nil {
+			address.Region = *payload.Region
+		}
+		if payload.PostalCode != nil {
+			address.PostalCode = *payload.PostalCode
+		}
+		if payload.Country != nil {
+			address.Country = *payload.Country
+		}
+		if payload.Phone != nil {
+			address.Phone = *payload.Phone
+		}
+		if payload.IsDefaultShipping != nil {
+			address.IsDefaultShipping = *payload.IsDefaultShipping
+		}
+		if payload.IsDefaultBilling != nil {
+			address.IsDefaultBilling = *payload.IsDefaultBilling
+		}
+
+		address.Normalize()
+		if err := address.Validate(); err != nil {
+			errChan <- model.ErrMsg{Err: err, Code: 400}
+			return
+		}
+		address.UpdatedAt = time.Now()
+
+		if err := a.clearDefaults(ctx, userObjID, &address); err != nil {
+			errChan <- err
+			return
+		}
+
+		_, err = collection.UpdateOne(ctx, filter, bson.M{"$set": address})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- &address
+	}()
+
+	select {
+	case address := <-addressChan:
+		return address, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (a *AddressServiceStruct) DeleteAddress(userId, addressId string) (*model.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	addressChan := make(chan *model.Address, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	addressObjID, err := primitive.ObjectIDFromHex(addressId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid addressId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(addressChan)
+
+		// orders keep their own snapshot, so deleting never touches them
+		var address model.Address
+		err := a.db.Database("go-ecomm").Collection("addresses").FindOneAndDelete(ctx,
+			bson.M{"_id": addressObjID, "userid": userObjID},
+		).Decode(&address)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("address not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		addressChan <- &address
+	}()
+
+	select {
+	case address := <-addressChan:
+		return address, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// a user has at most one default shipping and one default billing address
+func (a *AddressServiceStruct) clearDefaults(ctx context.Context, userId primitive.ObjectID, address *model.Address) error {
+	unset := bson.M{}
+	if address.IsDefaultShipping {
+		unset["isdefaultshipping"] = false
+	}
+	if address.IsDefaultBilling {
+		unset["isdefaultbilling"] = false
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+
+	_, err := a.db.Database("go-ecomm").Collection("addresses").UpdateMany(ctx,
+		bson.M{"userid": userId, "_id": bson.M{"$ne": address.ID}},
+		bson.M{"$set": unset},
+	)
+	return err
+}
+
+// Picks the address to snapshot onto an order: a saved address by id, then a
+// one-off address, then the user's default. Returns nil when none applies.
+func resolveOrderAddress(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, addressId string, inline *request.AddressPayload, defaultField string) (*model.Address, error) {
+	collection := db.Database("go-ecomm").Collection("addresses")
+
+	if addressId != "" {
+		addressObjID, err := primitive.ObjectIDFromHex(addressId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid address id"), Code: 400}
+		}
+		var address model.Address
+		err = collection.FindOne(ctx, bson.M{"_id": addressObjID, "userid": userId}).Decode(&address)
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrMsg{Err: fmt.Errorf("address not found"), Code: 404}
+		} else if err != nil {
+			return nil, err
+		}
+		return &address, nil
+	}
+
+	if inline != nil {
+		address := model.NewAddress(userId, inline.Name, inline.Line1, inline.Line2, inline.City,
+			inline.Region, inline.PostalCode, inline.Country, inline.Phone)
+		if err := address.Validate(); err != nil {
+			return nil, model.ErrMsg{Err: err, Code: 400}
+		}
+		return address, nil
+	}
+
+	var address model.Address
+	err := collection.FindOne(ctx, bson.M{"userid": userId, defaultField: true}).Decode(&address)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
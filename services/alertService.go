@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AlertService interface {
+	Subscribe(payload *request.ProductAlertPayload, userId string) (*model.ProductAlert, error)
+	GetMyAlerts(userId string) (*[]model.ProductAlert, error)
+	DeleteAlert(userId, alertId string) (*model.ProductAlert, error)
+	Unsubscribe(token string) (*model.ProductAlert, error)
+}
+
+type AlertServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewAlertService(db *mongo.Client) *AlertServiceStruct {
+	return &AlertServiceStruct{
+		db: db,
+	}
+}
+
+// Subscribe to a product, subscribing again to the same change re-arms the
+// alert
+func (a *AlertServiceStruct) Subscribe(payload *request.ProductAlertPayload, userId string) (*model.ProductAlert, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	alertChan := make(chan *model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(payload.ProductID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(alertChan)
+
+		db := a.db.Database("go-ecomm")
+
+		var prod model.Product
+		if err := db.Collection("products").FindOne(ctx, bson.M{"_id": productObjID}).Decode(&prod); err != nil {
+			errChan <- err
+			return
+		}
+		if payload.Type == model.AlertBackInStock && prod.InStock {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product %s is in stock", prod.Title), Code: 400}
+			return
+		}
+		if payload.Size != "" && !contains(prod.Size, payload.Size) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product has no size %q", payload.Size), Code: 400}
+			return
+		}
+		if payload.Color != "" && !contains(prod.Color, payload.Color) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product has no color %q", payload.Color), Code: 400}
+			return
+		}
+
+		token, err := utils.RandomToken(16)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		var alert model.ProductAlert
+		err = db.Collection("product_alerts").FindOneAndUpdate(ctx,
+			bson.M{
+				"userid":    usrObjID,
+				"productid": productObjID,
+				"type":      payload.Type,
+				"size":      payload.Size,
+				"color":     payload.Color,
+			},
+			bson.M{
+				"$set": bson.M{
+					"active":         true,
+					"referenceprice": prod.Price,
+					"subscribedat":   time.Now(),
+				},
+				"$setOnInsert": bson.M{
+					"_id":              primitive.NewObjectID(),
+					"unsubscribetoken": token,
+					"lastnotifiedat":   nil,
+				},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&alert)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertChan <- &alert
+	}()
+
+	select {
+	case alert := <-alertChan:
+		return alert, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (a *AlertServiceStruct) GetMyAlerts(userId string) (*[]model.ProductAlert, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	alertsChan := make(chan *[]model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(alertsChan)
+
+		cur, err := a.db.Database("go-ecomm").Collection("product_alerts").Find(ctx,
+			bson.M{"userid": usrObjID},
+			options.Find().SetSort(bson.D{{Key: "subscribedat", Value: -1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alerts := []model.ProductAlert{}
+		if err := cur.All(ctx, &alerts); err != nil {
+			errChan <- err
+			return
+		}
+		alertsChan <- &alerts
+	}()
+
+	select {
+	case alerts := <-alertsChan:
+		return alerts, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (a *AlertServiceStruct) DeleteAlert(userId, alertId string) (*model.ProductAlert, error) {
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	alertObjID, err := primitive.ObjectIDFromHex(alertId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid alertId"), Code: 400}
+	}
+	return a.deleteAlert(bson.M{"_id": alertObjID, "userid": usrObjID})
+}
+
+// Unsubscribe through the link in a notification, no login needed
+func (a *AlertServiceStruct) Unsubscribe(token string) (*model.ProductAlert, error) {
+	if token == "" {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid token"), Code: 400}
+	}
+	return a.deleteAlert(bson.M{"unsubscribetoken": token})
+}
+
+// queued notifications of a deleted alert are cancelled when they come up
+func (a *AlertServiceStruct) deleteAlert(filter bson.M) (*model.ProductAlert, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	alertChan := make(chan *model.ProductAlert, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(alertChan)
+
+		var alert model.ProductAlert
+		err := a.db.Database("go-ecomm").Collection("product_alerts").FindOneAndDelete(ctx, filter).Decode(&alert)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		alertChan <- &alert
+	}()
+
+	select {
+	case alert := <-alertChan:
+		return alert, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Queues the alerts a product update sets off: back in stock when it became
+// available, price drop when it is available below a subscriber's reference
+// price, so a drop while out of stock is reported when it is back. It runs
+// after the update has been answered, so it has its own timeout.
+func queueProductAlerts(db *mongo.Client, before, after *model.Product) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if !before.InStock && after.InStock {
+		queued, err := fanOutAlerts(ctx, db, after, model.AlertBackInStock, bson.M{})
+		if err != nil {
+			fmt.Println("failed to queue back in stock alerts:", err)
+		} else if queued > 0 {
+			fmt.Println("back in stock alerts queued:", queued)
+		}
+	}
+	dropped := after.Price.Currency == before.Price.Currency && after.Price.Amount < before.Price.Amount
+	if after.InStock && (dropped || !before.InStock) {
+		queued, err := fanOutAlerts(ctx, db, after, model.AlertPriceDrop, bson.M{
+			"referenceprice.currency": after.Price.Currency,
+			"referenceprice.amount":   bson.M{"$gt": after.Price.Amount},
+		})
+		if err != nil {
+			fmt.Println("failed to queue price drop alerts:", err)
+		} else if queued > 0 {
+			fmt.Println("price drop alerts queued:", queued)
+		}
+	}
+}
+
+func fanOutAlerts(ctx context.Context, db *mongo.Client, prod *model.Product, alertType string, filter bson.M) (int, error) {
+	alerts := db.Database("go-ecomm").Collection("product_alerts")
+
+	filter["productid"] = prod.ID
+	filter["type"] = alertType
+	filter["active"] = true
+	cur, err := alerts.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	users := map[primitive.ObjectID]*model.User{}
+	queued := 0
+	for cur.Next(ctx) {
+		var alert model.ProductAlert
+		if err := cur.Decode(&alert); err != nil {
+			return queued, err
+		}
+
+		user, ok := users[alert.UserId]
+		if !ok {
+			user = &model.User{}
+			err := db.Database("go-ecomm").Collection("users").FindOne(ctx, bson.M{"_id": alert.UserId},
+				options.FindOne().SetProjection(bson.M{"email": 1, "firstname": 1})).Decode(user)
+			if err == mongo.ErrNoDocuments {
+				user = nil
+			} else if err != nil {
+				return queued, err
+			}
+			users[alert.UserId] = user
+		}
+		if user == nil {
+			continue
+		}
+
+		// claim the alert so an update racing this one can't queue it too
+		now := time.Now()
+		claim := bson.M{"_id": alert.ID, "active": true}
+		set := bson.M{"lastnotifiedat": now}
+		notification := &model.Notification{
+			Kind:    alertType,
+			UserId:  alert.UserId,
+			To:      user.Email,
+			AlertID: &alert.ID,
+			Data: map[string]string{
+				"productId": prod.ID.Hex(),
+				"size":      alert.Size,
+				"color":     alert.Color,
+			},
+		}
+		if alertType == model.AlertBackInStock {
+			set["active"] = false
+			notification.DedupKey = fmt.Sprintf("%s:%s:%d", alertType, alert.ID.Hex(), alert.SubscribedAt.Unix())
+			notification.Subject = prod.Title + " is back in stock"
+			notification.Body = fmt.Sprintf("Hi %s, %s%s is back in stock.", user.FirstName, prod.Title, alertVariant(&alert))
+		} else {
+			claim["referenceprice.amount"] = alert.ReferencePrice.Amount
+			set["referenceprice"] = prod.Price
+			notification.DedupKey = fmt.Sprintf("%s:%s:%d", alertType, alert.ID.Hex(), prod.Price.Amount)
+			notification.Subject = prod.Title + " is now cheaper"
+			notification.Body = fmt.Sprintf("Hi %s, %s%s went down from %s to %s.", user.FirstName, prod.Title,
+				alertVariant(&alert), alert.ReferencePrice.Format(), prod.Price.Format())
+			notification.Data["oldPrice"] = alert.ReferencePrice.Format()
+			notification.Data["newPrice"] = prod.Price.Format()
+		}
+
+		claimed, err := alerts.UpdateOne(ctx, claim, bson.M{"$set": set})
+		if err != nil {
+			return queued, err
+		}
+		if claimed.ModifiedCount == 0 {
+			continue
+		}
+		ok, err = enqueueNotification(ctx, db, notification)
+		if err != nil {
+			return queued, err
+		}
+		if ok {
+			queued++
+		}
+	}
+	return queued, cur.Err()
+}
+
+// the variant an alert is for, e.g. " (size M, color red)"
+func alertVariant(alert *model.ProductAlert) string {
+	parts := []string{}
+	if alert.Size != "" {
+		parts = append(parts, "size "+alert.Size)
+	}
+	if alert.Color != "" {
+		parts = append(parts, "color "+alert.Color)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
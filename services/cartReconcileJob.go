@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// a cart keeps this many notices, the oldest are dropped
+const maxCartNotices = 20
+
+// CartReconcileResult counts what a reconciliation run changed
+type CartReconcileResult struct {
+	Expired int64
+	Removed int
+	Flagged int
+	// lines cut down to the stock left
+	Clamped int
+	// lines cut down to CartOptions.MaxLineQuantity
+	OverLineLimit int
+}
+
+// CartReconcileJob removes carts that were left unchanged for longer than
+// the cart TTL and brings the lines of the others in line with the catalogue:
+// deleted products are removed, out of stock ones flagged or removed and
+// quantities cut down to the stock left, for products that count it, and to
+// the per line limit. It doesn't touch UpdatedAt, a reconciled cart is still
+// as old as the customer left it.
+type CartReconcileJob struct {
+	db      *mongo.Client
+	options CartOptions
+}
+
+func NewCartReconcileJob(db *mongo.Client, options CartOptions) *CartReconcileJob {
+	return &CartReconcileJob{
+		db:      db,
+		options: options,
+	}
+}
+
+// Start runs the job every interval until the context is cancelled
+func (j *CartReconcileJob) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(ctx, interval)
+				result, err := j.Run(runCtx)
+				cancel()
+				if err != nil {
+					fmt.Println("failed to reconcile carts:", err)
+				} else if *result != (CartReconcileResult{}) {
+					fmt.Printf("carts reconciled: %d expired, %d lines removed, %d flagged, %d clamped to stock, %d over the line limit\n",
+						result.Expired, result.Removed, result.Flagged, result.Clamped, result.OverLineLimit)
+				}
+			}
+		}
+	}()
+}
+
+func (j *CartReconcileJob) Run(ctx context.Context) (*CartReconcileResult, error) {
+	result := &CartReconcileResult{}
+	carts := j.db.Database("go-ecomm").Collection("carts")
+
+	if j.options.TTL > 0 {
+		expired, err := carts.DeleteMany(ctx, bson.M{"updatedat": bson.M{"$lt": time.Now().Add(-j.options.TTL)}})
+		if err != nil {
+			return result, err
+		}
+		result.Expired = expired.DeletedCount
+	}
+
+	cur, err := carts.Find(ctx, bson.M{"products.0": bson.M{"$exists": true}})
+	if err != nil {
+		return result, err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var cart model.Cart
+		if err := cur.Decode(&cart); err != nil {
+			return result, err
+		}
+		// the lines as stored, to tell whether the customer changed them since
+		stored := cur.Current.Lookup("products")
+		if err := j.reconcile(ctx, &cart, stored, result); err != nil {
+			fmt.Println("failed to reconcile cart", cart.ID.Hex()+":", err)
+		}
+	}
+	return result, cur.Err()
+}
+
+func (j *CartReconcileJob) reconcile(ctx context.Context, cart *model.Cart, stored bson.RawValue, result *CartReconcileResult) error {
+	db := j.db.Database("go-ecomm")
+
+	productIDs := make([]primitive.ObjectID, 0, len(cart.Products))
+	for _, item := range cart.Products {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	cur, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}},
+		options.Find().SetProjection(bson.M{"title": 1, "instock": 1, "stock": 1}))
+	if err != nil {
+		return err
+	}
+	products := []model.Product{}
+	if err := cur.All(ctx, &products); err != nil {
+		return err
+	}
+	byID := make(map[primitive.ObjectID]*model.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	now := time.Now()
+	changed := false
+	notices := []model.CartNotice{}
+	items := make([]model.ProductDetails, 0, len(cart.Products))
+	removed, flagged, clamped, overLimit := 0, 0, 0, 0
+	for _, item := range cart.Products {
+		prod, ok := byID[item.ProductID]
+		if !ok {
+			notices = append(notices, model.CartNotice{
+				Code:      model.CartNoticeProductRemoved,
+				Message:   "a product in your cart is no longer sold and was removed",
+				ProductID: item.ProductID,
+				CreatedAt: now,
+			})
+			removed++
+			changed = true
+			continue
+		}
+
+		if !prod.InStock && j.options.RemoveUnavailable {
+			notices = append(notices, model.CartNotice{
+				Code:      model.CartNoticeProductRemoved,
+				Message:   fmt.Sprintf("%s is out of stock and was removed", prod.Title),
+				ProductID: item.ProductID,
+				CreatedAt: now,
+			})
+			removed++
+			changed = true
+			continue
+		}
+		status := ""
+		if !prod.InStock {
+			status = model.CartWarningOutOfStock
+		}
+		if item.Status != status {
+			if status != "" {
+				flagged++
+			}
+			item.Status = status
+			changed = true
+		}
+
+		// an out of stock line keeps its quantity for when the product is back
+		if stock := prod.Stock; prod.InStock && stock != nil && *stock > 0 && item.Quantity > *stock {
+			notices = append(notices, model.CartNotice{
+				Code:      model.CartNoticeQuantityReduced,
+				Message:   fmt.Sprintf("only %d of %s are left, the quantity was reduced from %d", *stock, prod.Title, item.Quantity),
+				ProductID: item.ProductID,
+				CreatedAt: now,
+			})
+			item.Quantity = *stock
+			clamped++
+			changed = true
+		}
+		if limit := j.options.MaxLineQuantity; limit > 0 && item.Quantity > limit {
+			notices = append(notices, model.CartNotice{
+				Code:      model.CartNoticeOverLineLimit,
+				Message:   fmt.Sprintf("only %d of %s can be ordered at once, the quantity was reduced from %d", limit, prod.Title, item.Quantity),
+				ProductID: item.ProductID,
+				CreatedAt: now,
+			})
+			item.Quantity = limit
+			overLimit++
+			changed = true
+		}
+		items = append(items, item)
+	}
+	if !changed {
+		return nil
+	}
+
+	// only if the customer hasn't changed the lines in the meantime
+	update := bson.M{"$set": bson.M{"products": items}}
+	if len(notices) > 0 {
+		update["$push"] = bson.M{"notices": bson.M{"$each": notices, "$slice": -maxCartNotices}}
+	}
+	res, err := db.Collection("carts").UpdateOne(ctx,
+		bson.M{"_id": cart.ID, "products": stored},
+		update,
+	)
+	if err != nil || res.ModifiedCount == 0 {
+		return err
+	}
+	result.Removed += removed
+	result.Flagged += flagged
+	result.Clamped += clamped
+	result.OverLineLimit += overLimit
+	return nil
+}
+
+// Clear the notices of the cart once the customer has seen them
+func (c *CartServiceStruct) DismissNotices(userId string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		var cart model.Cart
+		err := c.db.Database("go-ecomm").Collection("carts").FindOneAndUpdate(ctx,
+			bson.M{"userid": usrObjID},
+			bson.M{"$set": bson.M{"notices": []model.CartNotice{}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&cart)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- &cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
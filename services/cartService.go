@@ -2,32 +2,70 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/souvikjs01/go-ecommerce/config"
 	"github.com/souvikjs01/go-ecommerce/model"
 	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/tax"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type CartService interface {
 	AddToCart(cart *request.AddToCartPayload, userID string) (*model.Cart, error)
-	GetCartDetails(userId string) (*model.Cart, error) // debug
+	GetCartDetails(userId, currency string) (*model.Cart, error)
 	DeleteCart(userId, cartId string) (*model.Cart, error)
 	GetAllCarts() (*[]model.Cart, error)
 	UpdateCart(cart *model.Cart, userId, cartId string) (*model.Cart, error) // debug
+	ApplyCoupon(code, userId, currency string) (*model.Cart, error)
+	RemoveCoupon(code, userId, currency string) (*model.Cart, error)
+	ShippingOptions(userId, addressId string, estimate *model.Address, currency string) (*[]model.ShippingOption, error)
+	AddToGuestCart(cart *request.AddToCartPayload, guestCartId string) (*model.GuestCart, error)
+	GetGuestCart(guestCartId, currency string) (*model.Cart, error)
+	RemoveFromGuestCart(guestCartId, productId string) (*model.GuestCart, error)
+	MergeGuestCart(guestCartId, userId string) (*model.Cart, error)
+	GetAbandonedCartReport(from, to string) (*model.AbandonedCartReport, error)
+	DismissNotices(userId string) (*model.Cart, error)
+}
+
+// CartOptions configures guest carts and merging
+type CartOptions struct {
+	GuestTTL time.Duration
+	// how long an unchanged cart is kept, 0 keeps carts forever
+	TTL             time.Duration
+	MergeStrategy   string
+	MaxLineQuantity int
+	// the reconciliation removes out of stock lines instead of flagging them
+	RemoveUnavailable bool
+}
+
+func NewCartOptions(cfg *config.Config) CartOptions {
+	return CartOptions{
+		GuestTTL:          time.Duration(cfg.GUEST_CART_TTL_HOURS) * time.Hour,
+		TTL:               time.Duration(cfg.CART_TTL_DAYS) * 24 * time.Hour,
+		MergeStrategy:     cfg.CART_MERGE_STRATEGY,
+		MaxLineQuantity:   cfg.CART_MAX_LINE_QUANTITY,
+		RemoveUnavailable: cfg.CART_REMOVE_UNAVAILABLE,
+	}
 }
 
 type CartServiceStruct struct {
-	db *mongo.Client
+	db      *mongo.Client
+	taxes   tax.Calculator
+	options CartOptions
 }
 
-func NewCartService(db *mongo.Client) *CartServiceStruct {
+func NewCartService(db *mongo.Client, taxes tax.Calculator, options CartOptions) *CartServiceStruct {
 	return &CartServiceStruct{
-		db: db,
+		db:      db,
+		taxes:   taxes,
+		options: options,
 	}
 }
 
@@ -62,8 +100,11 @@ func (c *CartServiceStruct) AddToCart(cart *request.AddToCartPayload, userID str
 		if err == mongo.ErrNoDocuments {
 			// No cart exists, create a new one
 			newCart := &model.Cart{
-				ID:     primitive.NewObjectID(),
-				UserId: user_objId,
+				ID:          primitive.NewObjectID(),
+				UserId:      user_objId,
+				CouponCodes: []string{},
+				CreatedAt:   time.Now(),
+				UpdatedAt:   time.Now(),
 				Products: []model.ProductDetails{
 					{
 						ProductID: productObjID,
@@ -78,7 +119,7 @@ func (c *CartServiceStruct) AddToCart(cart *request.AddToCartPayload, userID str
 				return
 			}
 			cartChan <- newCart
-
+			return
 		} else if err != nil {
 			errChan <- fmt.Errorf("failed to query cart: %w", err)
 			return
@@ -103,6 +144,7 @@ func (c *CartServiceStruct) AddToCart(cart *request.AddToCartPayload, userID str
 				},
 				bson.M{
 					"$inc": bson.M{"products.$.quantity": cart.Quantity},
+					"$set": cartTouched(),
 				},
 			)
 			if err != nil {
@@ -123,6 +165,7 @@ func (c *CartServiceStruct) AddToCart(cart *request.AddToCartPayload, userID str
 							Quantity:  cart.Quantity,
 						},
 					},
+					"$set": cartTouched(),
 				},
 			)
 			if err != nil {
@@ -154,8 +197,10 @@ func (c *CartServiceStruct) AddToCart(cart *request.AddToCartPayload, userID str
 	}
 }
 
-// get Cart
-func (c *CartServiceStruct) GetCartDetails(userId string) (*model.Cart, error) {
+// Get the cart with its products, current prices, totals and tax estimate.
+// The products are joined in the same query, items that can't be ordered are
+// kept with a warning and left out of the totals.
+func (c *CartServiceStruct) GetCartDetails(userId, currency string) (*model.Cart, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
@@ -164,33 +209,36 @@ func (c *CartServiceStruct) GetCartDetails(userId string) (*model.Cart, error) {
 
 	usrObjID, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		errChan <- fmt.Errorf("invalid userId: %w", err)
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
 	}
 
-	filter := bson.M{"userid": usrObjID}
-
 	go func() {
-		var cartData model.Cart
-		err = c.db.Database("go-ecomm").Collection("carts").FindOne(ctx, filter).Decode(&cartData)
-		if err == mongo.ErrNoDocuments {
+		defer close(errChan)
+		defer close(cartChan)
+
+		cart, products, err := findCartProducts(ctx, c.db, bson.M{"userid": usrObjID})
+		if err != nil {
 			errChan <- err
 			return
-		} else if err != nil {
+		}
+		if err := enrichCart(ctx, c.db, c.taxes, cart, products, currency); err != nil {
 			errChan <- err
 			return
 		}
-
-		cartChan <- &cartData
-	}()
-	for {
-		select {
-		case cart := <-cartChan:
-			return cart, nil
-		case err := <-errChan:
-			return nil, err
-		case <-ctx.Done():
-			return nil, fmt.Errorf("context deadline exceeded")
+		if c.options.TTL > 0 {
+			expiresAt := cart.UpdatedAt.Add(c.options.TTL)
+			cart.ExpiresAt = &expiresAt
 		}
+		cartChan <- cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
 	}
 }
 
@@ -335,11 +383,9 @@ func (c *CartServiceStruct) UpdateCart(cart *model.Cart, userId, cartId string)
 		existingCart.Products = cart.Products
 		existingCart.UserId = usrObjID
 
-		update := bson.M{
-			"$set": bson.M{
-				"products": existingCart.Products,
-			},
-		}
+		set := cartTouched()
+		set["products"] = existingCart.Products
+		update := bson.M{"$set": set}
 
 		_, err = c.db.Database("go-ecomm").Collection("carts").
 			UpdateOne(ctx, filter, update)
@@ -361,3 +407,489 @@ func (c *CartServiceStruct) UpdateCart(cart *model.Cart, userId, cartId string)
 		return nil, context.DeadlineExceeded
 	}
 }
+
+// Apply a coupon code to the cart, it is only kept when it works together
+// with the codes already applied
+func (c *CartServiceStruct) ApplyCoupon(code, userId, currency string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	code = model.NormalizeCouponCode(code)
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		collection := c.db.Database("go-ecomm").Collection("carts")
+
+		cart, products, err := findCartProducts(ctx, c.db, bson.M{"userid": usrObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		for _, applied := range cart.CouponCodes {
+			if applied == code {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("coupon %s is already applied", code), Code: 409}
+				return
+			}
+		}
+		cart.CouponCodes = append(cart.CouponCodes, code)
+
+		pricing, err := cartPricing(ctx, c.db, c.taxes, cart, products, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": cart.ID},
+			bson.M{"$addToSet": bson.M{"couponcodes": code}, "$set": cartTouched()},
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cart.Pricing = pricing
+		cartChan <- cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Remove a coupon code from the cart
+func (c *CartServiceStruct) RemoveCoupon(code, userId, currency string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	code = model.NormalizeCouponCode(code)
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		var cart model.Cart
+		err := c.db.Database("go-ecomm").Collection("carts").FindOneAndUpdate(ctx,
+			bson.M{"userid": usrObjID},
+			bson.M{"$pull": bson.M{"couponcodes": code}, "$set": cartTouched()},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&cart)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		// the remaining codes may no longer apply, the cart is still returned then
+		products, err := cartProducts(ctx, c.db, cart.Products)
+		if err == nil {
+			if pricing, err := cartPricing(ctx, c.db, c.taxes, &cart, products, currency); err == nil {
+				cart.Pricing = pricing
+			}
+		}
+		cartChan <- &cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Shipping methods available for the cart with their costs. They are priced
+// for a saved address, a partial address to estimate for, or the default
+// shipping address.
+func (c *CartServiceStruct) ShippingOptions(userId, addressId string, estimate *model.Address, currency string) (*[]model.ShippingOption, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	optionsChan := make(chan *[]model.ShippingOption, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(optionsChan)
+
+		cart, products, err := findCartProducts(ctx, c.db, bson.M{"userid": usrObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		address := estimate
+		if address == nil {
+			address, err = resolveOrderAddress(ctx, c.db, usrObjID, addressId, nil, "isdefaultshipping")
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
+		if address == nil {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("an address is required to list shipping methods"), Code: 400}
+			return
+		}
+
+		currency, err := resolveCurrency(ctx, c.db, currency, userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		contents, err := cartLines(ctx, c.db, cart.Products, products, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		lines, currency := contents.Priced, contents.Currency
+		if len(lines) == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("the cart has nothing that can be ordered"), Code: 400}
+			return
+		}
+		pricing, _, err := applyDiscounts(ctx, c.db, cart.UserId, cart.CouponCodes, lines, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		goods, freeShipping, err := shippableValue(pricing)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		available, err := shippingOptions(ctx, c.db, address, lines, goods, freeShipping)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		optionsChan <- &available
+	}()
+
+	select {
+	case available := <-optionsChan:
+		return available, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// fields set by every change to a cart, a change starts the reminders over
+func cartTouched() bson.M {
+	return bson.M{"updatedat": time.Now(), "reminderssent": 0}
+}
+
+// how often a cart update is tried again when the cart changed under it
+const cartMergeAttempts = 5
+
+// Merges items into the user's cart with the strategy, creating the cart when
+// there is none. The lines are only written if nobody changed them since they
+// were read, otherwise the merge starts over from the new lines.
+func mergeIntoCart(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, items []model.ProductDetails, strategy string, maxQuantity int) (*model.Cart, error) {
+	carts := db.Database("go-ecomm").Collection("carts")
+	for attempt := 0; attempt < cartMergeAttempts; attempt++ {
+		raw, err := carts.FindOne(ctx, bson.M{"userid": userId}).Raw()
+		if err == mongo.ErrNoDocuments {
+			now := time.Now()
+			cart := model.Cart{
+				ID:          primitive.NewObjectID(),
+				UserId:      userId,
+				Products:    model.MergeCartItems([]model.ProductDetails{}, items, strategy, maxQuantity),
+				CouponCodes: []string{},
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			// only inserted if no cart was created in the meantime
+			res, err := carts.UpdateOne(ctx, bson.M{"userid": userId},
+				bson.M{"$setOnInsert": cart}, options.Update().SetUpsert(true))
+			if err != nil {
+				return nil, err
+			}
+			if res.UpsertedCount == 1 {
+				return &cart, nil
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var cart model.Cart
+		if err := bson.Unmarshal(raw, &cart); err != nil {
+			return nil, err
+		}
+		// the lines as stored, to tell whether they changed since
+		filter := bson.M{"_id": cart.ID, "products": bson.M{"$exists": false}}
+		if stored, err := raw.LookupErr("products"); err == nil {
+			filter["products"] = stored
+		}
+
+		now := time.Now()
+		cart.Products = model.MergeCartItems(cart.Products, items, strategy, maxQuantity)
+		res, err := carts.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+			"products":      cart.Products,
+			"updatedat":     now,
+			"reminderssent": 0,
+		}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 1 {
+			cart.UpdatedAt, cart.RemindersSent = now, 0
+			return &cart, nil
+		}
+	}
+	return nil, model.ErrMsg{Err: fmt.Errorf("the cart kept changing, try again"), Code: 409}
+}
+
+// promotions, coupon discounts and tax on the cart's current contents, taxed
+// for the user's default shipping address
+func cartPricing(ctx context.Context, db *mongo.Client, taxes tax.Calculator, cart *model.Cart, products []model.Product, currency string) (*model.PromotionResult, error) {
+	currency, err := resolveCurrency(ctx, db, currency, cart.UserId.Hex())
+	if err != nil {
+		return nil, err
+	}
+	contents, err := cartLines(ctx, db, cart.Products, products, currency)
+	if err != nil {
+		return nil, err
+	}
+	lines, currency := contents.Priced, contents.Currency
+	result, lineDiscounts, err := applyDiscounts(ctx, db, cart.UserId, cart.CouponCodes, lines, currency)
+	if err != nil {
+		return nil, err
+	}
+	address, err := defaultShippingAddress(ctx, db, cart.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTax(ctx, taxes, result, lines, lineDiscounts, address); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Fills the lines, warnings and pricing of a cart from its products. A coupon
+// that no longer applies is reported instead of failing the whole cart.
+func enrichCart(ctx context.Context, db *mongo.Client, taxes tax.Calculator, cart *model.Cart, products []model.Product, currency string) error {
+	currency, err := resolveCurrency(ctx, db, currency, cart.UserId.Hex())
+	if err != nil {
+		return err
+	}
+	contents, err := cartLines(ctx, db, cart.Products, products, currency)
+	if err != nil {
+		return err
+	}
+	cartItems, warnings, lines, currency := contents.Lines, contents.Warnings, contents.Priced, contents.Currency
+	// the latest change the reconciliation made to each line, unless the
+	// line has a problem of its own now
+	notices := make(map[primitive.ObjectID]model.CartNotice, len(cart.Notices))
+	for _, notice := range cart.Notices {
+		notices[notice.ProductID] = notice
+	}
+	for i := range cartItems {
+		if notice, ok := notices[cartItems[i].ProductID]; ok && cartItems[i].Status == model.CartLineOK {
+			cartItems[i].Status, cartItems[i].Message = notice.Code, notice.Message
+		}
+	}
+
+	pricing, lineDiscounts, err := applyDiscounts(ctx, db, cart.UserId, cart.CouponCodes, lines, currency)
+	var msg model.ErrMsg
+	if errors.As(err, &msg) && len(cart.CouponCodes) > 0 {
+		warnings = append(warnings, model.CartWarning{Code: model.CartWarningCouponInvalid, Message: msg.Err.Error()})
+		pricing, lineDiscounts, err = applyDiscounts(ctx, db, cart.UserId, nil, lines, currency)
+	}
+	if err != nil {
+		return err
+	}
+	address, err := defaultShippingAddress(ctx, db, cart.UserId)
+	if err != nil {
+		return err
+	}
+	if err := applyTax(ctx, taxes, pricing, lines, lineDiscounts, address); err != nil {
+		return err
+	}
+
+	for i := range cartItems {
+		if !cartItems[i].Available {
+			zero := model.ZeroMoney(currency)
+			cartItems[i].UnitPrice, cartItems[i].Total, cartItems[i].Discount, cartItems[i].Tax = zero, zero, zero, zero
+		}
+	}
+	for i, index := range contents.Indexes {
+		cartItems[index].Discount = lineDiscounts[i]
+		cartItems[index].Tax = pricing.Lines[i].Tax
+	}
+	cart.Lines, cart.Warnings, cart.Pricing = cartItems, warnings, pricing
+	return nil
+}
+
+// the items of a cart as they can be ordered right now
+type cartContents struct {
+	// every item, with why it can't be ordered
+	Lines    []model.CartLine
+	Warnings []model.CartWarning
+	// the items that can be ordered, priced
+	Priced []pricedLine
+	// where each of Priced is in Lines
+	Indexes  []int
+	Currency string
+}
+
+// Prices the cart items in the currency, or in the currency of the first
+// product when none is given. Products that were deleted, are out of stock or
+// can't be priced in the currency are only listed with a warning, totals,
+// discounts and shipping leave them out.
+func cartLines(ctx context.Context, db *mongo.Client, items []model.ProductDetails, products []model.Product, currency string) (*cartContents, error) {
+	byID := make(map[primitive.ObjectID]*model.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	contents := &cartContents{
+		Lines:    make([]model.CartLine, 0, len(items)),
+		Warnings: []model.CartWarning{},
+		Priced:   make([]pricedLine, 0, len(items)),
+		Indexes:  make([]int, 0, len(items)),
+	}
+	warn := func(line *model.CartLine, code, message string) {
+		productID := line.ProductID
+		contents.Warnings = append(contents.Warnings, model.CartWarning{Code: code, Message: message, ProductID: &productID})
+		line.Status, line.Message = code, message
+	}
+	for _, item := range items {
+		line := model.CartLine{ProductID: item.ProductID, Quantity: item.Quantity, Status: model.CartLineOK}
+		prod, ok := byID[item.ProductID]
+		if !ok {
+			warn(&line, model.CartWarningProductDeleted, "this product is no longer available")
+			contents.Lines = append(contents.Lines, line)
+			continue
+		}
+		line.Title, line.Img, line.InStock = prod.Title, prod.Img, prod.InStock
+		if !prod.InStock {
+			warn(&line, model.CartWarningOutOfStock, fmt.Sprintf("%s is out of stock", prod.Title))
+			contents.Lines = append(contents.Lines, line)
+			continue
+		}
+
+		if currency == "" {
+			currency = prod.Price.Currency
+		}
+		priced, err := linePrice(ctx, db, prod, item.Quantity, currency)
+		if errors.Is(err, errNoRate) {
+			warn(&line, model.CartWarningPriceUnavailable, fmt.Sprintf("%s can't be priced in %s", prod.Title, currency))
+			contents.Lines = append(contents.Lines, line)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		line.Available = true
+		line.UnitPrice, line.Total = priced.UnitPrice, priced.Total
+		contents.Priced = append(contents.Priced, priced)
+		contents.Indexes = append(contents.Indexes, len(contents.Lines))
+		contents.Lines = append(contents.Lines, line)
+	}
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+	contents.Currency = currency
+	return contents, nil
+}
+
+// a cart found by the filter, with its products joined in the same query
+func findCartProducts(ctx context.Context, db *mongo.Client, filter bson.M) (*model.Cart, []model.Product, error) {
+	cur, err := db.Database("go-ecomm").Collection("carts").Aggregate(ctx, bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$limit": 1},
+		bson.M{
+			"$lookup": bson.M{
+				"from":         "products",
+				"localField":   "products.productid",
+				"foreignField": "_id",
+				"as":           "items",
+			},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cur.Close(ctx)
+
+	if !cur.Next(ctx) {
+		if err := cur.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	var cartData struct {
+		model.Cart `bson:",inline"`
+		Items      []model.Product `bson:"items"`
+	}
+	if err := cur.Decode(&cartData); err != nil {
+		return nil, nil, err
+	}
+	return &cartData.Cart, cartData.Items, nil
+}
+
+// the products of items that aren't part of a stored cart, in one query
+func cartProducts(ctx context.Context, db *mongo.Client, items []model.ProductDetails) ([]model.Product, error) {
+	productIDs := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	cur, err := db.Database("go-ecomm").Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	products := []model.Product{}
+	if err := cur.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// a product priced in the currency for some quantity
+func linePrice(ctx context.Context, db *mongo.Client, prod *model.Product, quantity int, currency string) (pricedLine, error) {
+	price, _, err := priceProduct(ctx, db, prod, currency)
+	if err != nil {
+		return pricedLine{}, err
+	}
+	total, err := price.Mul(int64(quantity))
+	if err != nil {
+		return pricedLine{}, err
+	}
+	return pricedLine{
+		ProductID:   prod.ID,
+		Categories:  prod.Categories,
+		TaxCategory: prod.TaxCategory,
+		WeightGrams: prod.ShippingWeight(),
+		UnitPrice:   price,
+		Quantity:    quantity,
+		Total:       total,
+	}, nil
+}
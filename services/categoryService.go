@@ -0,0 +1,619 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CategoryService interface {
+	CreateCategory(payload *request.CategoryPayload) (*model.Category, error)
+	UpdateCategory(payload *request.UpdateCategoryPayload, categoryId string) (*model.Category, error)
+	DeleteCategory(categoryId, reassignTo string) (*model.Category, error)
+	GetCategoryTree() (*[]model.Category, error)
+	GetCategory(slug string) (*model.Category, error)
+	GetBreadcrumbs(slug string) (*[]model.Category, error)
+	GetCategoryProducts(slug string, query *request.CategoryProductsQuery) (*model.CategoryProducts, error)
+}
+
+type CategoryServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewCategoryService(db *mongo.Client) *CategoryServiceStruct {
+	return &CategoryServiceStruct{
+		db: db,
+	}
+}
+
+func (c *CategoryServiceStruct) CreateCategory(payload *request.CategoryPayload) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	slug := payload.Slug
+	if slug == "" {
+		slug = model.Slugify(payload.Name)
+	}
+	if !model.ValidSlug(slug) {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid slug %q, use lower case letters, digits and dashes", slug), Code: 400}
+	}
+	category := &model.Category{
+		ID:          primitive.NewObjectID(),
+		Slug:        slug,
+		Name:        strings.TrimSpace(payload.Name),
+		Description: strings.TrimSpace(payload.Description),
+		Path:        []primitive.ObjectID{},
+		Position:    payload.Position,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(categoryChan)
+
+		collection := c.db.Database("go-ecomm").Collection("categories")
+
+		if payload.ParentID != "" {
+			parent, err := findParent(ctx, collection, payload.ParentID)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			category.ParentID = &parent.ID
+			category.Path = append(parent.Path, parent.ID)
+		}
+
+		_, err := collection.InsertOne(ctx, category)
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a category with the slug %q already exists", slug), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- category
+	}()
+
+	select {
+	case category := <-categoryChan:
+		return category, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Edit or move a category. Its subtree moves with it, and a new slug is
+// carried over to the products in it.
+func (c *CategoryServiceStruct) UpdateCategory(payload *request.UpdateCategoryPayload, categoryId string) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	categoryObjID, err := primitive.ObjectIDFromHex(categoryId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid categoryId"), Code: 400}
+	}
+	if payload.Slug != nil && !model.ValidSlug(*payload.Slug) {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid slug %q, use lower case letters, digits and dashes", *payload.Slug), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(categoryChan)
+
+		db := c.db.Database("go-ecomm")
+		collection := db.Collection("categories")
+
+		var category model.Category
+		if err := collection.FindOne(ctx, bson.M{"_id": categoryObjID}).Decode(&category); err != nil {
+			errChan <- err
+			return
+		}
+		oldSlug := category.Slug
+		oldPath := category.Path
+
+		if payload.Name != nil {
+			category.Name = strings.TrimSpace(*payload.Name)
+		}
+		if payload.Slug != nil {
+			category.Slug = *payload.Slug
+		}
+		if payload.Description != nil {
+			category.Description = strings.TrimSpace(*payload.Description)
+		}
+		if payload.Position != nil {
+			category.Position = *payload.Position
+		}
+		if payload.ParentID != nil {
+			if *payload.ParentID == "" {
+				category.ParentID = nil
+				category.Path = []primitive.ObjectID{}
+			} else {
+				parent, err := findParent(ctx, collection, *payload.ParentID)
+				if err != nil {
+					errChan <- err
+					return
+				}
+				if parent.ID == category.ID || containsID(parent.Path, category.ID) {
+					errChan <- model.ErrMsg{Err: fmt.Errorf("a category can't be moved under itself"), Code: 400}
+					return
+				}
+				category.ParentID = &parent.ID
+				category.Path = append(parent.Path, parent.ID)
+			}
+		}
+		category.UpdatedAt = time.Now()
+
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": category.ID}, category)
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a category with the slug %q already exists", category.Slug), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.ParentID != nil && !sameIDs(oldPath, category.Path) {
+			// descendants keep the part of their path below the category
+			prefix := append(category.Path, category.ID)
+			_, err := collection.UpdateMany(ctx,
+				bson.M{"path": category.ID},
+				bson.A{bson.M{"$set": bson.M{"path": bson.M{"$concatArrays": bson.A{
+					prefix,
+					bson.M{"$slice": bson.A{
+						"$path",
+						bson.M{"$add": bson.A{bson.M{"$indexOfArray": bson.A{"$path", category.ID}}, 1}},
+						bson.M{"$size": "$path"},
+					}},
+				}}}}},
+			)
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
+		if category.Slug != oldSlug {
+			for _, name := range categorizedCollections {
+				_, err := db.Collection(name).UpdateMany(ctx,
+					bson.M{"categories": oldSlug},
+					bson.M{"$set": bson.M{"categories.$[c]": category.Slug}},
+					options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"c": oldSlug}}}),
+				)
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}
+		categoryChan <- &category
+	}()
+
+	select {
+	case category := <-categoryChan:
+		return category, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Delete a category without subcategories. Products, coupons and promotions
+// in it are moved to the category reassignTo names, without one nothing may
+// use the category.
+func (c *CategoryServiceStruct) DeleteCategory(categoryId, reassignTo string) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	categoryObjID, err := primitive.ObjectIDFromHex(categoryId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid categoryId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(categoryChan)
+
+		db := c.db.Database("go-ecomm")
+		collection := db.Collection("categories")
+
+		var category model.Category
+		if err := collection.FindOne(ctx, bson.M{"_id": categoryObjID}).Decode(&category); err != nil {
+			errChan <- err
+			return
+		}
+		children, err := collection.CountDocuments(ctx, bson.M{"parentid": category.ID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if children > 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("category %s has subcategories, move or delete them first", category.Slug), Code: 409}
+			return
+		}
+
+		if reassignTo != "" {
+			if reassignTo == category.Slug {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("can't reassign products to the deleted category"), Code: 400}
+				return
+			}
+			count, err := collection.CountDocuments(ctx, bson.M{"slug": reassignTo})
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if count == 0 {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("category %s not found", reassignTo), Code: 400}
+				return
+			}
+			// added before the old one is pulled, a coupon or promotion left
+			// without categories would apply to everything
+			for _, name := range categorizedCollections {
+				_, err = db.Collection(name).UpdateMany(ctx, bson.M{"categories": category.Slug},
+					bson.M{"$addToSet": bson.M{"categories": reassignTo}})
+				if err != nil {
+					errChan <- err
+					return
+				}
+				_, err = db.Collection(name).UpdateMany(ctx, bson.M{"categories": category.Slug},
+					bson.M{"$pull": bson.M{"categories": category.Slug}})
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+		} else {
+			for _, name := range categorizedCollections {
+				used, err := db.Collection(name).CountDocuments(ctx, bson.M{"categories": category.Slug})
+				if err != nil {
+					errChan <- err
+					return
+				}
+				if used > 0 {
+					errChan <- model.ErrMsg{Err: fmt.Errorf("%d %s are in category %s, reassign them first", used, name, category.Slug), Code: 409}
+					return
+				}
+			}
+		}
+
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": category.ID}); err != nil {
+			errChan <- err
+			return
+		}
+		categoryChan <- &category
+	}()
+
+	select {
+	case category := <-categoryChan:
+		return category, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The whole tree, the roots with their children nested
+func (c *CategoryServiceStruct) GetCategoryTree() (*[]model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	treeChan := make(chan *[]model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(treeChan)
+
+		categories, err := findCategories(ctx, c.db, bson.M{})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		tree := buildTree(categories, nil)
+		treeChan <- &tree
+	}()
+
+	select {
+	case tree := <-treeChan:
+		return tree, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// A category with its subtree
+func (c *CategoryServiceStruct) GetCategory(slug string) (*model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	categoryChan := make(chan *model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(categoryChan)
+
+		var category model.Category
+		err := c.db.Database("go-ecomm").Collection("categories").FindOne(ctx, bson.M{"slug": slug}).Decode(&category)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		descendants, err := findCategories(ctx, c.db, bson.M{"path": category.ID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		category.Children = buildTree(descendants, &category.ID)
+		categoryChan <- &category
+	}()
+
+	select {
+	case category := <-categoryChan:
+		return category, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The categories from the root down to the one asked for
+func (c *CategoryServiceStruct) GetBreadcrumbs(slug string) (*[]model.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	crumbsChan := make(chan *[]model.Category, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(crumbsChan)
+
+		var category model.Category
+		err := c.db.Database("go-ecomm").Collection("categories").FindOne(ctx, bson.M{"slug": slug}).Decode(&category)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		ancestors, err := findCategories(ctx, c.db, bson.M{"_id": bson.M{"$in": category.Path}})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		byID := make(map[primitive.ObjectID]model.Category, len(ancestors))
+		for _, ancestor := range ancestors {
+			byID[ancestor.ID] = ancestor
+		}
+		crumbs := make([]model.Category, 0, len(category.Path)+1)
+		for _, id := range category.Path {
+			if ancestor, ok := byID[id]; ok {
+				crumbs = append(crumbs, ancestor)
+			}
+		}
+		crumbs = append(crumbs, category)
+		crumbsChan <- &crumbs
+	}()
+
+	select {
+	case crumbs := <-crumbsChan:
+		return crumbs, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The products in a category or any category below it, a page at a time
+func (c *CategoryServiceStruct) GetCategoryProducts(slug string, query *request.CategoryProductsQuery) (*model.CategoryProducts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	resultChan := make(chan *model.CategoryProducts, 32)
+	errChan := make(chan error, 32)
+
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(resultChan)
+
+		db := c.db.Database("go-ecomm")
+		collection := db.Collection("categories")
+
+		var category model.Category
+		if err := collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&category); err != nil {
+			errChan <- err
+			return
+		}
+		slugs, err := collection.Distinct(ctx, "slug", bson.M{"path": category.ID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		slugs = append(slugs, category.Slug)
+
+		filter := bson.M{"categories": bson.M{"$in": slugs}}
+		total, err := db.Collection("products").CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := db.Collection("products").Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		products := []model.Product{}
+		if err := cur.All(ctx, &products); err != nil {
+			errChan <- err
+			return
+		}
+
+		resultChan <- &model.CategoryProducts{
+			Category: category,
+			Products: products,
+			Page:     page,
+			Limit:    limit,
+			Total:    total,
+		}
+	}()
+
+	select {
+	case result := <-resultChan:
+		return result, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func findParent(ctx context.Context, collection *mongo.Collection, parentId string) (*model.Category, error) {
+	parentObjID, err := primitive.ObjectIDFromHex(parentId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid parent_id"), Code: 400}
+	}
+	var parent model.Category
+	err = collection.FindOne(ctx, bson.M{"_id": parentObjID}).Decode(&parent)
+	if err == mongo.ErrNoDocuments {
+		return nil, model.ErrMsg{Err: fmt.Errorf("parent category not found"), Code: 400}
+	} else if err != nil {
+		return nil, err
+	}
+	return &parent, nil
+}
+
+// categories in tree order: by position, then name
+func findCategories(ctx context.Context, db *mongo.Client, filter bson.M) ([]model.Category, error) {
+	cur, err := db.Database("go-ecomm").Collection("categories").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	categories := []model.Category{}
+	err = cur.All(ctx, &categories)
+	return categories, err
+}
+
+// nests the categories under the parent, nil for the roots, keeping their
+// order
+func buildTree(categories []model.Category, parent *primitive.ObjectID) []model.Category {
+	children := map[primitive.ObjectID][]model.Category{}
+	roots := []model.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil || (parent != nil && *category.ParentID == *parent) {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+	var nest func(nodes []model.Category) []model.Category
+	nest = func(nodes []model.Category) []model.Category {
+		for i := range nodes {
+			nodes[i].Children = nest(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return nest(roots)
+}
+
+// collections whose documents name categories by slug
+var categorizedCollections = []string{"products", "coupons", "promotions"}
+
+// Checks the categories of a product, coupon or promotion against the tree
+// and returns them as slugs, without blanks or repeats
+func validateCategories(ctx context.Context, db *mongo.Client, categories []string) ([]string, error) {
+	slugs := normalizeCategories(categories)
+	if len(slugs) == 0 {
+		return slugs, nil
+	}
+
+	found, err := db.Database("go-ecomm").Collection("categories").Distinct(ctx, "slug", bson.M{"slug": bson.M{"$in": slugs}})
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, slug := range found {
+		if s, ok := slug.(string); ok {
+			known[s] = true
+		}
+	}
+	unknown := []string{}
+	for _, slug := range slugs {
+		if !known[slug] {
+			unknown = append(unknown, slug)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, model.ErrMsg{Err: fmt.Errorf("unknown categories: %s", strings.Join(unknown, ", ")), Code: 400}
+	}
+	return slugs, nil
+}
+
+// lower case slugs without blanks and duplicates
+func normalizeCategories(categories []string) []string {
+	slugs := []string{}
+	for _, category := range categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category != "" && !contains(slugs, category) {
+			slugs = append(slugs, category)
+		}
+	}
+	return slugs
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
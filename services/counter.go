@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type counter struct {
+	ID  string `bson:"_id"`
+	Seq int64  `bson:"seq"`
+}
+
+// atomically increments the named counter and returns its new value, starting at 1
+func nextSequence(ctx context.Context, db *mongo.Client, name string) (int64, error) {
+	var c counter
+	err := db.Database("go-ecomm").Collection("counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&c)
+	if err != nil {
+		return 0, err
+	}
+	return c.Seq, nil
+}
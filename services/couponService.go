@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CouponService interface {
+	CreateCoupon(payload *request.CouponPayload) (*model.Coupon, error)
+	GetCoupons() (*[]model.Coupon, error)
+	UpdateCoupon(payload *request.UpdateCouponPayload, couponId string) (*model.Coupon, error)
+	DeleteCoupon(couponId string) (*model.Coupon, error)
+}
+
+type CouponServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewCouponService(db *mongo.Client) *CouponServiceStruct {
+	return &CouponServiceStruct{
+		db: db,
+	}
+}
+
+// Create a coupon code
+func (c *CouponServiceStruct) CreateCoupon(payload *request.CouponPayload) (*model.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	productIDs, err := objectIDs(payload.ProductIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+	coupon := model.NewCoupon(&model.Coupon{
+		Code:             payload.Code,
+		Type:             payload.Type,
+		PercentOff:       payload.PercentOff,
+		AmountOff:        payload.AmountOff,
+		MinSpend:         payload.MinSpend,
+		ProductIDs:       productIDs,
+		Categories:       payload.Categories,
+		StartsAt:         payload.StartsAt,
+		EndsAt:           payload.EndsAt,
+		UsageLimit:       payload.UsageLimit,
+		PerCustomerLimit: payload.PerCustomerLimit,
+		Stackable:        payload.Stackable,
+		Active:           active,
+	})
+	if err := coupon.Validate(); err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(couponChan)
+
+		categories, err := validateCategories(ctx, c.db, coupon.Categories)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		coupon.Categories = categories
+
+		_, err = c.db.Database("go-ecomm").Collection("coupons").InsertOne(ctx, coupon)
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("coupon %s already exists", coupon.Code), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- coupon
+	}()
+
+	for {
+		select {
+		case coupon := <-couponChan:
+			return coupon, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// All coupons, newest first
+func (c *CouponServiceStruct) GetCoupons() (*[]model.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	couponsChan := make(chan *[]model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(couponsChan)
+
+		cur, err := c.db.Database("go-ecomm").Collection("coupons").Find(ctx, bson.M{},
+			options.Find().SetSort(bson.M{"createdat": -1}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		coupons := []model.Coupon{}
+		if err := cur.All(ctx, &coupons); err != nil {
+			errChan <- err
+			return
+		}
+		couponsChan <- &coupons
+	}()
+
+	for {
+		select {
+		case coupons := <-couponsChan:
+			return coupons, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Change the limits, validity or stacking of a coupon. The code, type and
+// value stay fixed so redeemed orders keep matching their coupon.
+func (c *CouponServiceStruct) UpdateCoupon(payload *request.UpdateCouponPayload, couponId string) (*model.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	couponObjID, err := primitive.ObjectIDFromHex(couponId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid couponId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(couponChan)
+
+		collection := c.db.Database("go-ecomm").Collection("coupons")
+
+		var coupon model.Coupon
+		if err := collection.FindOne(ctx, bson.M{"_id": couponObjID}).Decode(&coupon); err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.MinSpend != nil {
+			coupon.MinSpend = payload.MinSpend
+		}
+		if payload.StartsAt != nil {
+			coupon.StartsAt = payload.StartsAt
+		}
+		if payload.EndsAt != nil {
+			coupon.EndsAt = payload.EndsAt
+		}
+		if payload.UsageLimit != nil {
+			coupon.UsageLimit = *payload.UsageLimit
+		}
+		if payload.PerCustomerLimit != nil {
+			coupon.PerCustomerLimit = *payload.PerCustomerLimit
+		}
+		if payload.Stackable != nil {
+			coupon.Stackable = *payload.Stackable
+		}
+		if payload.Active != nil {
+			coupon.Active = *payload.Active
+		}
+		if err := coupon.Validate(); err != nil {
+			errChan <- model.ErrMsg{Err: err, Code: 400}
+			return
+		}
+		coupon.UpdatedAt = time.Now()
+
+		// usedcount is left alone, orders may be redeeming the coupon right now
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": couponObjID}, bson.M{
+			"$set": bson.M{
+				"minspend":         coupon.MinSpend,
+				"startsat":         coupon.StartsAt,
+				"endsat":           coupon.EndsAt,
+				"usagelimit":       coupon.UsageLimit,
+				"percustomerlimit": coupon.PerCustomerLimit,
+				"stackable":        coupon.Stackable,
+				"active":           coupon.Active,
+				"updatedat":        coupon.UpdatedAt,
+			},
+		})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- &coupon
+	}()
+
+	for {
+		select {
+		case coupon := <-couponChan:
+			return coupon, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Delete a coupon, orders keep the discounts they were given
+func (c *CouponServiceStruct) DeleteCoupon(couponId string) (*model.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	couponChan := make(chan *model.Coupon, 32)
+	errChan := make(chan error, 32)
+
+	couponObjID, err := primitive.ObjectIDFromHex(couponId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid couponId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(couponChan)
+
+		var coupon model.Coupon
+		err := c.db.Database("go-ecomm").Collection("coupons").FindOneAndDelete(ctx, bson.M{"_id": couponObjID}).Decode(&coupon)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		couponChan <- &coupon
+	}()
+
+	for {
+		select {
+		case coupon := <-couponChan:
+			return coupon, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Checks the codes against the lines and works out the discount of each
+// coupon. The discount given on every line is added to lineDiscounts, which
+// may already hold the discounts of promotions.
+func evaluateCoupons(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, codes []string, lines []pricedLine, lineDiscounts []model.Money, currency string) ([]model.AppliedDiscount, error) {
+	if len(codes) == 0 {
+		return []model.AppliedDiscount{}, nil
+	}
+
+	coupons, err := findCoupons(ctx, db, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	// minimum spends are checked against the price after promotions
+	subtotal := model.ZeroMoney(currency)
+	for i, line := range lines {
+		if subtotal, err = subtotal.Add(line.Total); err == nil {
+			subtotal, err = subtotal.Sub(lineDiscounts[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	discounts := make([]model.AppliedDiscount, 0, len(coupons))
+	for _, coupon := range coupons {
+		if !coupon.IsValidAt(now) {
+			return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s is not valid", coupon.Code), Code: 400}
+		}
+		if len(coupons) > 1 && !coupon.Stackable {
+			return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s can't be combined with other coupons", coupon.Code), Code: 400}
+		}
+		if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+			return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s has been used up", coupon.Code), Code: 400}
+		}
+		if coupon.PerCustomerLimit > 0 {
+			var usage model.CouponUsage
+			err := db.Database("go-ecomm").Collection("coupon_usage").FindOne(ctx, bson.M{
+				"couponid": coupon.ID,
+				"userid":   userId,
+			}).Decode(&usage)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return nil, err
+			}
+			if usage.Count >= coupon.PerCustomerLimit {
+				return nil, model.ErrMsg{Err: fmt.Errorf("you have already used coupon %s", coupon.Code), Code: 400}
+			}
+		}
+		if coupon.MinSpend != nil {
+			minSpend, err := convertMoney(ctx, db, *coupon.MinSpend, currency)
+			if err != nil {
+				return nil, err
+			}
+			if subtotal.Amount < minSpend.Amount {
+				return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s needs a minimum spend of %s", coupon.Code, minSpend), Code: 400}
+			}
+		}
+
+		remaining, err := remainingLines(lines, lineDiscounts)
+		if err != nil {
+			return nil, err
+		}
+		eligible := make([]int, 0, len(lines))
+		for i, line := range lines {
+			if coupon.Applies(line.ProductID, line.Categories) {
+				eligible = append(eligible, i)
+			}
+		}
+		if len(eligible) == 0 && coupon.Type != model.CouponFreeShipping {
+			return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s doesn't apply to any product in your cart", coupon.Code), Code: 400}
+		}
+
+		var off []model.Money
+		switch coupon.Type {
+		case model.CouponPercentage:
+			off, err = percentOff(remaining, eligible, coupon.PercentOff)
+		case model.CouponFixedAmount:
+			var amountOff model.Money
+			if amountOff, err = convertMoney(ctx, db, coupon.AmountOff, currency); err == nil {
+				off, err = spreadDiscount(amountOff, remaining, eligible)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		applied := model.AppliedDiscount{
+			CouponID:     coupon.ID,
+			Code:         coupon.Code,
+			Type:         coupon.Type,
+			FreeShipping: coupon.Type == model.CouponFreeShipping,
+		}
+		if applied.Amount, err = addLineDiscounts(lineDiscounts, off, currency); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, applied)
+	}
+	return discounts, nil
+}
+
+// coupons for the codes in the order they were given, unknown codes are an error
+func findCoupons(ctx context.Context, db *mongo.Client, codes []string) ([]model.Coupon, error) {
+	normalized := make([]string, 0, len(codes))
+	seen := map[string]bool{}
+	for _, code := range codes {
+		code = model.NormalizeCouponCode(code)
+		if !seen[code] {
+			seen[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+
+	cur, err := db.Database("go-ecomm").Collection("coupons").Find(ctx, bson.M{"code": bson.M{"$in": normalized}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	byCode := map[string]model.Coupon{}
+	for cur.Next(ctx) {
+		var coupon model.Coupon
+		if err := cur.Decode(&coupon); err != nil {
+			return nil, err
+		}
+		byCode[coupon.Code] = coupon
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	coupons := make([]model.Coupon, 0, len(normalized))
+	for _, code := range normalized {
+		coupon, ok := byCode[code]
+		if !ok {
+			return nil, model.ErrMsg{Err: fmt.Errorf("coupon %s not found", code), Code: 404}
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, nil
+}
+
+// amount in another currency at the current rate
+func convertMoney(ctx context.Context, db *mongo.Client, m model.Money, currency string) (model.Money, error) {
+	if m.Currency == currency || currency == "" {
+		return m, nil
+	}
+	rate, err := findRate(ctx, db, m.Currency, currency)
+	if err != nil {
+		return model.Money{}, err
+	}
+	return m.Convert(currency, rate.RateMicros)
+}
+
+// Counts the use of every coupon, in total and by the customer. Both limits
+// are checked again by the updates themselves so two orders can't both take
+// the last use.
+func redeemCoupons(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, discounts []model.AppliedDiscount) error {
+	collection := db.Database("go-ecomm").Collection("coupons")
+	for n, discount := range discounts {
+		var coupon model.Coupon
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"_id": discount.CouponID,
+				"$or": bson.A{
+					bson.M{"usagelimit": 0},
+					bson.M{"$expr": bson.M{"$lt": bson.A{"$usedcount", "$usagelimit"}}},
+				},
+			},
+			bson.M{"$inc": bson.M{"usedcount": 1}},
+			options.FindOneAndUpdate().SetProjection(bson.M{"percustomerlimit": 1}),
+		).Decode(&coupon)
+		if err == mongo.ErrNoDocuments {
+			err = model.ErrMsg{Err: fmt.Errorf("coupon %s has been used up", discount.Code), Code: 409}
+		}
+		if err == nil && coupon.PerCustomerLimit > 0 {
+			if err = reserveCouponUsage(ctx, db, userId, &discount, coupon.PerCustomerLimit); err != nil {
+				releaseCoupon(ctx, db, &discount)
+			}
+		}
+		if err != nil {
+			releaseCoupons(ctx, db, userId, discounts[:n])
+			return err
+		}
+	}
+	return nil
+}
+
+// takes one of the uses the customer has of a coupon. A counter at the limit
+// doesn't match, so the upsert runs into the unique index instead.
+func reserveCouponUsage(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, discount *model.AppliedDiscount, limit int) error {
+	_, err := db.Database("go-ecomm").Collection("coupon_usage").UpdateOne(ctx,
+		bson.M{
+			"couponid": discount.CouponID,
+			"userid":   userId,
+			"count":    bson.M{"$lt": limit},
+		},
+		bson.M{"$inc": bson.M{"count": 1}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return model.ErrMsg{Err: fmt.Errorf("you have already used coupon %s", discount.Code), Code: 409}
+	}
+	return err
+}
+
+// gives back the uses taken by redeemCoupons when the order could not be saved
+func releaseCoupons(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, discounts []model.AppliedDiscount) {
+	for _, discount := range discounts {
+		releaseCoupon(ctx, db, &discount)
+		// coupons without a per customer limit have no counter to match
+		_, err := db.Database("go-ecomm").Collection("coupon_usage").UpdateOne(ctx,
+			bson.M{"couponid": discount.CouponID, "userid": userId, "count": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"count": -1}},
+		)
+		if err != nil {
+			fmt.Println("failed to release coupon", discount.Code+":", err)
+		}
+	}
+}
+
+func releaseCoupon(ctx context.Context, db *mongo.Client, discount *model.AppliedDiscount) {
+	_, err := db.Database("go-ecomm").Collection("coupons").UpdateOne(ctx,
+		bson.M{"_id": discount.CouponID},
+		bson.M{"$inc": bson.M{"usedcount": -1}},
+	)
+	if err != nil {
+		fmt.Println("failed to release coupon", discount.Code+":", err)
+	}
+}
+
+// one redemption per coupon used by the order, the history of its coupons
+func recordRedemptions(ctx context.Context, db *mongo.Client, order *model.Order) error {
+	if len(order.Discounts) == 0 {
+		return nil
+	}
+	redemptions := make([]interface{}, 0, len(order.Discounts))
+	for _, discount := range order.Discounts {
+		redemptions = append(redemptions, model.CouponRedemption{
+			ID:        primitive.NewObjectID(),
+			CouponID:  discount.CouponID,
+			Code:      discount.Code,
+			OrderID:   order.ID,
+			UserId:    order.UserId,
+			Discount:  discount.Amount,
+			CreatedAt: time.Now(),
+		})
+	}
+	_, err := db.Database("go-ecomm").Collection("coupon_redemptions").InsertMany(ctx, redemptions)
+	return err
+}
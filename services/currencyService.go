@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CurrencyService interface {
+	SetRate(payload *request.ExchangeRatePayload) (*model.ExchangeRate, error)
+	GetRates() (*[]model.ExchangeRate, error)
+	DeleteRate(from, to string) (*model.ExchangeRate, error)
+	ResolveCurrency(requested, userId string) (string, error)
+	PriceProducts(products []model.Product, currency string) error
+}
+
+type CurrencyServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewCurrencyService(db *mongo.Client) *CurrencyServiceStruct {
+	return &CurrencyServiceStruct{
+		db: db,
+	}
+}
+
+// Create or replace the rate between two currencies
+func (c *CurrencyServiceStruct) SetRate(payload *request.ExchangeRatePayload) (*model.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	rateChan := make(chan *model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	from, to := strings.ToUpper(payload.From), strings.ToUpper(payload.To)
+	if !model.IsValidCurrency(from) || !model.IsValidCurrency(to) {
+		return nil, model.ErrMsg{Err: fmt.Errorf("unsupported currency"), Code: 400}
+	}
+	if from == to {
+		return nil, model.ErrMsg{Err: fmt.Errorf("a rate needs two different currencies"), Code: 400}
+	}
+
+	rate := model.NewExchangeRate(from, to, payload.Rate)
+	if rate.RateMicros <= 0 {
+		return nil, model.ErrMsg{Err: fmt.Errorf("rate is too small"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(rateChan)
+
+		_, err := c.db.Database("go-ecomm").Collection("exchange_rates").ReplaceOne(ctx,
+			bson.M{"_id": rate.ID},
+			rate,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- rate
+	}()
+
+	for {
+		select {
+		case rate := <-rateChan:
+			return rate, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// All rates of the exchange-rate table
+func (c *CurrencyServiceStruct) GetRates() (*[]model.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	ratesChan := make(chan *[]model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(ratesChan)
+
+		cur, err := c.db.Database("go-ecomm").Collection("exchange_rates").Find(ctx, bson.M{},
+			options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		rates := []model.ExchangeRate{}
+		if err := cur.All(ctx, &rates); err != nil {
+			errChan <- err
+			return
+		}
+		ratesChan <- &rates
+	}()
+
+	for {
+		select {
+		case rates := <-ratesChan:
+			return rates, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Remove a rate, orders keep the rate they were placed with
+func (c *CurrencyServiceStruct) DeleteRate(from, to string) (*model.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	rateChan := make(chan *model.ExchangeRate, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(rateChan)
+
+		var rate model.ExchangeRate
+		err := c.db.Database("go-ecomm").Collection("exchange_rates").FindOneAndDelete(ctx, bson.M{
+			"_id": model.ExchangeRateID(strings.ToUpper(from), strings.ToUpper(to)),
+		}).Decode(&rate)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- &rate
+	}()
+
+	for {
+		select {
+		case rate := <-rateChan:
+			return rate, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Currency the customer wants to see, the requested one (header or query)
+// wins over the one saved in the profile. Empty means the product's own price.
+func (c *CurrencyServiceStruct) ResolveCurrency(requested, userId string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	return resolveCurrency(ctx, c.db, requested, userId)
+}
+
+// Fill the display price of every product in the currency. Products without a
+// price or a rate for it keep showing only their base price.
+func (c *CurrencyServiceStruct) PriceProducts(products []model.Product, currency string) error {
+	if currency == "" {
+		return nil
+	}
+	if !model.IsValidCurrency(currency) {
+		return model.ErrMsg{Err: fmt.Errorf("unsupported currency %q", currency), Code: 400}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	for i := range products {
+		price, _, err := priceProduct(ctx, c.db, &products[i], currency)
+		if err != nil {
+			if errors.Is(err, errNoRate) {
+				continue
+			}
+			return err
+		}
+		products[i].DisplayPrice = &price
+	}
+	return nil
+}
+
+var errNoRate = errors.New("no exchange rate")
+
+func resolveCurrency(ctx context.Context, db *mongo.Client, requested, userId string) (string, error) {
+	if requested != "" {
+		requested = strings.ToUpper(requested)
+		if !model.IsValidCurrency(requested) {
+			return "", model.ErrMsg{Err: fmt.Errorf("unsupported currency %q", requested), Code: 400}
+		}
+		return requested, nil
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return "", nil
+	}
+	var user model.User
+	err = db.Database("go-ecomm").Collection("users").FindOne(ctx, bson.M{"_id": userObjID},
+		options.FindOne().SetProjection(bson.M{"currency": 1})).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", err
+	}
+	return user.Currency, nil
+}
+
+// Price of the product in the currency, either its explicit price or the base
+// price converted with the current rate. The rate is returned so orders can
+// keep it.
+func priceProduct(ctx context.Context, db *mongo.Client, prod *model.Product, currency string) (model.Money, *model.AppliedRate, error) {
+	if price, ok := prod.PriceIn(currency); ok || currency == "" {
+		if !ok {
+			price = prod.Price
+		}
+		return price, nil, nil
+	}
+
+	rate, err := findRate(ctx, db, prod.Price.Currency, currency)
+	if err != nil {
+		return model.Money{}, nil, err
+	}
+	price, err := prod.Price.Convert(currency, rate.RateMicros)
+	if err != nil {
+		return model.Money{}, nil, err
+	}
+	return price, rate, nil
+}
+
+// Rate from one currency to another, taken from the table directly, as the
+// inverse of the opposite rate, or through the default currency.
+func findRate(ctx context.Context, db *mongo.Client, from, to string) (*model.AppliedRate, error) {
+	collection := db.Database("go-ecomm").Collection("exchange_rates")
+
+	lookup := func(from, to string) (int64, error) {
+		var rate model.ExchangeRate
+		err := collection.FindOne(ctx, bson.M{"_id": model.ExchangeRateID(from, to)}).Decode(&rate)
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return rate.RateMicros, err
+	}
+	direct := func(from, to string) (int64, error) {
+		micros, err := lookup(from, to)
+		if err != nil || micros > 0 {
+			return micros, err
+		}
+		inverse, err := lookup(to, from)
+		if err != nil || inverse == 0 {
+			return 0, err
+		}
+		return divideRates(model.RateScale, inverse), nil
+	}
+
+	micros, err := direct(from, to)
+	if err != nil {
+		return nil, err
+	}
+	pivot := model.DefaultCurrency
+	if micros == 0 && from != pivot && to != pivot {
+		first, err := direct(from, pivot)
+		if err != nil {
+			return nil, err
+		}
+		second, err := direct(pivot, to)
+		if err != nil {
+			return nil, err
+		}
+		if first > 0 && second > 0 {
+			micros = multiplyRates(first, second)
+		}
+	}
+	if micros <= 0 {
+		return nil, model.ErrMsg{Err: fmt.Errorf("%w from %s to %s", errNoRate, from, to), Code: 400}
+	}
+	return &model.AppliedRate{From: from, To: to, RateMicros: micros}, nil
+}
+
+// a / b on fixed point rates, rounded half to even
+func divideRates(a, b int64) int64 {
+	rate, err := model.Money{Amount: a}.MulRat(model.RateScale, b)
+	if err != nil {
+		return 0
+	}
+	return rate.Amount
+}
+
+// a * b on fixed point rates, rounded half to even
+func multiplyRates(a, b int64) int64 {
+	rate, err := model.Money{Amount: a}.MulRat(b, model.RateScale)
+	if err != nil {
+		return 0
+	}
+	return rate.Amount
+}
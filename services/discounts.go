@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// a cart or order line priced in the currency of the checkout
+type pricedLine struct {
+	ProductID  primitive.ObjectID
+	Categories []string
+	// tax category of the product
+	TaxCategory string
+	// shipping weight of one unit in grams
+	WeightGrams int
+	UnitPrice   model.Money
+	Quantity    int
+	Total       model.Money
+}
+
+// Runs the automatic promotions and then the coupon codes over the lines. The
+// result explains every discount, lineDiscounts holds the discount of every
+// line so it can be stored on the order.
+func applyDiscounts(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, codes []string, lines []pricedLine, currency string) (*model.PromotionResult, []model.Money, error) {
+	promotions, err := activePromotions(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return applyPromotionsAndCoupons(ctx, db, userId, codes, lines, currency, promotions)
+}
+
+func applyPromotionsAndCoupons(ctx context.Context, db *mongo.Client, userId primitive.ObjectID, codes []string, lines []pricedLine, currency string, promotions []model.Promotion) (*model.PromotionResult, []model.Money, error) {
+	lineDiscounts := make([]model.Money, len(lines))
+	for i := range lineDiscounts {
+		lineDiscounts[i] = model.ZeroMoney(currency)
+	}
+
+	convert := func(m model.Money) (model.Money, error) {
+		return convertMoney(ctx, db, m, currency)
+	}
+	applied, skipped, err := runPromotions(promotions, lines, lineDiscounts, currency, time.Now(), convert)
+	if err != nil {
+		return nil, nil, err
+	}
+	coupons, err := evaluateCoupons(ctx, db, userId, codes, lines, lineDiscounts, currency)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &model.PromotionResult{
+		Currency: currency,
+		Subtotal: model.ZeroMoney(currency),
+		Discount: model.ZeroMoney(currency),
+		Tax:      model.ZeroMoney(currency),
+		TaxLines: []model.TaxLine{},
+		Applied:  applied,
+		Skipped:  skipped,
+		Coupons:  coupons,
+		Lines:    make([]model.PromotionLine, 0, len(lines)),
+	}
+	for i, line := range lines {
+		if result.Subtotal, err = result.Subtotal.Add(line.Total); err != nil {
+			return nil, nil, err
+		}
+		if result.Discount, err = result.Discount.Add(lineDiscounts[i]); err != nil {
+			return nil, nil, err
+		}
+		result.Lines = append(result.Lines, model.PromotionLine{
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Total:     line.Total,
+			Discount:  lineDiscounts[i],
+			Tax:       model.ZeroMoney(currency),
+		})
+	}
+	if result.Total, err = result.Subtotal.Sub(result.Discount); err != nil {
+		return nil, nil, err
+	}
+	return result, lineDiscounts, nil
+}
+
+// promotions that are switched on, their time window is checked by runPromotions
+func activePromotions(ctx context.Context, db *mongo.Client) ([]model.Promotion, error) {
+	cur, err := db.Database("go-ecomm").Collection("promotions").Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	promotions := []model.Promotion{}
+	if err := cur.All(ctx, &promotions); err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+// Evaluates the promotions from the highest priority down and adds their
+// discounts to lineDiscounts. Every promotion ends up either applied or
+// skipped with the reason.
+func runPromotions(promotions []model.Promotion, lines []pricedLine, lineDiscounts []model.Money, currency string, now time.Time, convert func(model.Money) (model.Money, error)) ([]model.PromotionDiscount, []model.PromotionSkip, error) {
+	sorted := make([]model.Promotion, len(promotions))
+	copy(sorted, promotions)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if sorted[a].Priority != sorted[b].Priority {
+			return sorted[a].Priority > sorted[b].Priority
+		}
+		return sorted[a].CreatedAt.Before(sorted[b].CreatedAt)
+	})
+
+	applied := []model.PromotionDiscount{}
+	skipped := []model.PromotionSkip{}
+	skip := func(p model.Promotion, reason string) {
+		skipped = append(skipped, model.PromotionSkip{PromotionID: p.ID, Name: p.Name, Reason: reason})
+	}
+
+	for n, promotion := range sorted {
+		if !promotion.IsValidAt(now) {
+			skip(promotion, "the promotion is not running")
+			continue
+		}
+		if promotion.Exclusive && len(applied) > 0 {
+			skip(promotion, "exclusive promotions can't be combined with the promotions already applied")
+			continue
+		}
+
+		remaining, err := remainingLines(lines, lineDiscounts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var off []model.Money
+		var explanation, reason string
+		switch promotion.Type {
+		case model.PromotionBuyXGetY:
+			off, explanation, reason, err = buyXGetY(&promotion, lines, remaining)
+		case model.PromotionTiered:
+			off, explanation, reason, err = tieredDiscount(&promotion, lines, remaining, currency, convert)
+		case model.PromotionBundle:
+			off, explanation, reason, err = bundleDiscount(&promotion, lines, remaining, currency, convert)
+		default:
+			reason = fmt.Sprintf("unknown promotion type %q", promotion.Type)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if reason != "" {
+			skip(promotion, reason)
+			continue
+		}
+
+		amount, err := addLineDiscounts(lineDiscounts, off, currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		if amount.IsZero() {
+			skip(promotion, "nothing left to discount")
+			continue
+		}
+
+		productIDs := []primitive.ObjectID{}
+		for i, o := range off {
+			if !o.IsZero() {
+				productIDs = append(productIDs, lines[i].ProductID)
+			}
+		}
+		applied = append(applied, model.PromotionDiscount{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Type:        promotion.Type,
+			Amount:      amount,
+			Explanation: explanation,
+			ProductIDs:  productIDs,
+		})
+
+		if promotion.Exclusive {
+			for _, rest := range sorted[n+1:] {
+				skip(rest, fmt.Sprintf("exclusive promotion %q was applied", promotion.Name))
+			}
+			break
+		}
+	}
+	return applied, skipped, nil
+}
+
+// every BuyQuantity+GetQuantity eligible units, the GetQuantity cheapest are discounted
+func buyXGetY(p *model.Promotion, lines []pricedLine, remaining []model.Money) ([]model.Money, string, string, error) {
+	type unit struct {
+		line  int
+		price model.Money
+	}
+	units := []unit{}
+	for i, line := range lines {
+		if !p.Applies(line.ProductID, line.Categories) {
+			continue
+		}
+		for q := 0; q < line.Quantity; q++ {
+			units = append(units, unit{line: i, price: line.UnitPrice})
+		}
+	}
+	group := p.BuyQuantity + p.GetQuantity
+	if len(units) < group {
+		return nil, "", fmt.Sprintf("needs %d eligible items, the cart has %d", group, len(units)), nil
+	}
+	sort.SliceStable(units, func(a, b int) bool {
+		return units[a].price.Amount > units[b].price.Amount
+	})
+
+	off := zeroLines(len(lines), remaining)
+	discounted := 0
+	for start := 0; start+group <= len(units); start += group {
+		for _, u := range units[start+p.BuyQuantity : start+group] {
+			unitOff, err := u.price.MulRat(int64(p.GetPercentOff), 100)
+			if err != nil {
+				return nil, "", "", err
+			}
+			if off[u.line], err = off[u.line].Add(unitOff); err != nil {
+				return nil, "", "", err
+			}
+			discounted++
+		}
+	}
+	if err := capLines(off, remaining); err != nil {
+		return nil, "", "", err
+	}
+
+	deal := "free"
+	if p.GetPercentOff < 100 {
+		deal = fmt.Sprintf("at %d%% off", p.GetPercentOff)
+	}
+	return off, fmt.Sprintf("buy %d get %d %s: %d item(s) discounted", p.BuyQuantity, p.GetQuantity, deal, discounted), "", nil
+}
+
+// the highest tier reached by the eligible spend applies
+func tieredDiscount(p *model.Promotion, lines []pricedLine, remaining []model.Money, currency string, convert func(model.Money) (model.Money, error)) ([]model.Money, string, string, error) {
+	eligible := []int{}
+	spend := model.ZeroMoney(currency)
+	var err error
+	for i, line := range lines {
+		if p.Applies(line.ProductID, line.Categories) {
+			eligible = append(eligible, i)
+			if spend, err = spend.Add(remaining[i]); err != nil {
+				return nil, "", "", err
+			}
+		}
+	}
+
+	var best *model.PromotionTier
+	var bestMin, lowest model.Money
+	for i := range p.Tiers {
+		minSpend, err := convert(p.Tiers[i].MinSpend)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if lowest.Currency == "" || minSpend.Amount < lowest.Amount {
+			lowest = minSpend
+		}
+		if minSpend.Amount <= spend.Amount && (best == nil || minSpend.Amount > bestMin.Amount) {
+			best, bestMin = &p.Tiers[i], minSpend
+		}
+	}
+	if best == nil {
+		missing, err := lowest.Sub(spend)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return nil, "", fmt.Sprintf("spend %s more on eligible products to reach the first tier", missing), nil
+	}
+
+	if best.AmountOff != nil {
+		amountOff, err := convert(*best.AmountOff)
+		if err != nil {
+			return nil, "", "", err
+		}
+		off, err := spreadDiscount(amountOff, remaining, eligible)
+		return off, fmt.Sprintf("%s off for spending over %s", amountOff, bestMin), "", err
+	}
+	off, err := percentOff(remaining, eligible, best.PercentOff)
+	return off, fmt.Sprintf("%d%% off for spending over %s", best.PercentOff, bestMin), "", err
+}
+
+// one of each bundle product makes a bundle, as many bundles as the cart holds
+func bundleDiscount(p *model.Promotion, lines []pricedLine, remaining []model.Money, currency string, convert func(model.Money) (model.Money, error)) ([]model.Money, string, string, error) {
+	bundleLines := []int{}
+	count := -1
+	for _, productID := range p.BundleProductIDs {
+		found := -1
+		for i, line := range lines {
+			if line.ProductID == productID {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return nil, "", "the cart doesn't hold every product of the bundle", nil
+		}
+		bundleLines = append(bundleLines, found)
+		if count < 0 || lines[found].Quantity < count {
+			count = lines[found].Quantity
+		}
+	}
+
+	// value of the bundled units on every line
+	weights := zeroLines(len(lines), remaining)
+	bundleValue := model.ZeroMoney(currency)
+	for _, i := range bundleLines {
+		value, err := lines[i].UnitPrice.Mul(int64(count))
+		if err == nil {
+			weights[i] = value
+			bundleValue, err = bundleValue.Add(value)
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	var off []model.Money
+	var explanation string
+	if p.BundlePrice != nil {
+		bundlePrice, err := convert(*p.BundlePrice)
+		if err != nil {
+			return nil, "", "", err
+		}
+		total, err := bundlePrice.Mul(int64(count))
+		if err != nil {
+			return nil, "", "", err
+		}
+		saving, err := bundleValue.Sub(total)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if !saving.IsNegative() && !saving.IsZero() {
+			if off, err = spreadDiscount(saving, weights, bundleLines); err != nil {
+				return nil, "", "", err
+			}
+		}
+		explanation = fmt.Sprintf("%d bundle(s) for %s each", count, bundlePrice)
+	} else {
+		var err error
+		if off, err = percentOff(weights, bundleLines, p.BundlePercentOff); err != nil {
+			return nil, "", "", err
+		}
+		explanation = fmt.Sprintf("%d bundle(s) at %d%% off", count, p.BundlePercentOff)
+	}
+	if off == nil {
+		return nil, "", "the bundle price is not lower than the products on their own", nil
+	}
+	if err := capLines(off, remaining); err != nil {
+		return nil, "", "", err
+	}
+	return off, explanation, "", nil
+}
+
+// what is left to discount on every line
+func remainingLines(lines []pricedLine, lineDiscounts []model.Money) ([]model.Money, error) {
+	remaining := make([]model.Money, len(lines))
+	for i, line := range lines {
+		left, err := line.Total.Sub(lineDiscounts[i])
+		if err != nil {
+			return nil, err
+		}
+		remaining[i] = left
+	}
+	return remaining, nil
+}
+
+// a percentage of the weight of every eligible line
+func percentOff(weights []model.Money, eligible []int, percent int) ([]model.Money, error) {
+	off := zeroLines(len(weights), weights)
+	for _, i := range eligible {
+		lineOff, err := weights[i].MulRat(int64(percent), 100)
+		if err != nil {
+			return nil, err
+		}
+		off[i] = lineOff
+	}
+	return off, nil
+}
+
+// Spreads an amount over the eligible lines by their weight, never more than
+// the total weight. The last line takes the rounding difference.
+func spreadDiscount(amount model.Money, weights []model.Money, eligible []int) ([]model.Money, error) {
+	off := zeroLines(len(weights), weights)
+	if len(eligible) == 0 {
+		return off, nil
+	}
+
+	total := model.ZeroMoney(amount.Currency)
+	var err error
+	for _, i := range eligible {
+		if total, err = total.Add(weights[i]); err != nil {
+			return nil, err
+		}
+	}
+	if amount, err = amount.Min(total); err != nil {
+		return nil, err
+	}
+
+	left := amount
+	for n, i := range eligible {
+		share := left
+		if n < len(eligible)-1 && total.Amount > 0 {
+			if share, err = amount.MulRat(weights[i].Amount, total.Amount); err == nil {
+				share, err = share.Min(left)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		off[i] = share
+		if left, err = left.Sub(share); err != nil {
+			return nil, err
+		}
+	}
+	return off, nil
+}
+
+// adds the discounts to the lines and returns their sum
+func addLineDiscounts(lineDiscounts, off []model.Money, currency string) (model.Money, error) {
+	sum := model.ZeroMoney(currency)
+	var err error
+	for i := range off {
+		if lineDiscounts[i], err = lineDiscounts[i].Add(off[i]); err != nil {
+			return model.Money{}, err
+		}
+		if sum, err = sum.Add(off[i]); err != nil {
+			return model.Money{}, err
+		}
+	}
+	return sum, nil
+}
+
+// no line can be discounted below zero
+func capLines(off, remaining []model.Money) error {
+	for i := range off {
+		capped, err := off[i].Min(remaining[i])
+		if err != nil {
+			return err
+		}
+		off[i] = capped
+	}
+	return nil
+}
+
+func zeroLines(n int, like []model.Money) []model.Money {
+	zero := make([]model.Money, n)
+	for i := range zero {
+		if i < len(like) {
+			zero[i] = model.ZeroMoney(like[i].Currency)
+		}
+	}
+	return zero
+}
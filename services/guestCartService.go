@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Add a product to a guest cart. A new cart is started when there is no id
+// or the cart has expired. Every change pushes the expiry back.
+func (c *CartServiceStruct) AddToGuestCart(cart *request.AddToCartPayload, guestCartId string) (*model.GuestCart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.GuestCart, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(cart.ProductID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		db := c.db.Database("go-ecomm")
+
+		var prod model.Product
+		err := db.Collection("products").FindOne(ctx, bson.M{"_id": productObjID}).Decode(&prod)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		if !prod.InStock {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product %s is out of stock", prod.Title), Code: 400}
+			return
+		}
+
+		guest, err := c.updateGuestCart(ctx, guestCartId, true, func(guest *model.GuestCart) {
+			guest.Products = model.MergeCartItems(guest.Products,
+				[]model.ProductDetails{{ProductID: productObjID, Quantity: cart.Quantity}},
+				model.CartMergeSum, c.options.MaxLineQuantity)
+		})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- guest
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// A guest cart priced like a saved one
+func (c *CartServiceStruct) GetGuestCart(guestCartId, currency string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		guest, err := c.findGuestCart(ctx, guestCartId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if guest == nil {
+			errChan <- mongo.ErrNoDocuments
+			return
+		}
+
+		products, err := cartProducts(ctx, c.db, guest.Products)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		cart := model.Cart{ID: guest.ID, Products: guest.Products, CouponCodes: []string{}}
+		if err := enrichCart(ctx, c.db, c.taxes, &cart, products, currency); err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- &cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Remove a product from a guest cart
+func (c *CartServiceStruct) RemoveFromGuestCart(guestCartId, productId string) (*model.GuestCart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.GuestCart, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		guest, err := c.updateGuestCart(ctx, guestCartId, false, func(guest *model.GuestCart) {
+			products := make([]model.ProductDetails, 0, len(guest.Products))
+			for _, item := range guest.Products {
+				if item.ProductID != productObjID {
+					products = append(products, item)
+				}
+			}
+			guest.Products = products
+		})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cartChan <- guest
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Move a guest cart into the user's cart with the configured strategy.
+// Products that were deleted or went out of stock are dropped and lines are
+// capped at the per line limit. The guest cart is removed afterwards.
+func (c *CartServiceStruct) MergeGuestCart(guestCartId, userId string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		db := c.db.Database("go-ecomm")
+
+		guest, err := c.findGuestCart(ctx, guestCartId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if guest == nil {
+			errChan <- mongo.ErrNoDocuments
+			return
+		}
+
+		productIDs := make([]primitive.ObjectID, 0, len(guest.Products))
+		for _, item := range guest.Products {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		available := map[primitive.ObjectID]bool{}
+		cur, err := db.Collection("products").Find(ctx,
+			bson.M{"_id": bson.M{"$in": productIDs}, "instock": true},
+			options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		products := []model.Product{}
+		if err := cur.All(ctx, &products); err != nil {
+			errChan <- err
+			return
+		}
+		for _, prod := range products {
+			available[prod.ID] = true
+		}
+		items := make([]model.ProductDetails, 0, len(guest.Products))
+		for _, item := range guest.Products {
+			if available[item.ProductID] {
+				items = append(items, item)
+			}
+		}
+
+		cart, err := mergeIntoCart(ctx, c.db, usrObjID, items, c.options.MergeStrategy, c.options.MaxLineQuantity)
+		if err != nil {
+			errChan <- fmt.Errorf("failed to save merged cart: %w", err)
+			return
+		}
+		if _, err := db.Collection("guest_carts").DeleteOne(ctx, bson.M{"_id": guest.ID}); err != nil {
+			fmt.Println("failed to delete merged guest cart:", err)
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// the guest cart, nil when there is none or it has expired. Expired carts
+// are only removed by the TTL index every minute or so.
+func (c *CartServiceStruct) findGuestCart(ctx context.Context, guestCartId string) (*model.GuestCart, error) {
+	if guestCartId == "" {
+		return nil, nil
+	}
+	guestObjID, err := primitive.ObjectIDFromHex(guestCartId)
+	if err != nil {
+		return nil, nil
+	}
+	var guest model.GuestCart
+	err = c.db.Database("go-ecomm").Collection("guest_carts").FindOne(ctx, bson.M{
+		"_id":       guestObjID,
+		"expiresat": bson.M{"$gt": time.Now()},
+	}).Decode(&guest)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &guest, nil
+}
+
+// Applies change to the guest cart and saves it, pushing the expiry back. The
+// cart is only written if nobody changed it since it was read, otherwise the
+// change is made again on the new contents. Without a live cart one is
+// started if create is set.
+func (c *CartServiceStruct) updateGuestCart(ctx context.Context, guestCartId string, create bool, change func(*model.GuestCart)) (*model.GuestCart, error) {
+	guestCarts := c.db.Database("go-ecomm").Collection("guest_carts")
+	for attempt := 0; attempt < cartMergeAttempts; attempt++ {
+		guest, err := c.findGuestCart(ctx, guestCartId)
+		if err != nil {
+			return nil, err
+		}
+		if guest == nil {
+			if !create {
+				return nil, mongo.ErrNoDocuments
+			}
+			guest = &model.GuestCart{
+				ID:        primitive.NewObjectID(),
+				Products:  []model.ProductDetails{},
+				CreatedAt: time.Now(),
+			}
+			change(guest)
+			guest.UpdatedAt = time.Now()
+			guest.ExpiresAt = guest.UpdatedAt.Add(c.options.GuestTTL)
+			if _, err := guestCarts.InsertOne(ctx, guest); err != nil {
+				return nil, err
+			}
+			return guest, nil
+		}
+
+		previousUpdate := guest.UpdatedAt
+		change(guest)
+		guest.UpdatedAt = time.Now()
+		guest.ExpiresAt = guest.UpdatedAt.Add(c.options.GuestTTL)
+		res, err := guestCarts.UpdateOne(ctx,
+			bson.M{"_id": guest.ID, "updatedat": previousUpdate},
+			bson.M{"$set": bson.M{
+				"products":  guest.Products,
+				"updatedat": guest.UpdatedAt,
+				"expiresat": guest.ExpiresAt,
+			}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 1 {
+			return guest, nil
+		}
+	}
+	return nil, model.ErrMsg{Err: fmt.Errorf("the cart kept changing, try again"), Code: 409}
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/config"
+	"github.com/souvikjs01/go-ecommerce/imaging"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// product images live below this prefix of the file storage, nothing else in
+// it is served to the public
+const productImagePrefix = "products/"
+
+type ImageService interface {
+	UploadProductImages(productId string, uploads []request.ImageUpload) (*model.Product, error)
+	ReorderProductImages(productId string, payload *request.ReorderImagesPayload) (*model.Product, error)
+	DeleteProductImage(productId, imageId string) (*model.Product, error)
+	OpenImage(key string) (io.ReadCloser, string, error)
+}
+
+type ImageOptions struct {
+	// URLs of the images are this followed by their storage key
+	BaseURL       string
+	MaxPerProduct int
+	// widths of the resized copies
+	Widths []int
+}
+
+func NewImageOptions(cfg *config.Config) ImageOptions {
+	return ImageOptions{
+		BaseURL:       cfg.IMAGE_BASE_URL,
+		MaxPerProduct: cfg.IMAGE_MAX_PER_PRODUCT,
+		Widths:        cfg.IMAGE_WIDTHS,
+	}
+}
+
+type ImageServiceStruct struct {
+	db      *mongo.Client
+	store   storage.Storage
+	options ImageOptions
+}
+
+func NewImageService(db *mongo.Client, store storage.Storage, options ImageOptions) *ImageServiceStruct {
+	return &ImageServiceStruct{
+		db:      db,
+		store:   store,
+		options: options,
+	}
+}
+
+// Add images to a product, after the ones it already has. Each one is stored
+// as uploaded along with its resized and WebP copies.
+func (i *ImageServiceStruct) UploadProductImages(productId string, uploads []request.ImageUpload) (*model.Product, error) {
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+	if len(uploads) == 0 {
+		return nil, model.ErrMsg{Err: fmt.Errorf("no images uploaded"), Code: 400}
+	}
+	if len(uploads) > i.options.MaxPerProduct {
+		return nil, model.ErrMsg{Err: fmt.Errorf("a product can have at most %d images", i.options.MaxPerProduct), Code: 400}
+	}
+
+	// resizing is slow, it is done before the clock of the database work starts
+	processed := make([]*imaging.Image, len(uploads))
+	for n, upload := range uploads {
+		img, err := imaging.Process(upload.Data, i.options.Widths)
+		if errors.Is(err, imaging.ErrUnsupported) {
+			return nil, model.ErrMsg{Err: fmt.Errorf("image %d: %w", n+1, err), Code: 415}
+		} else if errors.Is(err, imaging.ErrTooLarge) {
+			return nil, model.ErrMsg{Err: fmt.Errorf("image %d: %w", n+1, err), Code: 413}
+		} else if err != nil {
+			return nil, err
+		}
+		processed[n] = img
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(productChan)
+
+		collection := i.db.Database("go-ecomm").Collection("products")
+
+		var product model.Product
+		err := collection.FindOne(ctx, bson.M{"_id": productObjID}).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		if len(product.Images)+len(uploads) > i.options.MaxPerProduct {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a product can have at most %d images, it has %d", i.options.MaxPerProduct, len(product.Images)), Code: 400}
+			return
+		}
+
+		images := []model.ProductImage{}
+		for n, img := range processed {
+			image, err := i.saveImage(ctx, productObjID, img, uploads[n])
+			if err != nil {
+				fmt.Println("failed to store product image:", err)
+				for _, saved := range images {
+					i.removeFiles(ctx, saved.Keys())
+				}
+				errChan <- fmt.Errorf("failed to store image %d", n+1)
+				return
+			}
+			images = append(images, *image)
+		}
+
+		// refused when uploads running at the same time already filled the
+		// product up
+		err = collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"_id": productObjID,
+				fmt.Sprintf("images.%d", i.options.MaxPerProduct-len(images)): bson.M{"$exists": false},
+			},
+			bson.M{"$push": bson.M{"images": bson.M{"$each": images}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&product)
+		if err != nil {
+			for _, saved := range images {
+				i.removeFiles(ctx, saved.Keys())
+			}
+			if err == mongo.ErrNoDocuments {
+				err = model.ErrMsg{Err: fmt.Errorf("a product can have at most %d images", i.options.MaxPerProduct), Code: 409}
+			}
+			errChan <- err
+			return
+		}
+
+		if err := syncProductImg(ctx, collection, &product); err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- &product
+	}()
+
+	select {
+	case product := <-productChan:
+		return product, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Put the images of a product in a new order, every image has to be listed
+func (i *ImageServiceStruct) ReorderProductImages(productId string, payload *request.ReorderImagesPayload) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+	order := []primitive.ObjectID{}
+	for _, id := range payload.ImageIDs {
+		imageObjID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid imageId %q", id), Code: 400}
+		}
+		if containsID(order, imageObjID) {
+			return nil, model.ErrMsg{Err: fmt.Errorf("image %s is listed twice", id), Code: 400}
+		}
+		order = append(order, imageObjID)
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(productChan)
+
+		collection := i.db.Database("go-ecomm").Collection("products")
+
+		var product model.Product
+		err := collection.FindOne(ctx, bson.M{"_id": productObjID}).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		if len(order) != len(product.Images) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("list all %d images of the product", len(product.Images)), Code: 400}
+			return
+		}
+
+		byID := map[primitive.ObjectID]model.ProductImage{}
+		current := []primitive.ObjectID{}
+		for _, image := range product.Images {
+			byID[image.ID] = image
+			current = append(current, image.ID)
+		}
+		images := []model.ProductImage{}
+		for _, id := range order {
+			image, ok := byID[id]
+			if !ok {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("image %s not found", id.Hex()), Code: 404}
+				return
+			}
+			images = append(images, image)
+		}
+
+		// only if no image was added or removed since it was read
+		err = collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"_id":       productObjID,
+				"images.id": bson.M{"$all": current},
+				"images":    bson.M{"$size": len(current)},
+			},
+			bson.M{"$set": bson.M{"images": images}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("the images of the product changed, try again"), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		if err := syncProductImg(ctx, collection, &product); err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- &product
+	}()
+
+	select {
+	case product := <-productChan:
+		return product, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Remove an image from a product along with its files
+func (i *ImageServiceStruct) DeleteProductImage(productId, imageId string) (*model.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	productChan := make(chan *model.Product, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+	imageObjID, err := primitive.ObjectIDFromHex(imageId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid imageId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(productChan)
+
+		collection := i.db.Database("go-ecomm").Collection("products")
+
+		var before model.Product
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": productObjID, "images.id": imageObjID},
+			bson.M{"$pull": bson.M{"images": bson.M{"id": imageObjID}}},
+		).Decode(&before)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("image not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		product := before
+		product.Images = []model.ProductImage{}
+		for _, image := range before.Images {
+			if image.ID == imageObjID {
+				go removeImageFiles(i.store, []model.ProductImage{image})
+				continue
+			}
+			product.Images = append(product.Images, image)
+		}
+
+		// the last image is gone, img must not point at its removed file
+		if len(product.Images) == 0 && product.Img == before.Images[0].URL {
+			product.Img = ""
+			_, err = collection.UpdateOne(ctx, bson.M{"_id": productObjID}, bson.M{"$set": bson.M{"img": ""}})
+		} else {
+			err = syncProductImg(ctx, collection, &product)
+		}
+		if err != nil {
+			errChan <- err
+			return
+		}
+		productChan <- &product
+	}()
+
+	select {
+	case product := <-productChan:
+		return product, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Open a stored product image, along with its content type
+func (i *ImageServiceStruct) OpenImage(key string) (io.ReadCloser, string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if !strings.HasPrefix(key, productImagePrefix) || strings.Contains(key, "..") {
+		return nil, "", model.ErrMsg{Err: fmt.Errorf("image not found"), Code: 404}
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", model.ErrMsg{Err: fmt.Errorf("image not found"), Code: 404}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	file, err := i.store.Open(ctx, key)
+	if err == storage.ErrNotFound {
+		return nil, "", model.ErrMsg{Err: fmt.Errorf("image not found"), Code: 404}
+	} else if err != nil {
+		return nil, "", err
+	}
+	return file, contentType, nil
+}
+
+// stores the original and the variants of an upload under
+// products/<productId>/<imageId>/
+func (i *ImageServiceStruct) saveImage(ctx context.Context, productId primitive.ObjectID, img *imaging.Image, upload request.ImageUpload) (*model.ProductImage, error) {
+	image := &model.ProductImage{
+		ID:          primitive.NewObjectID(),
+		ContentType: img.ContentType,
+		Width:       img.Width,
+		Height:      img.Height,
+		Size:        int64(len(upload.Data)),
+		Alt:         strings.TrimSpace(upload.Alt),
+		Variants:    []model.ImageVariant{},
+		CreatedAt:   time.Now(),
+	}
+	dir := fmt.Sprintf("%s%s/%s/", productImagePrefix, productId.Hex(), image.ID.Hex())
+	image.Key = dir + "original." + img.Ext
+	image.URL = i.options.BaseURL + "/" + image.Key
+
+	saved := []string{}
+	save := func(key string, data []byte, contentType string) error {
+		if err := i.store.Save(ctx, key, bytes.NewReader(data), contentType); err != nil {
+			i.removeFiles(ctx, saved)
+			return err
+		}
+		saved = append(saved, key)
+		return nil
+	}
+
+	if err := save(image.Key, upload.Data, img.ContentType); err != nil {
+		return nil, err
+	}
+	for _, v := range img.Variants {
+		variant := model.ImageVariant{
+			Width:   v.Width,
+			Height:  v.Height,
+			Key:     fmt.Sprintf("%s%d.%s", dir, v.Width, v.Ext),
+			WebPKey: fmt.Sprintf("%s%d.webp", dir, v.Width),
+		}
+		variant.URL = i.options.BaseURL + "/" + variant.Key
+		variant.WebPURL = i.options.BaseURL + "/" + variant.WebPKey
+		if err := save(variant.Key, v.Data, v.ContentType); err != nil {
+			return nil, err
+		}
+		if err := save(variant.WebPKey, v.WebP, "image/webp"); err != nil {
+			return nil, err
+		}
+		image.Variants = append(image.Variants, variant)
+	}
+	return image, nil
+}
+
+func (i *ImageServiceStruct) removeFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := i.store.Delete(ctx, key); err != nil {
+			fmt.Println("failed to delete image file:", key, err)
+		}
+	}
+}
+
+// deletes the files of images no longer referenced by a product
+func removeImageFiles(store storage.Storage, images []model.ProductImage) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, image := range images {
+		for _, key := range image.Keys() {
+			if err := store.Delete(ctx, key); err != nil {
+				fmt.Println("failed to delete image file:", key, err)
+			}
+		}
+	}
+}
+
+// points img at the first uploaded image, the field older clients read
+func syncProductImg(ctx context.Context, collection *mongo.Collection, product *model.Product) error {
+	if len(product.Images) == 0 || product.Img == product.Images[0].URL {
+		return nil
+	}
+	product.Img = product.Images[0].URL
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": product.ID}, bson.M{"$set": bson.M{"img": product.Img}})
+	return err
+}
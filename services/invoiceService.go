@@ -0,0 +1,510 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/storage"
+	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type InvoiceService interface {
+	IssueInvoice(orderId string) (*model.Invoice, error)
+	IssueCreditNote(orderId string, refund *model.Refund) (*model.Invoice, error)
+	IssueMissingDocuments(orderId string) (*[]model.Invoice, error)
+	GetOrderInvoices(orderId, userId string, isAdmin bool) (*[]model.Invoice, error)
+	OpenInvoice(invoiceId, userId string, isAdmin bool) (*model.Invoice, io.ReadCloser, error)
+}
+
+type InvoiceServiceStruct struct {
+	db    *mongo.Client
+	store storage.Storage
+}
+
+func NewInvoiceService(db *mongo.Client, store storage.Storage) *InvoiceServiceStruct {
+	return &InvoiceServiceStruct{
+		db:    db,
+		store: store,
+	}
+}
+
+// Issue the invoice of a paid order. Calling it again returns the existing invoice.
+func (i *InvoiceServiceStruct) IssueInvoice(orderId string) (*model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	collection := i.db.Database("go-ecomm").Collection("invoices")
+
+	var existing model.Invoice
+	err = collection.FindOne(ctx, bson.M{"orderid": orderObjID, "type": model.InvoiceTypeInvoice}).Decode(&existing)
+	if err == nil {
+		return &existing, nil
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var order model.Order
+	if err := i.db.Database("go-ecomm").Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID}).Decode(&order); err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+
+	lines, err := i.invoiceLines(ctx, order.Products)
+	if err != nil {
+		return nil, err
+	}
+
+	subtotal := model.ZeroMoney(order.Amount.Currency)
+	for _, line := range lines {
+		if subtotal, err = subtotal.Add(line.Total); err != nil {
+			return nil, err
+		}
+	}
+	discount := order.DiscountAmount
+	if discount.Currency == "" {
+		discount = model.ZeroMoney(order.Amount.Currency)
+	}
+	net, err := subtotal.Sub(discount)
+	if err != nil {
+		return nil, err
+	}
+	shipping := order.ShippingCost
+	if shipping.Currency == "" {
+		shipping = model.ZeroMoney(order.Amount.Currency)
+	}
+	// orders placed before tax was recorded charged it on top of the net amount
+	tax := order.Tax
+	if tax.Currency == "" {
+		if tax, err = order.Amount.Sub(net); err != nil {
+			return nil, err
+		}
+	}
+
+	invoice := &model.Invoice{
+		ID:              primitive.NewObjectID(),
+		Type:            model.InvoiceTypeInvoice,
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		UserId:          order.UserId,
+		Lines:           lines,
+		Subtotal:        subtotal,
+		Discount:        discount,
+		Shipping:        shipping,
+		Tax:             tax,
+		TaxInclusive:    order.TaxInclusive,
+		Total:           order.Amount,
+		BillingAddress:  order.BillingAddress,
+		ShippingAddress: order.ShippingAddress,
+		CreatedAt:       time.Now(),
+	}
+	if err := i.saveDocument(ctx, invoice, "INV"); mongo.IsDuplicateKeyError(err) {
+		// issued at the same time by another call, that one counts
+		err = collection.FindOne(ctx, bson.M{"orderid": orderObjID, "type": model.InvoiceTypeInvoice}).Decode(&existing)
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// Issue a credit note for a refund made on an order. Calling it again for the
+// same refund returns the existing credit note.
+func (i *InvoiceServiceStruct) IssueCreditNote(orderId string, refund *model.Refund) (*model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	collection := i.db.Database("go-ecomm").Collection("invoices")
+
+	var existing model.Invoice
+	err = collection.FindOne(ctx, bson.M{"refundid": refund.ID}).Decode(&existing)
+	if err == nil {
+		return &existing, nil
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var order model.Order
+	if err := i.db.Database("go-ecomm").Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID}).Decode(&order); err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+
+	// the credit note lists the returned items when the refund comes from a return
+	var lines []model.InvoiceLine
+	discount := model.ZeroMoney(refund.Amount.Currency)
+	taxShare := model.ZeroMoney(refund.Amount.Currency)
+	var ret model.ReturnRequest
+	err = i.db.Database("go-ecomm").Collection("returns").FindOne(ctx, bson.M{"_id": refund.ReturnID}).Decode(&ret)
+	if err == nil {
+		returned := make([]model.ProductInfo, 0, len(ret.Items))
+		for _, item := range ret.Items {
+			line := model.ProductInfo{ProductID: item.ProductID, Quantity: item.Quantity}
+			for _, p := range order.Products {
+				if p.ProductID == item.ProductID {
+					line.Price = p.Price
+					share, err := p.DiscountFor(item.Quantity)
+					if err == nil {
+						discount, err = discount.Add(share)
+					}
+					if err == nil {
+						share, err = p.TaxFor(item.Quantity)
+					}
+					if err == nil {
+						taxShare, err = taxShare.Add(share)
+					}
+					if err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			returned = append(returned, line)
+		}
+		if lines, err = i.invoiceLines(ctx, returned); err != nil {
+			return nil, err
+		}
+	}
+	if len(lines) == 0 {
+		lines = []model.InvoiceLine{{Description: refund.Reason, Quantity: 1, UnitPrice: refund.Amount, Total: refund.Amount}}
+		discount = model.ZeroMoney(refund.Amount.Currency)
+		taxShare = model.ZeroMoney(refund.Amount.Currency)
+	}
+
+	subtotal := model.ZeroMoney(refund.Amount.Currency)
+	for _, line := range lines {
+		if subtotal, err = subtotal.Add(line.Total); err != nil {
+			return nil, err
+		}
+	}
+	// the refund may be capped below the value of the returned items
+	net, err := subtotal.Sub(discount)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := refund.Amount.Sub(net)
+	if err != nil {
+		return nil, err
+	}
+	// included tax is already part of the item prices
+	if order.TaxInclusive {
+		tax = taxShare
+	}
+
+	refundID := refund.ID
+	note := &model.Invoice{
+		ID:              primitive.NewObjectID(),
+		Type:            model.InvoiceTypeCreditNote,
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		UserId:          order.UserId,
+		RefundID:        &refundID,
+		Lines:           lines,
+		Subtotal:        subtotal,
+		Discount:        discount,
+		Shipping:        model.ZeroMoney(refund.Amount.Currency),
+		Tax:             tax,
+		TaxInclusive:    order.TaxInclusive,
+		Total:           refund.Amount,
+		BillingAddress:  order.BillingAddress,
+		ShippingAddress: order.ShippingAddress,
+		CreatedAt:       time.Now(),
+	}
+	if err := i.saveDocument(ctx, note, "CN"); mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOne(ctx, bson.M{"refundid": refund.ID}).Decode(&existing)
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Issue whatever failed to be issued when it was due: the invoice of a paid
+// order and the credit notes of its refunds. Returns all documents of the order.
+func (i *InvoiceServiceStruct) IssueMissingDocuments(orderId string) (*[]model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	var order model.Order
+	err = i.db.Database("go-ecomm").Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID},
+		options.FindOne().SetProjection(bson.M{"paymentstatus": 1, "refunds": 1})).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return nil, model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if order.PaymentStatus == model.PaymentStatusPaid {
+		if _, err := i.IssueInvoice(orderId); err != nil {
+			return nil, fmt.Errorf("failed to issue invoice: %w", err)
+		}
+	}
+	for n := range order.Refunds {
+		if _, err := i.IssueCreditNote(orderId, &order.Refunds[n]); err != nil {
+			return nil, fmt.Errorf("failed to issue credit note: %w", err)
+		}
+	}
+	return i.GetOrderInvoices(orderId, "", true)
+}
+
+// invoices and credit notes of an order, for its owner or an admin
+func (i *InvoiceServiceStruct) GetOrderInvoices(orderId, userId string, isAdmin bool) (*[]model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	invoicesChan := make(chan *[]model.Invoice, 32)
+	errChan := make(chan error, 32)
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	filter := bson.M{"orderid": orderObjID}
+	if !isAdmin {
+		userObjID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+		}
+		filter["userid"] = userObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(invoicesChan)
+
+		cur, err := i.db.Database("go-ecomm").Collection("invoices").Find(ctx, filter,
+			options.Find().SetSort(bson.M{"createdat": 1}),
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		invoices := []model.Invoice{}
+		if err := cur.All(ctx, &invoices); err != nil {
+			errChan <- err
+			return
+		}
+		invoicesChan <- &invoices
+	}()
+
+	select {
+	case invoices := <-invoicesChan:
+		return invoices, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Open the PDF of an invoice, only the order owner and admins may read it
+func (i *InvoiceServiceStruct) OpenInvoice(invoiceId, userId string, isAdmin bool) (*model.Invoice, io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	invoiceObjID, err := primitive.ObjectIDFromHex(invoiceId)
+	if err != nil {
+		return nil, nil, model.ErrMsg{Err: fmt.Errorf("invalid invoiceId"), Code: 400}
+	}
+
+	var invoice model.Invoice
+	err = i.db.Database("go-ecomm").Collection("invoices").FindOne(ctx, bson.M{"_id": invoiceObjID}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, model.ErrMsg{Err: fmt.Errorf("invoice not found"), Code: 404}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// don't reveal that the invoice exists to other customers
+	if !isAdmin && invoice.UserId.Hex() != userId {
+		return nil, nil, model.ErrMsg{Err: fmt.Errorf("invoice not found"), Code: 404}
+	}
+
+	file, err := i.store.Open(context.Background(), invoice.StorageKey)
+	if err == storage.ErrNotFound {
+		return nil, nil, model.ErrMsg{Err: fmt.Errorf("invoice document is missing"), Code: 404}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return &invoice, file, nil
+}
+
+// numbers the document, renders the PDF into storage and saves the record
+func (i *InvoiceServiceStruct) saveDocument(ctx context.Context, invoice *model.Invoice, prefix string) error {
+	year := invoice.CreatedAt.Year()
+	seq, err := nextSequence(ctx, i.db, fmt.Sprintf("invoice_number:%s:%d", prefix, year))
+	if err != nil {
+		return fmt.Errorf("failed to generate invoice number: %w", err)
+	}
+	invoice.Number = fmt.Sprintf("%s-%d-%06d", prefix, year, seq)
+	invoice.StorageKey = fmt.Sprintf("invoices/%d/%s.pdf", year, invoice.Number)
+
+	pdf := renderInvoicePDF(invoice)
+	if err := i.store.Save(ctx, invoice.StorageKey, bytes.NewReader(pdf), "application/pdf"); err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+
+	if _, err := i.db.Database("go-ecomm").Collection("invoices").InsertOne(ctx, invoice); err != nil {
+		i.store.Delete(ctx, invoice.StorageKey)
+		return err
+	}
+	return nil
+}
+
+func (i *InvoiceServiceStruct) invoiceLines(ctx context.Context, products []model.ProductInfo) ([]model.InvoiceLine, error) {
+	lines := make([]model.InvoiceLine, 0, len(products))
+	for _, p := range products {
+		description := p.ProductID.Hex()
+		var prod model.Product
+		err := i.db.Database("go-ecomm").Collection("products").FindOne(ctx, bson.M{"_id": p.ProductID}).Decode(&prod)
+		if err == nil {
+			description = prod.Title
+			if p.Price.IsZero() {
+				p.Price = prod.Price
+			}
+		} else if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+
+		total, err := p.Price.Mul(int64(p.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, model.InvoiceLine{
+			ProductID:   p.ProductID,
+			Description: description,
+			Quantity:    p.Quantity,
+			UnitPrice:   p.Price,
+			Total:       total,
+		})
+	}
+	return lines, nil
+}
+
+func renderInvoicePDF(invoice *model.Invoice) []byte {
+	doc := utils.NewPDFDocument()
+	const left, right = 50.0, 545.0
+
+	title := "INVOICE"
+	if invoice.Type == model.InvoiceTypeCreditNote {
+		title = "CREDIT NOTE"
+	}
+	doc.Text(left, 60, 20, true, title)
+	doc.Text(left, 85, 10, false, "Number: "+invoice.Number)
+	doc.Text(left, 100, 10, false, "Date: "+invoice.CreatedAt.Format("2006-01-02"))
+	doc.Text(left, 115, 10, false, "Order: "+orderReference(invoice))
+
+	y := 150.0
+	writeAddress := func(x float64, label string, address *model.Address) {
+		doc.Text(x, y, 10, true, label)
+		if address == nil {
+			return
+		}
+		row := y + 14
+		for _, part := range []string{address.Name, address.Line1, address.Line2,
+			address.City + " " + address.Region + " " + address.PostalCode, address.Country} {
+			if part == "" {
+				continue
+			}
+			doc.Text(x, row, 9, false, part)
+			row += 12
+		}
+	}
+	writeAddress(left, "Bill to", invoice.BillingAddress)
+	writeAddress(300, "Ship to", invoice.ShippingAddress)
+
+	y = 250
+	header := func() {
+		doc.Text(left, y, 10, true, "Description")
+		doc.Text(350, y, 10, true, "Qty")
+		doc.Text(400, y, 10, true, "Unit price")
+		doc.Text(480, y, 10, true, "Total")
+		doc.Line(left, y+5, right, y+5)
+		y += 20
+	}
+	header()
+
+	for _, line := range invoice.Lines {
+		if y > 760 {
+			doc.AddPage()
+			y = 60
+			header()
+		}
+		description := line.Description
+		if len(description) > 55 {
+			description = description[:52] + "..."
+		}
+		doc.Text(left, y, 9, false, description)
+		doc.Text(350, y, 9, false, fmt.Sprint(line.Quantity))
+		doc.Text(400, y, 9, false, line.UnitPrice.Format())
+		doc.Text(480, y, 9, false, line.Total.Format())
+		y += 15
+	}
+
+	if y > 740 {
+		doc.AddPage()
+		y = 60
+	}
+	doc.Line(left, y, right, y)
+	y += 18
+	doc.Text(400, y, 10, false, "Subtotal")
+	doc.Text(480, y, 10, false, invoice.Subtotal.Format())
+	y += 15
+	if !invoice.Discount.IsZero() {
+		doc.Text(400, y, 10, false, "Discount")
+		doc.Text(480, y, 10, false, "-"+invoice.Discount.Format())
+		y += 15
+	}
+	if !invoice.Shipping.IsZero() {
+		doc.Text(400, y, 10, false, "Shipping")
+		doc.Text(480, y, 10, false, invoice.Shipping.Format())
+		y += 15
+	}
+	taxLabel := "Tax"
+	if invoice.TaxInclusive {
+		taxLabel = "Tax (included)"
+	}
+	doc.Text(400, y, 10, false, taxLabel)
+	doc.Text(480, y, 10, false, invoice.Tax.Format())
+	y += 15
+	doc.Text(400, y, 10, true, "Total")
+	doc.Text(480, y, 10, true, invoice.Total.Format())
+
+	return doc.Bytes()
+}
+
+func orderReference(invoice *model.Invoice) string {
+	if invoice.OrderNumber != "" {
+		return invoice.OrderNumber
+	}
+	return invoice.OrderID.Hex()
+}
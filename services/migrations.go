@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateMoneyFields rewrites amounts stored as bare numbers into
+// {amount, currency} documents. Bare numbers are read as whole units of
+// model.DefaultCurrency, see model.Money. Running it twice is harmless.
+func MigrateMoneyFields(db *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	database := db.Database("go-ecomm")
+	number := bson.M{"$type": "number"}
+
+	products, err := migrateCollection(ctx, database.Collection("products"),
+		bson.M{"price": number},
+		func(cur *mongo.Cursor) (bson.M, error) {
+			var p model.Product
+			err := cur.Decode(&p)
+			return bson.M{"price": p.Price}, err
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("products: %w", err)
+	}
+
+	orders, err := migrateCollection(ctx, database.Collection("orders"),
+		bson.M{"$or": bson.A{
+			bson.M{"amount": number},
+			bson.M{"refundedamount": number},
+			bson.M{"products.price": number},
+			bson.M{"refunds.amount": number},
+		}},
+		func(cur *mongo.Cursor) (bson.M, error) {
+			var o model.Order
+			err := cur.Decode(&o)
+			return bson.M{
+				"amount":         o.Amount,
+				"refundedamount": o.RefundedAmount,
+				"products":       o.Products,
+				"refunds":        o.Refunds,
+			}, err
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("orders: %w", err)
+	}
+
+	returns, err := migrateCollection(ctx, database.Collection("returns"),
+		bson.M{"refundamount": number},
+		func(cur *mongo.Cursor) (bson.M, error) {
+			var r model.ReturnRequest
+			err := cur.Decode(&r)
+			return bson.M{"refundamount": r.RefundAmount}, err
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("returns: %w", err)
+	}
+
+	invoices, err := migrateCollection(ctx, database.Collection("invoices"),
+		bson.M{"$or": bson.A{
+			bson.M{"total": number},
+			bson.M{"lines.total": number},
+		}},
+		func(cur *mongo.Cursor) (bson.M, error) {
+			var i model.Invoice
+			err := cur.Decode(&i)
+			return bson.M{
+				"lines":    i.Lines,
+				"subtotal": i.Subtotal,
+				"tax":      i.Tax,
+				"total":    i.Total,
+			}, err
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("invoices: %w", err)
+	}
+
+	fmt.Printf("money migration done: %d products, %d orders, %d returns, %d invoices\n", products, orders, returns, invoices)
+	return nil
+}
+
+func migrateCollection(ctx context.Context, collection *mongo.Collection, filter bson.M, convert func(*mongo.Cursor) (bson.M, error)) (int, error) {
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	migrated := 0
+	for cur.Next(ctx) {
+		set, err := convert(cur)
+		if err != nil {
+			return migrated, err
+		}
+		id := cur.Current.Lookup("_id")
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cur.Err()
+}
+
+// MigrateCartTimestamps backfills the activity times of carts created before
+// they were tracked from the creation time in their id, so abandoned cart
+// reminders can pick them up. Running it twice is harmless.
+func MigrateCartTimestamps(db *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	result, err := db.Database("go-ecomm").Collection("carts").UpdateMany(ctx,
+		bson.M{"updatedat": bson.M{"$exists": false}},
+		bson.A{bson.M{"$set": bson.M{
+			"createdat":     bson.M{"$toDate": "$_id"},
+			"updatedat":     bson.M{"$toDate": "$_id"},
+			"reminderssent": 0,
+		}}},
+	)
+	if err != nil {
+		return fmt.Errorf("carts: %w", err)
+	}
+
+	fmt.Printf("cart timestamp migration done: %d carts\n", result.ModifiedCount)
+	return nil
+}
+
+// MigrateReviewStatus publishes the reviews written before they were
+// moderated, they are already counted in the product ratings. Running it
+// twice is harmless.
+func MigrateReviewStatus(db *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	result, err := db.Database("go-ecomm").Collection("reviews").UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"status":      model.ReviewApproved,
+			"holdreasons": []string{},
+			"reportcount": 0,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("reviews: %w", err)
+	}
+
+	fmt.Printf("review status migration done: %d reviews\n", result.ModifiedCount)
+	return nil
+}
+
+// MigrateProductCategories creates a root category for every category name
+// products use that isn't in the tree yet, and rewrites the names to their
+// slugs, in coupons and promotions too. Misspelt names end up as categories
+// of their own, to be merged with a delete and reassign. Running it twice is
+// harmless.
+func MigrateProductCategories(db *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	database := db.Database("go-ecomm")
+	products := database.Collection("products")
+
+	names, err := products.Distinct(ctx, "categories", bson.M{})
+	if err != nil {
+		return fmt.Errorf("products: %w", err)
+	}
+	created, renamed := 0, int64(0)
+	for _, value := range names {
+		name, ok := value.(string)
+		if !ok {
+			continue
+		}
+		slug := model.Slugify(name)
+		if slug == "" {
+			continue
+		}
+		res, err := database.Collection("categories").UpdateOne(ctx,
+			bson.M{"slug": slug},
+			bson.M{"$setOnInsert": bson.M{
+				"_id":         primitive.NewObjectID(),
+				"name":        strings.TrimSpace(name),
+				"description": "",
+				"parentid":    nil,
+				"path":        []primitive.ObjectID{},
+				"position":    0,
+				"createdat":   time.Now(),
+				"updatedat":   time.Now(),
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return fmt.Errorf("categories: %w", err)
+		}
+		if res.UpsertedCount > 0 {
+			created++
+		}
+		if slug == name {
+			continue
+		}
+		res, err = products.UpdateMany(ctx,
+			bson.M{"categories": name},
+			bson.M{"$set": bson.M{"categories.$[c]": slug}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"c": name}}}),
+		)
+		if err != nil {
+			return fmt.Errorf("products: %w", err)
+		}
+		renamed += res.ModifiedCount
+	}
+
+	// coupons and promotions scoped to categories have to match the slugs
+	scoped := bson.M{"categories.0": bson.M{"$exists": true}}
+	slugs := func(cur *mongo.Cursor) (bson.M, error) {
+		var doc struct{ Categories []string }
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		categories := []string{}
+		for _, name := range doc.Categories {
+			if slug := model.Slugify(name); slug != "" && !contains(categories, slug) {
+				categories = append(categories, slug)
+			}
+		}
+		return bson.M{"categories": categories}, nil
+	}
+	coupons, err := migrateCollection(ctx, database.Collection("coupons"), scoped, slugs)
+	if err != nil {
+		return fmt.Errorf("coupons: %w", err)
+	}
+	promotions, err := migrateCollection(ctx, database.Collection("promotions"), scoped, slugs)
+	if err != nil {
+		return fmt.Errorf("promotions: %w", err)
+	}
+
+	fmt.Printf("category migration done: %d categories created, %d products, %d coupons, %d promotions updated\n",
+		created, renamed, coupons, promotions)
+	return nil
+}
+
+// MigrateOrderNumbers numbers the orders placed before order numbers existed,
+// in the order they were placed, from the sequence of the year they were
+// placed in. Their returns and shipments get the number too. Running it twice
+// is harmless.
+func MigrateOrderNumbers(db *mongo.Client, prefix string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	database := db.Database("go-ecomm")
+	orders := database.Collection("orders")
+	prefix = OrderNumberPrefix(prefix)
+
+	cur, err := orders.Find(ctx,
+		bson.M{"ordernumber": bson.M{"$in": bson.A{nil, ""}}},
+		options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}).
+			SetProjection(bson.M{"_id": 1, "createdat": 1}),
+	)
+	if err != nil {
+		return fmt.Errorf("orders: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	numbered := 0
+	for cur.Next(ctx) {
+		var order struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			CreatedAt time.Time          `bson:"createdat"`
+		}
+		if err := cur.Decode(&order); err != nil {
+			return fmt.Errorf("orders: %w", err)
+		}
+		if order.CreatedAt.IsZero() {
+			order.CreatedAt = order.ID.Timestamp()
+		}
+		number, err := newOrderNumber(ctx, db, prefix, order.CreatedAt.Year())
+		if err != nil {
+			return fmt.Errorf("orders: %w", err)
+		}
+		_, err = orders.UpdateOne(ctx,
+			bson.M{"_id": order.ID, "ordernumber": bson.M{"$in": bson.A{nil, ""}}},
+			bson.M{"$set": bson.M{"ordernumber": number}},
+		)
+		if err != nil {
+			return fmt.Errorf("orders: %w", err)
+		}
+		numbered++
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("orders: %w", err)
+	}
+
+	copied := map[string]int{}
+	for _, name := range []string{"returns", "shipments"} {
+		collection := database.Collection(name)
+		missing := bson.M{"ordernumber": bson.M{"$in": bson.A{nil, ""}}}
+		orderIds, err := collection.Distinct(ctx, "orderid", missing)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, id := range orderIds {
+			var order model.Order
+			err := orders.FindOne(ctx, bson.M{"_id": id},
+				options.FindOne().SetProjection(bson.M{"ordernumber": 1})).Decode(&order)
+			if err == mongo.ErrNoDocuments {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			filter := bson.M{"orderid": id}
+			for k, v := range missing {
+				filter[k] = v
+			}
+			res, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"ordernumber": order.OrderNumber}})
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			copied[name] += int(res.ModifiedCount)
+		}
+	}
+
+	fmt.Printf("order number migration done: %d orders, %d returns, %d shipments\n", numbered, copied["returns"], copied["shipments"])
+	return nil
+}
+
+// MigrateCouponUsage counts the coupon redemptions of every customer into the
+// counters the per customer limits are checked against. Running it twice is
+// harmless.
+func MigrateCouponUsage(db *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	database := db.Database("go-ecomm")
+	cur, err := database.Collection("coupon_redemptions").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"couponid": "$couponid", "userid": "$userid"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("coupon redemptions: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	counted := 0
+	for cur.Next(ctx) {
+		var group struct {
+			ID struct {
+				CouponID primitive.ObjectID `bson:"couponid"`
+				UserId   primitive.ObjectID `bson:"userid"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cur.Decode(&group); err != nil {
+			return fmt.Errorf("coupon redemptions: %w", err)
+		}
+		// orders placed since the counters exist are already counted
+		_, err := database.Collection("coupon_usage").UpdateOne(ctx,
+			bson.M{"couponid": group.ID.CouponID, "userid": group.ID.UserId},
+			bson.M{"$max": bson.M{"count": group.Count}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return fmt.Errorf("coupon usage: %w", err)
+		}
+		counted++
+	}
+	if err := cur.Err(); err != nil {
+		return fmt.Errorf("coupon redemptions: %w", err)
+	}
+
+	fmt.Printf("coupon usage migration done: %d customers and coupons\n", counted)
+	return nil
+}
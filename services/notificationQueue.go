@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// a notification is given up after this many failed sends
+	maxNotificationAttempts = 5
+	// how long a worker may take to send one before another picks it up
+	notificationLease = 5 * time.Minute
+)
+
+// NotificationQueue sends the queued notifications, retrying failures with a
+// growing delay. Several instances can run side by side.
+type NotificationQueue struct {
+	db       *mongo.Client
+	notifier notify.Notifier
+	// unsubscribe links point here, e.g. https://shop.example.com
+	baseURL string
+}
+
+func NewNotificationQueue(db *mongo.Client, notifier notify.Notifier, baseURL string) *NotificationQueue {
+	return &NotificationQueue{
+		db:       db,
+		notifier: notifier,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Start works through the queue every interval until the context is cancelled
+func (q *NotificationQueue) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(ctx, notificationLease)
+				if _, err := q.Process(runCtx); err != nil {
+					fmt.Println("failed to process notification queue:", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Process sends the notifications that are due and returns how many went out
+func (q *NotificationQueue) Process(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		notification, err := q.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			return sent, nil
+		} else if err != nil {
+			return sent, err
+		}
+		if err := q.send(ctx, notification); err != nil {
+			fmt.Println("failed to send notification", notification.ID.Hex()+":", err)
+			continue
+		}
+		sent++
+	}
+}
+
+// takes the next due notification, or one whose sender gave up on its lease
+func (q *NotificationQueue) claim(ctx context.Context) (*model.Notification, error) {
+	now := time.Now()
+	var notification model.Notification
+	err := q.db.Database("go-ecomm").Collection("notifications").FindOneAndUpdate(ctx,
+		bson.M{
+			"status":        bson.M{"$in": bson.A{model.NotificationPending, model.NotificationSending}},
+			"nextattemptat": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{
+			"status":        model.NotificationSending,
+			"nextattemptat": now.Add(notificationLease),
+		}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextattemptat", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&notification)
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (q *NotificationQueue) send(ctx context.Context, notification *model.Notification) error {
+	db := q.db.Database("go-ecomm")
+	collection := db.Collection("notifications")
+
+	msg := &notify.Message{
+		Kind:    notification.Kind,
+		UserID:  notification.UserId.Hex(),
+		To:      notification.To,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+		Data:    map[string]string{},
+	}
+	for k, v := range notification.Data {
+		msg.Data[k] = v
+	}
+
+	if notification.AlertID != nil {
+		var alert model.ProductAlert
+		err := db.Collection("product_alerts").FindOne(ctx, bson.M{"_id": notification.AlertID}).Decode(&alert)
+		if err == mongo.ErrNoDocuments {
+			_, err := collection.UpdateOne(ctx, bson.M{"_id": notification.ID},
+				bson.M{"$set": bson.M{"status": model.NotificationCancelled}})
+			return err
+		} else if err != nil {
+			return q.failed(ctx, notification, err)
+		}
+		link := q.baseURL + "/api/v1/alerts/unsubscribe/" + alert.UnsubscribeToken
+		msg.Data["unsubscribeUrl"] = link
+		msg.Body += "\n\nTo stop these alerts: " + link
+	}
+
+	if err := q.notifier.Send(ctx, msg); err != nil {
+		return q.failed(ctx, notification, err)
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": notification.ID}, bson.M{"$set": bson.M{
+		"status":   model.NotificationSent,
+		"attempts": notification.Attempts + 1,
+		"sentat":   time.Now(),
+	}})
+	return err
+}
+
+// puts a notification back in the queue, or gives up on it, and returns the
+// error it failed with
+func (q *NotificationQueue) failed(ctx context.Context, notification *model.Notification, sendErr error) error {
+	attempts := notification.Attempts + 1
+	set := bson.M{
+		"status":        model.NotificationPending,
+		"attempts":      attempts,
+		"lasterror":     sendErr.Error(),
+		"nextattemptat": time.Now().Add(time.Duration(attempts*attempts) * time.Minute),
+	}
+	if attempts >= maxNotificationAttempts {
+		set["status"] = model.NotificationFailed
+	}
+	_, err := q.db.Database("go-ecomm").Collection("notifications").UpdateOne(ctx,
+		bson.M{"_id": notification.ID}, bson.M{"$set": set})
+	if err != nil {
+		fmt.Println("failed to reschedule notification:", err)
+	}
+	return sendErr
+}
+
+// Queues a notification for the worker. A notification with the same dedup
+// key that is already queued or sent wins, reporting false.
+func enqueueNotification(ctx context.Context, db *mongo.Client, notification *model.Notification) (bool, error) {
+	notification.ID = primitive.NewObjectID()
+	notification.Status = model.NotificationPending
+	notification.CreatedAt = time.Now()
+	notification.NextAttemptAt = notification.CreatedAt
+	if notification.Data == nil {
+		notification.Data = map[string]string{}
+	}
+	_, err := db.Database("go-ecomm").Collection("notifications").InsertOne(ctx, notification)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Lets the customer of an order know about a change to it, quoting the order
+// number they give support. Failures are only logged.
+func notifyOrder(db *mongo.Client, order *model.Order, kind, key, subject, body string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var user model.User
+	err := db.Database("go-ecomm").Collection("users").FindOne(ctx, bson.M{"_id": order.UserId},
+		options.FindOne().SetProjection(bson.M{"email": 1, "firstname": 1})).Decode(&user)
+	if err != nil {
+		fmt.Println("failed to notify about order:", order.ID.Hex(), err)
+		return
+	}
+
+	_, err = enqueueNotification(ctx, db, &model.Notification{
+		DedupKey: kind + ":" + key,
+		Kind:     kind,
+		UserId:   order.UserId,
+		To:       user.Email,
+		Subject:  subject,
+		Body:     fmt.Sprintf("Hi %s, %s", user.FirstName, body),
+		Data: map[string]string{
+			"orderId":     order.ID.Hex(),
+			"orderNumber": order.OrderNumber,
+		},
+	})
+	if err != nil {
+		fmt.Println("failed to notify about order:", order.ID.Hex(), err)
+	}
+}
@@ -3,33 +3,55 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/souvikjs01/go-ecommerce/model"
 	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/tax"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type OrderService interface {
 	CreateOrder(order *request.CreateOrderPayload, userId string) (*model.Order, error)
 	GetUserOrders(userID string) (*[]model.Order, error)
-	// GetAllOrders() (*[]model.Order, error)
+	GetOrderByNumber(orderNumber, userId string, isAdmin bool) (*model.Order, error)
+	MarkOrderPaid(orderId string) (*model.Order, *model.Invoice, error)
+	GetAllOrders(filter *request.OrderFilterQuery) (*model.OrderPage, error)
+	ExportOrders(filter *request.OrderFilterQuery, emit func(*model.Order) error) error
 	// DeleteUserOrder(userId, orderId string) (*model.Order, error)
 	// UpdateOrderDetails(order *model.Order, userid, orderid string) (*model.Order, error)
 }
 
 type OrderServiceStruct struct {
-	db *mongo.Client
+	db       *mongo.Client
+	invoices InvoiceService
+	taxes    tax.Calculator
+	// first part of the order numbers, e.g. ORD
+	numberPrefix string
 }
 
-func NewOrderService(db *mongo.Client) *OrderServiceStruct {
+func NewOrderService(db *mongo.Client, invoices InvoiceService, taxes tax.Calculator, numberPrefix string) *OrderServiceStruct {
 	return &OrderServiceStruct{
-		db: db,
+		db:           db,
+		invoices:     invoices,
+		taxes:        taxes,
+		numberPrefix: OrderNumberPrefix(numberPrefix),
 	}
 }
 
+// OrderNumberPrefix is the configured prefix as it appears in order numbers
+func OrderNumberPrefix(prefix string) string {
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return "ORD"
+	}
+	return prefix
+}
+
 func (o *OrderServiceStruct) CreateOrder(order *request.CreateOrderPayload, userId string) (*model.Order, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
@@ -47,8 +69,16 @@ func (o *OrderServiceStruct) CreateOrder(order *request.CreateOrderPayload, user
 		defer close(errChan)
 		defer close(orderChan)
 
-		totalAmount := 0
-		for _, product := range order.Products {
+		currency, err := resolveCurrency(ctx, o.db, order.Currency, userId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		var totalAmount model.Money
+		rates := []model.AppliedRate{}
+		lines := make([]pricedLine, 0, len(order.Products))
+		for i, product := range order.Products {
 			if product.ProductID.IsZero() {
 				errChan <- fmt.Errorf("invalid product ID: %v", product.ProductID)
 				return
@@ -64,29 +94,213 @@ func (o *OrderServiceStruct) CreateOrder(order *request.CreateOrderPayload, user
 				return
 			}
 
-			totalAmount += (prod.Price * product.Quantity)
+			if product.Quantity < 1 {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("quantity of product %s must be at least 1", product.ProductID.Hex()), Code: 400}
+				return
+			}
+
+			// without a preferred currency the order is charged in the first product's
+			if currency == "" {
+				currency = prod.Price.Currency
+			}
+			price, rate, err := priceProduct(ctx, o.db, &prod, currency)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if rate != nil && !hasRate(rates, rate) {
+				rates = append(rates, *rate)
+			}
+
+			lineTotal, err := price.Mul(int64(product.Quantity))
+			if err != nil {
+				errChan <- err
+				return
+			}
+			totalAmount, err = totalAmount.Add(lineTotal)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			order.Products[i].Price = price
+			lines = append(lines, pricedLine{
+				ProductID:   prod.ID,
+				Categories:  prod.Categories,
+				TaxCategory: prod.TaxCategory,
+				WeightGrams: prod.ShippingWeight(),
+				UnitPrice:   price,
+				Quantity:    product.Quantity,
+				Total:       lineTotal,
+			})
 			// Check stock
 			if !prod.InStock {
 				errChan <- fmt.Errorf("product %s is out of stock", prod.Title)
 				return
 			}
+			if prod.Stock != nil && product.Quantity > *prod.Stock {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("only %d of %s are left", *prod.Stock, prod.Title), Code: 409}
+				return
+			}
+		}
+
+		// promotions apply automatically, coupons come from the payload, or from the cart when none are given
+		codes, fromCart := order.CouponCodes, false
+		if codes == nil {
+			var cart model.Cart
+			err := o.db.Database("go-ecomm").Collection("carts").FindOne(ctx, bson.M{"userid": userObjID}).Decode(&cart)
+			if err != nil && err != mongo.ErrNoDocuments {
+				errChan <- err
+				return
+			}
+			codes, fromCart = cart.CouponCodes, len(cart.CouponCodes) > 0
+		}
+		pricing, lineDiscounts, err := applyDiscounts(ctx, o.db, userObjID, codes, lines, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		for i := range order.Products {
+			order.Products[i].Discount = lineDiscounts[i]
+		}
+		discounts := pricing.Coupons
+		goods, freeShipping, err := shippableValue(pricing)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		shippingAddress, err := resolveOrderAddress(ctx, o.db, userObjID, order.AddressID, order.ShippingAddress, "isdefaultshipping")
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if shippingAddress == nil && order.Address == "" {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a shipping address is required"), Code: 400}
+			return
+		}
+
+		billingAddress, err := resolveOrderAddress(ctx, o.db, userObjID, order.BillingAddressID, nil, "isdefaultbilling")
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if billingAddress == nil {
+			billingAddress = shippingAddress
+		}
+
+		// taxed where the order is shipped to
+		if err := applyTax(ctx, o.taxes, pricing, lines, lineDiscounts, shippingAddress); err != nil {
+			errChan <- err
+			return
+		}
+		for i := range order.Products {
+			order.Products[i].Tax = pricing.Lines[i].Tax
+		}
+
+		// delivery is priced on the goods after discounts, tax is not charged on it
+		var shippingMethod *model.ShippingOption
+		shippingCost := model.ZeroMoney(pricing.Currency)
+		if shippingAddress != nil {
+			available, err := shippingOptions(ctx, o.db, shippingAddress, lines, goods, freeShipping)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			for i := range available {
+				if available[i].MethodID.Hex() == order.ShippingMethodID {
+					shippingMethod = &available[i]
+				}
+			}
+			switch {
+			case order.ShippingMethodID == "" && len(available) > 0:
+				errChan <- model.ErrMsg{Err: fmt.Errorf("a shipping method is required"), Code: 400}
+				return
+			case order.ShippingMethodID != "" && shippingMethod == nil:
+				errChan <- model.ErrMsg{Err: fmt.Errorf("shipping method is not available for this address"), Code: 400}
+				return
+			}
+		} else if order.ShippingMethodID != "" {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a shipping method needs a structured shipping address"), Code: 400}
+			return
+		}
+		if shippingMethod != nil {
+			shippingCost = shippingMethod.Cost
+		}
+		amount, err := pricing.Total.Add(shippingCost)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		address := order.Address
+		if shippingAddress != nil {
+			address = shippingAddress.String()
+		}
+
+		orderNumber, err := o.nextOrderNumber(ctx)
+		if err != nil {
+			errChan <- fmt.Errorf("failed to generate order number: %w", err)
+			return
 		}
 
 		newOrderStruct := model.Order{
-			UserId:   userObjID,
-			Amount:   totalAmount,
-			Status:   order.Status,
-			Address:  order.Address,
-			Products: order.Products,
+			OrderNumber:     orderNumber,
+			UserId:          userObjID,
+			Amount:          amount,
+			Subtotal:        pricing.Subtotal,
+			DiscountAmount:  pricing.Discount,
+			Discounts:       discounts,
+			Promotions:      pricing.Applied,
+			Tax:             pricing.Tax,
+			TaxLines:        pricing.TaxLines,
+			TaxInclusive:    pricing.TaxInclusive,
+			FreeShipping:    freeShipping,
+			ShippingMethod:  shippingMethod,
+			ShippingCost:    shippingCost,
+			ExchangeRates:   rates,
+			Status:          order.Status,
+			Address:         address,
+			ShippingAddress: shippingAddress,
+			BillingAddress:  billingAddress,
+			Products:        order.Products,
 		}
 
 		createNewOrder := model.NewOrder(&newOrderStruct)
 
+		if err := reserveStock(ctx, o.db, createNewOrder.Products); err != nil {
+			errChan <- err
+			return
+		}
+		if err := redeemCoupons(ctx, o.db, userObjID, discounts); err != nil {
+			releaseStock(ctx, o.db, createNewOrder.Products)
+			errChan <- err
+			return
+		}
 		_, err = o.db.Database("go-ecomm").Collection("orders").InsertOne(ctx, createNewOrder)
 		if err != nil {
+			releaseCoupons(ctx, o.db, userObjID, discounts)
+			releaseStock(ctx, o.db, createNewOrder.Products)
 			errChan <- err
 			return
 		}
+
+		// the order is placed and the coupon uses are counted, a missing
+		// redemption only leaves a gap in the history
+		if err := recordRedemptions(ctx, o.db, createNewOrder); err != nil {
+			fmt.Println("failed to record coupon redemptions:", err)
+		}
+		if err := markCartsRecovered(ctx, o.db, userObjID, createNewOrder.ID); err != nil {
+			fmt.Println("failed to mark abandoned carts recovered:", err)
+		}
+		if fromCart {
+			_, err := o.db.Database("go-ecomm").Collection("carts").UpdateOne(ctx,
+				bson.M{"userid": userObjID},
+				bson.M{"$set": bson.M{"couponcodes": []string{}}},
+			)
+			if err != nil {
+				fmt.Println("failed to clear cart coupons:", err)
+			}
+		}
 		orderChan <- createNewOrder
 	}()
 
@@ -102,6 +316,15 @@ func (o *OrderServiceStruct) CreateOrder(order *request.CreateOrderPayload, user
 	}
 }
 
+func hasRate(rates []model.AppliedRate, rate *model.AppliedRate) bool {
+	for _, r := range rates {
+		if r.From == rate.From && r.To == rate.To {
+			return true
+		}
+	}
+	return false
+}
+
 func (o *OrderServiceStruct) GetUserOrders(userID string) (*[]model.Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
@@ -114,19 +337,40 @@ func (o *OrderServiceStruct) GetUserOrders(userID string) (*[]model.Order, error
 		return nil, fmt.Errorf("invalid userId")
 	}
 
+	// orders with their shipments, so every line shows its fulfilment
+	to_get_user_orders := bson.A{
+		bson.M{"$match": bson.M{"userid": userObjId}},
+		bson.M{"$sort": bson.M{"createdat": -1}},
+		bson.M{
+			"$lookup": bson.M{
+				"from":         "shipments",
+				"localField":   "_id",
+				"foreignField": "orderid",
+				"as":           "shipments",
+			},
+		},
+	}
+
 	go func() {
-		cur, err := o.db.Database("go-ecomm").Collection("orders").Find(ctx, bson.M{"userId": userObjId})
+		cur, err := o.db.Database("go-ecomm").Collection("orders").Aggregate(ctx, to_get_user_orders)
 		if err != nil {
 			errChan <- err
 			return
 		}
 		defer cur.Close(ctx)
 
-		var orders []model.Order
+		orders := []model.Order{}
 		for cur.Next(ctx) {
 			var order model.Order
 			if err := cur.Decode(&order); err != nil {
 				errChan <- err
+				return
+			}
+			// orders placed before fulfilment tracking have no line status yet
+			for i := range order.Products {
+				if order.Products[i].FulfilmentStatus == "" {
+					order.Products[i].UpdateFulfilment()
+				}
 			}
 			orders = append(orders, order)
 		}
@@ -144,55 +388,317 @@ func (o *OrderServiceStruct) GetUserOrders(userID string) (*[]model.Order, error
 
 }
 
-// func (o *OrderServiceStruct) GetAllOrders() (*[]model.Order, error) {
-// 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-// 	defer cancel()
+// Look up an order by its human readable number, customers only see their own
+func (o *OrderServiceStruct) GetOrderByNumber(orderNumber, userId string, isAdmin bool) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
-// 	ordersChan := make(chan *[]model.Order)
-// 	errChan := make(chan error, 32)
+	orderChan := make(chan *model.Order, 32)
+	errChan := make(chan error, 32)
 
-// 	var orders []model.Order
-// 	to_get_orders := bson.A{
-// 		bson.M{
-// 			"$sort": bson.M{
-// 				"createdAt": -1,
-// 			},
-// 		},
-// 		bson.M{
-// 			"$limit": 3,
-// 		},
-// 	}
-// 	// from MongoDB
-// 	go func() {
-// 		cur, err := o.db.Database("go-ecomm").Collection("orders").Aggregate(ctx, to_get_orders)
-// 		if err != nil {
-// 			errChan <- err
-// 			return
-// 		}
-// 		for cur.Next(ctx) {
-// 			var order model.Order
-// 			err := cur.Decode(&order)
-// 			if err != nil {
-// 				errChan <- err
-// 				return
-// 			}
-// 			orders = append(orders, order)
-// 		}
+	filter := bson.M{"ordernumber": strings.ToUpper(strings.TrimSpace(orderNumber))}
+	if !isAdmin {
+		userObjID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+		}
+		filter["userid"] = userObjID
+	}
 
-// 		ordersChan <- &orders
-// 	}()
+	go func() {
+		defer close(errChan)
+		defer close(orderChan)
 
-// 	for {
-// 		select {
-// 		case err := <-errChan:
-// 			return nil, err
-// 		case orders := <-ordersChan:
-// 			return orders, nil
-// 		case <-ctx.Done():
-// 			return nil, context.DeadlineExceeded
-// 		}
-// 	}
-// }
+		var order model.Order
+		err := o.db.Database("go-ecomm").Collection("orders").FindOne(ctx, filter).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		orderChan <- &order
+	}()
+
+	select {
+	case err := <-errChan:
+		return nil, err
+	case order := <-orderChan:
+		return order, nil
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Record the payment of an order and issue its invoice. For an order that is
+// already paid but whose invoice failed, only the invoice is issued.
+func (o *OrderServiceStruct) MarkOrderPaid(orderId string) (*model.Order, *model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	db := o.db.Database("go-ecomm")
+	now := time.Now()
+	var order model.Order
+	err = db.Collection("orders").FindOneAndUpdate(ctx,
+		bson.M{"_id": orderObjID, "paymentstatus": bson.M{"$ne": model.PaymentStatusPaid}},
+		bson.M{"$set": bson.M{
+			"paymentstatus": model.PaymentStatusPaid,
+			"paidat":        now,
+			"updatedat":     now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		err = db.Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+		} else if err != nil {
+			return nil, nil, err
+		}
+		invoices, err := db.Collection("invoices").CountDocuments(ctx,
+			bson.M{"orderid": orderObjID, "type": model.InvoiceTypeInvoice})
+		if err != nil {
+			return nil, nil, err
+		}
+		if invoices > 0 {
+			return nil, nil, model.ErrMsg{Err: fmt.Errorf("order already paid"), Code: 409}
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	invoice, err := o.invoices.IssueInvoice(orderId)
+	if err != nil {
+		return &order, nil, fmt.Errorf("order marked as paid but the invoice failed: %w", err)
+	}
+	return &order, invoice, nil
+}
+
+// order numbers restart every year: <prefix>-<year>-<sequence>
+func (o *OrderServiceStruct) nextOrderNumber(ctx context.Context) (string, error) {
+	return newOrderNumber(ctx, o.db, o.numberPrefix, time.Now().Year())
+}
+
+func newOrderNumber(ctx context.Context, db *mongo.Client, prefix string, year int) (string, error) {
+	seq, err := nextSequence(ctx, db, fmt.Sprintf("order_number:%d", year))
+	if err != nil {
+		return "", err
+	}
+	return model.FormatOrderNumber(prefix, year, seq), nil
+}
+
+// Get all orders for the admin, filtered, sorted and paginated
+func (o *OrderServiceStruct) GetAllOrders(filter *request.OrderFilterQuery) (*model.OrderPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.OrderPage, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		query, err := o.buildOrderFilter(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		page, limit := filter.Page, filter.Limit
+		if page == 0 {
+			page = 1
+		}
+		if limit == 0 {
+			limit = 20
+		}
+
+		collection := o.db.Database("go-ecomm").Collection("orders")
+		total, err := collection.CountDocuments(ctx, query)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		opts := options.Find().
+			SetSort(orderSort(filter)).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit))
+
+		cur, err := collection.Find(ctx, query, opts)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		orders := []model.Order{}
+		if err := cur.All(ctx, &orders); err != nil {
+			errChan <- err
+			return
+		}
+
+		pageChan <- &model.OrderPage{
+			Orders: orders,
+			Page:   page,
+			Limit:  limit,
+			Total:  total,
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		return nil, err
+	case page := <-pageChan:
+		return page, nil
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Streams every order matching the filter to emit, without pagination
+func (o *OrderServiceStruct) ExportOrders(filter *request.OrderFilterQuery, emit func(*model.Order) error) error {
+	// exports can be large, so they get a much longer deadline than regular requests
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+	defer cancel()
+
+	query, err := o.buildOrderFilter(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	cur, err := o.db.Database("go-ecomm").Collection("orders").Find(ctx, query,
+		options.Find().SetSort(orderSort(filter)).SetBatchSize(500),
+	)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var order model.Order
+		if err := cur.Decode(&order); err != nil {
+			return err
+		}
+		if err := emit(&order); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (o *OrderServiceStruct) buildOrderFilter(ctx context.Context, filter *request.OrderFilterQuery) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	if filter.OrderNumber != "" {
+		query["ordernumber"] = strings.ToUpper(strings.TrimSpace(filter.OrderNumber))
+	}
+
+	createdAt := bson.M{}
+	if filter.From != "" {
+		from, err := parseFilterTime(filter.From, false)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid from date: %w", err), Code: 400}
+		}
+		createdAt["$gte"] = from
+	}
+	if filter.To != "" {
+		to, err := parseFilterTime(filter.To, true)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid to date: %w", err), Code: 400}
+		}
+		createdAt["$lte"] = to
+	}
+	if len(createdAt) > 0 {
+		query["createdat"] = createdAt
+	}
+
+	amount := bson.M{}
+	if filter.MinAmount != nil {
+		amount["$gte"] = *filter.MinAmount
+	}
+	if filter.MaxAmount != nil {
+		amount["$lte"] = *filter.MaxAmount
+	}
+	if len(amount) > 0 {
+		query["amount.amount"] = amount
+	}
+
+	if filter.ProductID != "" {
+		productObjID, err := primitive.ObjectIDFromHex(filter.ProductID)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+		}
+		query["products.product_id"] = productObjID
+	}
+
+	// customer is either a user id, an email or a username
+	if filter.Customer != "" {
+		customerObjID, err := primitive.ObjectIDFromHex(filter.Customer)
+		if err != nil {
+			var user model.User
+			err = o.db.Database("go-ecomm").Collection("users").FindOne(ctx, bson.M{
+				"$or": bson.A{
+					bson.M{"email": filter.Customer},
+					bson.M{"username": filter.Customer},
+				},
+			}).Decode(&user)
+			if err == mongo.ErrNoDocuments {
+				return nil, model.ErrMsg{Err: fmt.Errorf("customer not found"), Code: 404}
+			} else if err != nil {
+				return nil, err
+			}
+			customerObjID = user.ID
+		}
+		query["userid"] = customerObjID
+	}
+
+	return query, nil
+}
+
+func orderSort(filter *request.OrderFilterQuery) bson.D {
+	fields := map[string]string{
+		"createdAt": "createdat",
+		"updatedAt": "updatedat",
+		"amount":    "amount.amount",
+		"status":    "status",
+	}
+	field, ok := fields[filter.SortBy]
+	if !ok {
+		field = "createdat"
+	}
+	dir := -1
+	if filter.SortDir == "asc" {
+		dir = 1
+	}
+	// _id keeps the order stable between pages
+	return bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}}
+}
+
+// accepts RFC 3339 timestamps or plain dates, a plain "to" date covers the whole day
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
 
 // func (o *OrderServiceStruct) DeleteUserOrder(userId, orderId string) (*model.Order, error) {
 // 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
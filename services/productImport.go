@@ -0,0 +1,903 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rows are written in batches of this size, progress is saved after each
+const importBatchSize = 500
+
+// ProductCSVHeader are the columns of the CSV export, the import takes the
+// same ones in any order. Lists are separated by ";", prices are written as
+// "17.99 EUR; 15.49 GBP". A blank stock is a product whose stock isn't
+// counted.
+var ProductCSVHeader = []string{"id", "sku", "title", "desc", "img", "categories", "size", "color", "price", "currency", "prices", "instock", "tax_category", "weight_grams", "length_mm", "width_mm", "height_mm", "stock"}
+
+// columns a CSV import can't do without
+var requiredProductColumns = []string{"title", "desc", "price", "currency"}
+
+// the columns a key of a JSON Lines row stands for, keys are matched without
+// regard to case like encoding/json does
+var jsonProductColumns = map[string][]string{
+	"_id":         {"id"},
+	"sku":         {"sku"},
+	"title":       {"title"},
+	"desc":        {"desc"},
+	"img":         {"img"},
+	"categories":  {"categories"},
+	"size":        {"size"},
+	"color":       {"color"},
+	"price":       {"price", "currency"},
+	"prices":      {"prices"},
+	"instock":     {"instock"},
+	"taxcategory": {"tax_category"},
+	"weightgrams": {"weight_grams"},
+	"dimensions":  {"length_mm", "width_mm", "height_mm"},
+	"stock":       {"stock"},
+}
+
+type ProductImportService interface {
+	StartProductImport(userId, filename, format string, dryRun bool, data []byte) (*model.ProductImport, error)
+	GetProductImport(importId string) (*model.ProductImport, error)
+	GetProductImports(query *request.ProductImportsQuery) (*model.ProductImportPage, error)
+	FailInterruptedImports() (int64, error)
+}
+
+type ProductImportServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewProductImportService(db *mongo.Client) *ProductImportServiceStruct {
+	return &ProductImportServiceStruct{
+		db: db,
+	}
+}
+
+// a row of an import file with what is wrong with it
+type importRow struct {
+	line int
+	row  request.ProductImportRow
+	// the columns the file gives the row, named as in ProductCSVHeader. An
+	// existing product only has these changed.
+	columns map[string]bool
+	errs    []model.ImportRowError
+}
+
+func (r *importRow) fail(field, format string, args ...any) {
+	r.errs = append(r.errs, model.ImportRowError{
+		Row:   r.line,
+		ID:    r.row.ID,
+		SKU:   r.row.SKU,
+		Field: field,
+		Error: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *importRow) failed(field string) bool {
+	for _, err := range r.errs {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Read an import file and start importing it in the background. Problems
+// with the file as a whole are reported right away, problems with single
+// rows end up on the import.
+func (p *ProductImportServiceStruct) StartProductImport(userId, filename, format string, dryRun bool, data []byte) (*model.ProductImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	importChan := make(chan *model.ProductImport, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	if format == "" {
+		switch strings.ToLower(path.Ext(filename)) {
+		case ".csv":
+			format = "csv"
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		default:
+			return nil, model.ErrMsg{Err: fmt.Errorf("can't tell the format of %q, pass format csv or jsonl", filename), Code: 400}
+		}
+	}
+
+	// spreadsheet programs like to start files with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var rows []importRow
+	if format == "csv" {
+		rows, err = parseProductCSV(data)
+	} else {
+		rows, err = parseProductJSONL(data)
+	}
+	if err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+	if len(rows) == 0 {
+		return nil, model.ErrMsg{Err: fmt.Errorf("the file has no products"), Code: 400}
+	}
+
+	job := &model.ProductImport{
+		ID:        primitive.NewObjectID(),
+		UserId:    userObjID,
+		Filename:  filename,
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    model.ImportRunning,
+		Total:     len(rows),
+		Errors:    []model.ImportRowError{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(importChan)
+
+		_, err := p.db.Database("go-ecomm").Collection("product_imports").InsertOne(ctx, job)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		// the job keeps changing its copy while it runs
+		started := *job
+		started.Errors = []model.ImportRowError{}
+		go p.run(job, rows)
+		importChan <- &started
+	}()
+
+	select {
+	case job := <-importChan:
+		return job, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The progress and outcome of an import
+func (p *ProductImportServiceStruct) GetProductImport(importId string) (*model.ProductImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	importChan := make(chan *model.ProductImport, 32)
+	errChan := make(chan error, 32)
+
+	importObjID, err := primitive.ObjectIDFromHex(importId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid importId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(importChan)
+
+		var job model.ProductImport
+		err := p.db.Database("go-ecomm").Collection("product_imports").FindOne(ctx, bson.M{"_id": importObjID}).Decode(&job)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("import not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		importChan <- &job
+	}()
+
+	select {
+	case job := <-importChan:
+		return job, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Recent imports first, without their row errors
+func (p *ProductImportServiceStruct) GetProductImports(query *request.ProductImportsQuery) (*model.ProductImportPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.ProductImportPage, 32)
+	errChan := make(chan error, 32)
+
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		collection := p.db.Database("go-ecomm").Collection("product_imports")
+		total, err := collection.CountDocuments(ctx, bson.M{})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, bson.M{}, options.Find().
+			SetSort(bson.D{{Key: "createdat", Value: -1}}).
+			SetProjection(bson.M{"errors": 0}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		imports := []model.ProductImport{}
+		if err := cur.All(ctx, &imports); err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- &model.ProductImportPage{
+			Imports: imports,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		}
+	}()
+
+	select {
+	case page := <-pageChan:
+		return page, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// run imports the rows batch by batch and saves the progress after each
+func (p *ProductImportServiceStruct) run(job *model.ProductImport, rows []importRow) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	found, err := p.db.Database("go-ecomm").Collection("categories").Distinct(ctx, "slug", bson.M{})
+	cancel()
+	if err != nil {
+		p.finish(job, err)
+		return
+	}
+	known := map[string]bool{}
+	for _, slug := range found {
+		if s, ok := slug.(string); ok {
+			known[s] = true
+		}
+	}
+
+	seenIDs, seenSKUs := map[string]int{}, map[string]int{}
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := min(start+importBatchSize, len(rows))
+		if err := p.importBatch(job, rows[start:end], known, seenIDs, seenSKUs); err != nil {
+			p.finish(job, err)
+			return
+		}
+		if end < len(rows) {
+			p.saveProgress(job)
+		}
+	}
+	p.finish(job, nil)
+}
+
+// a product to create (before is nil) or update
+type plannedWrite struct {
+	row    *importRow
+	before *model.Product
+	after  model.Product
+}
+
+func (p *ProductImportServiceStruct) importBatch(job *model.ProductImport, batch []importRow, known map[string]bool, seenIDs, seenSKUs map[string]int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	collection := p.db.Database("go-ecomm").Collection("products")
+
+	valid := []*importRow{}
+	ids, skus := []primitive.ObjectID{}, []string{}
+	for n := range batch {
+		r := &batch[n]
+		// a row that couldn't be read at all has nothing to check
+		if !r.failed("") {
+			validateImportRow(r, known)
+		}
+		claimImportKeys(r, seenIDs, seenSKUs)
+		if len(r.errs) > 0 {
+			continue
+		}
+		valid = append(valid, r)
+		if r.row.ID != "" {
+			id, _ := primitive.ObjectIDFromHex(r.row.ID)
+			ids = append(ids, id)
+		}
+		if r.row.SKU != "" {
+			skus = append(skus, r.row.SKU)
+		}
+	}
+
+	// the products the rows refer to, by id and by sku
+	byID, bySKU := map[primitive.ObjectID]*model.Product{}, map[string]*model.Product{}
+	if len(ids) > 0 || len(skus) > 0 {
+		cur, err := collection.Find(ctx, bson.M{"$or": bson.A{
+			bson.M{"_id": bson.M{"$in": ids}},
+			bson.M{"sku": bson.M{"$in": skus}},
+		}})
+		if err != nil {
+			return err
+		}
+		existing := []model.Product{}
+		if err := cur.All(ctx, &existing); err != nil {
+			return err
+		}
+		for n := range existing {
+			byID[existing[n].ID] = &existing[n]
+			if existing[n].SKU != "" {
+				bySKU[existing[n].SKU] = &existing[n]
+			}
+		}
+	}
+
+	planned := []plannedWrite{}
+	writes := []mongo.WriteModel{}
+	for _, r := range valid {
+		var target *model.Product
+		if r.row.ID != "" {
+			id, _ := primitive.ObjectIDFromHex(r.row.ID)
+			if target = byID[id]; target == nil {
+				r.fail("id", "no product has this id")
+				continue
+			}
+		} else if r.row.SKU != "" {
+			target = bySKU[r.row.SKU]
+		}
+		if owner := bySKU[r.row.SKU]; r.row.SKU != "" && owner != nil && owner.ID != target.ID {
+			r.fail("sku", "the sku belongs to product %s", owner.ID.Hex())
+			continue
+		}
+
+		if target == nil {
+			product := model.Product{
+				ID:     primitive.NewObjectID(),
+				UserID: job.UserId,
+			}
+			applyImportRow(&product, &r.row.ProductPayload, nil)
+			planned = append(planned, plannedWrite{row: r, after: product})
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(product))
+			continue
+		}
+
+		product := *target
+		applyImportRow(&product, &r.row.ProductPayload, r.columns)
+		// a new base price can clash with the prices the row leaves alone
+		if err := validatePrices(product.Price, product.Prices); err != nil {
+			r.fail("prices", "%s", err.Error())
+			continue
+		}
+		set := importUpdate(&product, r.columns)
+		planned = append(planned, plannedWrite{row: r, before: target, after: product})
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": product.ID}).
+			SetUpdate(bson.M{"$set": set}))
+	}
+
+	if !job.DryRun && len(writes) > 0 {
+		_, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+			for _, writeErr := range bulkErr.WriteErrors {
+				r := planned[writeErr.Index].row
+				if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+					r.fail("sku", "the sku belongs to another product")
+				} else {
+					r.fail("", "%s", writeErr.Message)
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, write := range planned {
+		if len(write.row.errs) > 0 {
+			continue
+		}
+		if write.before == nil {
+			job.Created++
+			continue
+		}
+		job.Updated++
+		if !job.DryRun {
+			queueProductAlerts(p.db, write.before, &write.after)
+		}
+	}
+	for _, r := range batch {
+		if len(r.errs) == 0 {
+			continue
+		}
+		job.Failed++
+		for _, err := range r.errs {
+			if len(job.Errors) < model.MaxImportErrors {
+				job.Errors = append(job.Errors, err)
+			}
+		}
+	}
+	job.Processed += len(batch)
+	return nil
+}
+
+func (p *ProductImportServiceStruct) saveProgress(job *model.ProductImport) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	job.UpdatedAt = time.Now()
+	_, err := p.db.Database("go-ecomm").Collection("product_imports").UpdateOne(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{"$set": bson.M{
+			"processed": job.Processed,
+			"created":   job.Created,
+			"updated":   job.Updated,
+			"failed":    job.Failed,
+			"errors":    job.Errors,
+			"updatedat": job.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		fmt.Println("failed to save product import progress:", err)
+	}
+}
+
+// An import runs in the process that started it, so the ones still running
+// when the server starts were cut short by a restart. They are marked failed,
+// the rows written before stay written.
+func (p *ProductImportServiceStruct) FailInterruptedImports() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	now := time.Now()
+	res, err := p.db.Database("go-ecomm").Collection("product_imports").UpdateMany(ctx,
+		bson.M{"status": model.ImportRunning},
+		bson.M{"$set": bson.M{
+			"status":     model.ImportFailed,
+			"error":      "interrupted by a server restart, import the file again",
+			"updatedat":  now,
+			"finishedat": now,
+		}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// finish records the outcome, err is what stopped the import early
+func (p *ProductImportServiceStruct) finish(job *model.ProductImport, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	now := time.Now()
+	job.Status = model.ImportCompleted
+	if err != nil {
+		fmt.Println("product import failed:", job.ID.Hex(), err)
+		job.Status = model.ImportFailed
+		job.Error = err.Error()
+	}
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	_, err = p.db.Database("go-ecomm").Collection("product_imports").ReplaceOne(ctx, bson.M{"_id": job.ID}, job)
+	if err != nil {
+		fmt.Println("failed to save product import:", job.ID.Hex(), err)
+	}
+}
+
+// validateImportRow checks a row like CreateProduct checks a product, and
+// normalises it on the way. Fields are named after the CSV columns.
+func validateImportRow(r *importRow, known map[string]bool) {
+	row := &r.row
+	row.ID = strings.TrimSpace(row.ID)
+	if row.ID != "" {
+		if _, err := primitive.ObjectIDFromHex(row.ID); err != nil {
+			r.fail("id", "invalid id")
+		}
+	}
+	sku, err := validateSKU(row.SKU)
+	if err != nil {
+		r.fail("sku", "%s", err.Error())
+	}
+	row.SKU = sku
+
+	row.Title, row.Desc = strings.TrimSpace(row.Title), strings.TrimSpace(row.Desc)
+	if row.Title == "" {
+		r.fail("title", "title is required")
+	}
+	if row.Desc == "" {
+		r.fail("desc", "desc is required")
+	}
+
+	if !r.failed("price") && !r.failed("currency") {
+		if row.Price.Currency == "" {
+			r.fail("price", "price is required")
+		} else if err := validatePrice(row.Price); err != nil {
+			r.fail("price", "%s", err.Error())
+		} else if err := validatePrices(row.Price, row.Prices); err != nil && !r.failed("prices") {
+			r.fail("prices", "%s", err.Error())
+		}
+	}
+
+	row.Categories = normalizeCategories(row.Categories)
+	unknown := []string{}
+	for _, slug := range row.Categories {
+		if !known[slug] {
+			unknown = append(unknown, slug)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		r.fail("categories", "unknown categories: %s", strings.Join(unknown, ", "))
+	}
+
+	if row.WeightGrams < 0 {
+		r.fail("weight_grams", "weight can't be negative")
+	}
+	if d := row.Dimensions; d != nil && (d.LengthMm <= 0 || d.WidthMm <= 0 || d.HeightMm <= 0) {
+		r.fail("dimensions", "length, width and height must all be positive")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		r.fail("stock", "stock can't be negative")
+	}
+}
+
+// claims the id and sku of a row for it, a later row of the file with either
+// fails
+func claimImportKeys(r *importRow, seenIDs, seenSKUs map[string]int) {
+	if r.row.ID != "" && !r.failed("id") {
+		if line, ok := seenIDs[r.row.ID]; ok {
+			r.fail("id", "the id is already on row %d", line)
+		} else {
+			seenIDs[r.row.ID] = r.line
+		}
+	}
+	if r.row.SKU != "" && !r.failed("sku") {
+		if line, ok := seenSKUs[r.row.SKU]; ok {
+			r.fail("sku", "the sku is already on row %d", line)
+		} else {
+			seenSKUs[r.row.SKU] = r.line
+		}
+	}
+}
+
+// hasColumn tells whether a row gives any of the columns, nil columns give
+// all of them
+func hasColumn(columns map[string]bool, names ...string) bool {
+	if columns == nil {
+		return true
+	}
+	for _, name := range names {
+		if columns[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyImportRow copies the columns of a valid row onto a product, nil
+// columns copy all of them. A blank sku or img keeps the one the product has.
+func applyImportRow(product *model.Product, row *request.ProductPayload, columns map[string]bool) {
+	if row.SKU != "" {
+		product.SKU = row.SKU
+	}
+	if row.Img != "" {
+		product.Img = row.Img
+	}
+	if hasColumn(columns, "title") {
+		product.Title = row.Title
+	}
+	if hasColumn(columns, "desc") {
+		product.Desc = row.Desc
+	}
+	if hasColumn(columns, "categories") {
+		product.Categories = nonNilStrings(row.Categories)
+	}
+	if hasColumn(columns, "size") {
+		product.Size = nonNilStrings(row.Size)
+	}
+	if hasColumn(columns, "color") {
+		product.Color = nonNilStrings(row.Color)
+	}
+	if hasColumn(columns, "price", "currency") {
+		product.Price = row.Price
+	}
+	if hasColumn(columns, "prices") {
+		product.Prices = row.Prices
+	}
+	if product.Prices == nil {
+		product.Prices = []model.Money{}
+	}
+	if hasColumn(columns, "instock") {
+		product.InStock = row.InStock
+	}
+	if hasColumn(columns, "tax_category") {
+		product.TaxCategory = model.NormalizeTaxCategory(row.TaxCategory)
+	}
+	if hasColumn(columns, "weight_grams") {
+		product.WeightGrams = row.WeightGrams
+	}
+	if hasColumn(columns, "length_mm", "width_mm", "height_mm") {
+		product.Dimensions = dimensions(row.Dimensions)
+	}
+	if hasColumn(columns, "stock") {
+		product.Stock = row.Stock
+		if product.Stock != nil {
+			product.InStock = *product.Stock > 0
+		}
+	}
+}
+
+// importUpdate sets the fields of an existing product the row gives
+func importUpdate(product *model.Product, columns map[string]bool) bson.M {
+	set := bson.M{}
+	if product.SKU != "" {
+		set["sku"] = product.SKU
+	}
+	fields := []struct {
+		columns []string
+		field   string
+		value   any
+	}{
+		{[]string{"title"}, "title", product.Title},
+		{[]string{"desc"}, "desc", product.Desc},
+		{[]string{"img"}, "img", product.Img},
+		{[]string{"categories"}, "categories", product.Categories},
+		{[]string{"size"}, "size", product.Size},
+		{[]string{"color"}, "color", product.Color},
+		{[]string{"price", "currency"}, "price", product.Price},
+		{[]string{"prices"}, "prices", product.Prices},
+		{[]string{"instock", "stock"}, "instock", product.InStock},
+		{[]string{"stock"}, "stock", product.Stock},
+		{[]string{"tax_category"}, "taxcategory", product.TaxCategory},
+		{[]string{"weight_grams"}, "weightgrams", product.WeightGrams},
+		{[]string{"length_mm", "width_mm", "height_mm"}, "dimensions", product.Dimensions},
+	}
+	for _, f := range fields {
+		if hasColumn(columns, f.columns...) {
+			set[f.field] = f.value
+		}
+	}
+	return set
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func parseProductCSV(data []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	// short and long rows are reported on the row instead of ending the file
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	present := map[string]bool{}
+	for n, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !contains(ProductCSVHeader, name) {
+			return nil, fmt.Errorf("unknown column %q, use %s", name, strings.Join(ProductCSVHeader, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = n
+		present[name] = true
+	}
+	for _, name := range requiredProductColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the %q column is missing", name)
+		}
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		r := importRow{line: line, columns: present}
+		if len(record) != len(header) {
+			r.fail("", "the row has %d columns, the header %d", len(record), len(header))
+		} else {
+			csvProductRow(&r, columns, record)
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// csvProductRow fills in a row from the CSV columns it has. Cells quoted by
+// the export to keep them from being run as formulas are read unquoted.
+func csvProductRow(r *importRow, columns map[string]int, record []string) {
+	get := func(name string) string {
+		if n, ok := columns[name]; ok {
+			return strings.TrimSpace(utils.CSVUnquote(strings.TrimSpace(record[n])))
+		}
+		return ""
+	}
+	row := &r.row
+	row.ID = get("id")
+	row.SKU = get("sku")
+	row.Title = get("title")
+	row.Desc = get("desc")
+	row.Img = get("img")
+	row.Categories = splitCSVList(get("categories"))
+	row.Size = splitCSVList(get("size"))
+	row.Color = splitCSVList(get("color"))
+	row.TaxCategory = get("tax_category")
+
+	currency := strings.ToUpper(get("currency"))
+	if get("price") != "" {
+		price, err := model.ParseDecimal(get("price"), currency)
+		if err != nil {
+			r.fail("price", "%s", err.Error())
+		}
+		row.Price = price
+	}
+	for _, entry := range splitCSVList(get("prices")) {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			r.fail("prices", "write prices as \"17.99 EUR; 15.49 GBP\"")
+			break
+		}
+		price, err := model.ParseDecimal(fields[0], strings.ToUpper(fields[1]))
+		if err != nil {
+			r.fail("prices", "%s", err.Error())
+			break
+		}
+		row.Prices = append(row.Prices, price)
+	}
+
+	if value := get("instock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			r.fail("instock", "instock must be true or false")
+		}
+		row.InStock = inStock
+	}
+
+	number := func(name string) int {
+		value := get(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			r.fail(name, "%s must be a whole number", name)
+		}
+		return n
+	}
+	row.WeightGrams = number("weight_grams")
+	length, width, height := number("length_mm"), number("width_mm"), number("height_mm")
+	if length != 0 || width != 0 || height != 0 {
+		row.Dimensions = &request.DimensionsPayload{LengthMm: length, WidthMm: width, HeightMm: height}
+	}
+	if get("stock") != "" {
+		stock := number("stock")
+		row.Stock = &stock
+	}
+}
+
+func splitCSVList(value string) []string {
+	values := []string{}
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// ProductCSVRow renders a product in the columns of ProductCSVHeader, with
+// cells that look like formulas quoted
+func ProductCSVRow(product *model.Product) []string {
+	prices := make([]string, 0, len(product.Prices))
+	for _, price := range product.Prices {
+		prices = append(prices, price.Format())
+	}
+	row := []string{
+		product.ID.Hex(),
+		product.SKU,
+		product.Title,
+		product.Desc,
+		product.Img,
+		strings.Join(product.Categories, "; "),
+		strings.Join(product.Size, "; "),
+		strings.Join(product.Color, "; "),
+		product.Price.Decimal(),
+		product.Price.Currency,
+		strings.Join(prices, "; "),
+		strconv.FormatBool(product.InStock),
+		product.TaxCategory,
+		strconv.Itoa(product.WeightGrams),
+		"", "", "", "",
+	}
+	if d := product.Dimensions; d != nil {
+		row[14], row[15], row[16] = strconv.Itoa(d.LengthMm), strconv.Itoa(d.WidthMm), strconv.Itoa(d.HeightMm)
+	}
+	if product.Stock != nil {
+		row[17] = strconv.Itoa(*product.Stock)
+	}
+	return utils.CSVSafe(row)
+}
+
+// a JSON object per line, in the shape of the JSON Lines export
+func parseProductJSONL(data []byte) ([]importRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+
+	rows := []importRow{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		r := importRow{line: line, columns: map[string]bool{}}
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(text, &r.row); err != nil {
+			r.row = request.ProductImportRow{}
+			r.fail("", "invalid JSON: %s", err.Error())
+		} else if err := json.Unmarshal(text, &keys); err == nil {
+			for key := range keys {
+				for _, column := range jsonProductColumns[strings.ToLower(key)] {
+					r.columns[column] = true
+				}
+			}
+		}
+		rows = append(rows, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
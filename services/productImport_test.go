@@ -0,0 +1,324 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the fields of the first error of each row, "-" for a row without errors
+func rowErrors(rows []importRow) []string {
+	fields := []string{}
+	for _, r := range rows {
+		if len(r.errs) == 0 {
+			fields = append(fields, "-")
+		} else {
+			fields = append(fields, r.errs[0].Field)
+		}
+	}
+	return fields
+}
+
+func TestParseProductCSVFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"empty", "", "the file is empty"},
+		{"unknown column", "title,desc,price,currency,colour\n", `unknown column "colour"`},
+		{"column twice", "title,desc,price,currency,Title\n", `column "title" appears twice`},
+		{"missing column", "title,desc,price\n", `the "currency" column is missing`},
+		{"header only", "title,desc,price,currency\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseProductCSV([]byte(tt.data))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseProductCSVRows(t *testing.T) {
+	data := strings.Join([]string{
+		"sku,title,desc,price,currency,prices,instock,weight_grams,length_mm,width_mm,height_mm",
+		"A-1,Mug,A mug,12.50,usd,,true,350,100,80,80",
+		"A-2,Mug,A mug,12.50,USD",
+		"",
+		"A-3,Mug,A mug,12.505,USD,,true,,,,",
+		"A-4,Mug,A mug,12.50,XXX,,true,,,,",
+		"A-5,Mug,A mug,12.50,USD,11.00,true,,,,",
+		"A-6,Mug,A mug,12.50,USD,11.00 EUR; 9.999 GBP,true,,,,",
+		"A-7,Mug,A mug,12.50,USD,,maybe,,,,",
+		"A-8,Mug,A mug,12.50,USD,,true,heavy,,,",
+		"A-9,Mug,A mug,12.50,USD,,true,,,,,extra",
+	}, "\n")
+	rows, err := parseProductCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"-", "", "price", "price", "prices", "prices", "instock", "weight_grams", ""}
+	if got := rowErrors(rows); !reflect.DeepEqual(got, want) {
+		t.Fatalf("row errors = %v, want %v", got, want)
+	}
+	// the blank line is skipped, but counted for the row numbers
+	if rows[2].line != 5 {
+		t.Errorf("third row is on line %d, want 5", rows[2].line)
+	}
+
+	first := rows[0].row
+	if first.SKU != "A-1" || first.Price != model.NewMoney(1250, "USD") || !first.InStock || first.WeightGrams != 350 {
+		t.Errorf("first row = %+v", first)
+	}
+	if d := first.Dimensions; d == nil || d.LengthMm != 100 || d.WidthMm != 80 || d.HeightMm != 80 {
+		t.Errorf("dimensions = %+v", first.Dimensions)
+	}
+	if !rows[0].columns["instock"] || rows[0].columns["categories"] {
+		t.Errorf("columns = %v, want the header's", rows[0].columns)
+	}
+}
+
+func TestParseProductJSONLColumns(t *testing.T) {
+	data := `{"sku":"A-1","title":"Mug","desc":"A mug","price":{"amount":1250,"currency":"USD"},"Dimensions":null}
+not json
+{"_id":"` + primitive.NewObjectID().Hex() + `","title":"Mug","desc":"A mug","price":{"amount":1250,"currency":"USD"},"instock":false}`
+	rows, err := parseProductJSONL([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowErrors(rows); !reflect.DeepEqual(got, []string{"-", "", "-"}) {
+		t.Fatalf("row errors = %v", got)
+	}
+
+	want := map[string]bool{"sku": true, "title": true, "desc": true, "price": true, "currency": true,
+		"length_mm": true, "width_mm": true, "height_mm": true}
+	if !reflect.DeepEqual(rows[0].columns, want) {
+		t.Errorf("columns = %v, want %v", rows[0].columns, want)
+	}
+	if !rows[2].columns["id"] || !rows[2].columns["instock"] || rows[2].columns["categories"] {
+		t.Errorf("columns = %v", rows[2].columns)
+	}
+}
+
+func TestValidateImportRow(t *testing.T) {
+	valid := func() request.ProductImportRow {
+		return request.ProductImportRow{ProductPayload: request.ProductPayload{
+			SKU:        " A-1 ",
+			Title:      " Mug ",
+			Desc:       "A mug",
+			Categories: []string{"Kitchen", "kitchen"},
+			Price:      model.NewMoney(1250, "USD"),
+		}}
+	}
+	known := map[string]bool{"kitchen": true}
+
+	tests := []struct {
+		name   string
+		change func(*request.ProductImportRow)
+		field  string
+	}{
+		{"valid", func(*request.ProductImportRow) {}, ""},
+		{"invalid id", func(r *request.ProductImportRow) { r.ID = "123" }, "id"},
+		{"sku with spaces", func(r *request.ProductImportRow) { r.SKU = "A 1" }, "sku"},
+		{"sku too long", func(r *request.ProductImportRow) { r.SKU = strings.Repeat("a", 65) }, "sku"},
+		{"no title", func(r *request.ProductImportRow) { r.Title = " " }, "title"},
+		{"no desc", func(r *request.ProductImportRow) { r.Desc = "" }, "desc"},
+		{"no price", func(r *request.ProductImportRow) { r.Price = model.Money{} }, "price"},
+		{"negative price", func(r *request.ProductImportRow) { r.Price = model.NewMoney(-1, "USD") }, "price"},
+		{"price twice", func(r *request.ProductImportRow) { r.Prices = []model.Money{model.NewMoney(1100, "USD")} }, "prices"},
+		{"unknown category", func(r *request.ProductImportRow) { r.Categories = []string{"garden"} }, "categories"},
+		{"negative weight", func(r *request.ProductImportRow) { r.WeightGrams = -1 }, "weight_grams"},
+		{"negative stock", func(r *request.ProductImportRow) { stock := -1; r.Stock = &stock }, "stock"},
+		{"flat box", func(r *request.ProductImportRow) {
+			r.Dimensions = &request.DimensionsPayload{LengthMm: 10, WidthMm: 10}
+		}, "dimensions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := importRow{line: 2, row: valid()}
+			tt.change(&r.row)
+			validateImportRow(&r, known)
+			got := ""
+			if len(r.errs) > 0 {
+				got = r.errs[0].Field
+			}
+			if got != tt.field {
+				t.Errorf("error field = %q (%v), want %q", got, r.errs, tt.field)
+			}
+		})
+	}
+
+	r := importRow{row: valid()}
+	validateImportRow(&r, known)
+	if r.row.SKU != "A-1" || r.row.Title != "Mug" || !reflect.DeepEqual(r.row.Categories, []string{"kitchen"}) {
+		t.Errorf("row was not normalised: %+v", r.row)
+	}
+}
+
+func TestClaimImportKeys(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	rows := []importRow{
+		{line: 2, row: request.ProductImportRow{ID: id, ProductPayload: request.ProductPayload{SKU: "A-1"}}},
+		{line: 3, row: request.ProductImportRow{ID: id, ProductPayload: request.ProductPayload{SKU: "A-2"}}},
+		{line: 4, row: request.ProductImportRow{ProductPayload: request.ProductPayload{SKU: "A-1"}}},
+		{line: 5, row: request.ProductImportRow{ProductPayload: request.ProductPayload{SKU: "A-3"}}},
+	}
+	seenIDs, seenSKUs := map[string]int{}, map[string]int{}
+	for n := range rows {
+		claimImportKeys(&rows[n], seenIDs, seenSKUs)
+	}
+
+	if got := rowErrors(rows); !reflect.DeepEqual(got, []string{"-", "id", "sku", "-"}) {
+		t.Fatalf("row errors = %v", got)
+	}
+	if msg := rows[1].errs[0].Error; msg != "the id is already on row 2" {
+		t.Errorf("id error = %q", msg)
+	}
+	if msg := rows[2].errs[0].Error; msg != "the sku is already on row 2" {
+		t.Errorf("sku error = %q", msg)
+	}
+}
+
+func TestApplyImportRowKeepsMissingColumns(t *testing.T) {
+	existing := model.Product{
+		ID:          primitive.NewObjectID(),
+		SKU:         "A-1",
+		Title:       "Mug",
+		Desc:        "A mug",
+		Img:         "/images/mug.png",
+		Categories:  []string{"kitchen"},
+		Size:        []string{"M"},
+		Color:       []string{"red", "blue"},
+		Price:       model.NewMoney(1250, "USD"),
+		Prices:      []model.Money{model.NewMoney(1100, "EUR")},
+		InStock:     true,
+		TaxCategory: "reduced",
+		WeightGrams: 350,
+		Dimensions:  &model.Dimensions{LengthMm: 100, WidthMm: 80, HeightMm: 80},
+	}
+
+	rows, err := parseProductCSV([]byte("sku,title,desc,price,currency\nA-1,Large mug,A large mug,14.00,USD\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &rows[0]
+	validateImportRow(r, map[string]bool{})
+	if len(r.errs) > 0 {
+		t.Fatalf("row errors: %v", r.errs)
+	}
+
+	product := existing
+	applyImportRow(&product, &r.row.ProductPayload, r.columns)
+	want := existing
+	want.Title, want.Desc, want.Price = "Large mug", "A large mug", model.NewMoney(1400, "USD")
+	if !reflect.DeepEqual(product, want) {
+		t.Errorf("got %+v\nwant %+v", product, want)
+	}
+
+	set := importUpdate(&product, r.columns)
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	for _, key := range []string{"sku", "title", "desc", "price"} {
+		if _, ok := set[key]; !ok {
+			t.Errorf("update leaves out %s", key)
+		}
+	}
+	if len(set) != 4 {
+		t.Errorf("update sets %v, want only the columns of the file", keys)
+	}
+
+	// a new product takes every field, missing ones as their zero value
+	created := model.Product{}
+	applyImportRow(&created, &r.row.ProductPayload, nil)
+	if created.InStock || created.Categories == nil || created.Prices == nil || created.TaxCategory != model.DefaultTaxCategory {
+		t.Errorf("created = %+v", created)
+	}
+}
+
+func TestProductCSVRoundTrip(t *testing.T) {
+	stock := 12
+	original := model.Product{
+		ID:          primitive.NewObjectID(),
+		SKU:         "MUG-1",
+		Title:       "=HYPERLINK(\"http://evil\")",
+		Desc:        "-- the best mug, 'tis said, @home or +away",
+		Img:         "/images/mug.png",
+		Categories:  []string{"kitchen", "gifts"},
+		Size:        []string{"M", "L"},
+		Color:       []string{"red"},
+		Price:       model.NewMoney(1250, "USD"),
+		Prices:      []model.Money{model.NewMoney(1100, "EUR"), model.NewMoney(999, "GBP")},
+		InStock:     true,
+		TaxCategory: "reduced",
+		WeightGrams: 350,
+		Dimensions:  &model.Dimensions{LengthMm: 100, WidthMm: 80, HeightMm: 80},
+		Stock:       &stock,
+	}
+	quoted := original
+	quoted.Title = "'tis a mug"
+	quoted.Desc = "@mention"
+	quoted.ID = primitive.NewObjectID()
+	quoted.Stock = nil
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(ProductCSVHeader)
+	for _, product := range []model.Product{original, quoted} {
+		w.Write(ProductCSVRow(&product))
+	}
+	w.Flush()
+
+	// nothing reaches the spreadsheet as a formula
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records[1:] {
+		for _, cell := range record {
+			if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+				t.Errorf("cell %q is exported as a formula", cell)
+			}
+		}
+	}
+
+	rows, err := parseProductCSV(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{"kitchen": true, "gifts": true}
+	for n, want := range []model.Product{original, quoted} {
+		r := &rows[n]
+		validateImportRow(r, known)
+		if len(r.errs) > 0 {
+			t.Fatalf("row %d errors: %v", n, r.errs)
+		}
+		if r.row.ID != want.ID.Hex() {
+			t.Errorf("row %d id = %s, want %s", n, r.row.ID, want.ID.Hex())
+		}
+		got := model.Product{ID: want.ID}
+		applyImportRow(&got, &r.row.ProductPayload, r.columns)
+		if !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			t.Errorf("row %d round trip\ngot  %s\nwant %s", n, gotJSON, wantJSON)
+		}
+	}
+}
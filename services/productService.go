@@ -2,13 +2,18 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/souvikjs01/go-ecommerce/model"
 	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/storage"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ProductService interface {
@@ -19,15 +24,19 @@ type ProductService interface {
 	GetProductDetailsByID(productId string) (*model.Product, error)
 	GetAllProduct() (*[]model.Product, error)
 	GetProductsByQuery(query string) (*[]model.Product, error)
+	ExportProducts(emit func(*model.Product) error) error
 }
 
 type ProductServiceStruct struct {
 	db *mongo.Client
+	// holds the uploaded images
+	store storage.Storage
 }
 
-func NewProductService(db *mongo.Client) *ProductServiceStruct {
+func NewProductService(db *mongo.Client, store storage.Storage) *ProductServiceStruct {
 	return &ProductServiceStruct{
-		db: db,
+		db:    db,
+		store: store,
 	}
 }
 
@@ -44,6 +53,17 @@ func (p *ProductServiceStruct) CreateProduct(userId string, productInfo *request
 		err_ch <- err
 	}
 
+	if err := validatePrice(productInfo.Price); err != nil {
+		return nil, err
+	}
+	if err := validatePrices(productInfo.Price, productInfo.Prices); err != nil {
+		return nil, err
+	}
+	sku, err := validateSKU(productInfo.SKU)
+	if err != nil {
+		return nil, err
+	}
+
 	newProduct := model.NewProduct(
 		&(*productInfo).Title,
 		&(*productInfo).Desc,
@@ -55,14 +75,36 @@ func (p *ProductServiceStruct) CreateProduct(userId string, productInfo *request
 		&(*productInfo).InStock,
 		&user_obj_id,
 	)
+	newProduct.SKU = sku
+	newProduct.Prices = productInfo.Prices
+	newProduct.TaxCategory = model.NormalizeTaxCategory(productInfo.TaxCategory)
+	newProduct.WeightGrams = productInfo.WeightGrams
+	newProduct.Dimensions = dimensions(productInfo.Dimensions)
+	if productInfo.Stock != nil {
+		newProduct.Stock = productInfo.Stock
+		newProduct.InStock = *productInfo.Stock > 0
+	}
+	if newProduct.Prices == nil {
+		newProduct.Prices = []model.Money{}
+	}
 
 	go func() {
 		defer close(product_ch)
 		defer close(err_ch)
 
+		categories, err := validateCategories(ctx, p.db, newProduct.Categories)
+		if err != nil {
+			err_ch <- err
+			return
+		}
+		newProduct.Categories = categories
+
 		// save into the Database
 		_, err = p.db.Database("go-ecomm").Collection("products").InsertOne(ctx, newProduct)
-		if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			err_ch <- model.ErrMsg{Err: fmt.Errorf("a product with the sku %q already exists", sku), Code: 409}
+			return
+		} else if err != nil {
 			err_ch <- err
 			return
 		}
@@ -182,6 +224,7 @@ func (p *ProductServiceStruct) DeleteProductsDetails(productId *string) (*model.
 			errChan <- err
 			return
 		}
+		go removeImageFiles(p.store, prod.Images)
 		productChan <- &prod
 	}()
 
@@ -225,7 +268,16 @@ func (p *ProductServiceStruct) UpdateProductsDetails(product_id *string, update_
 			errChan <- err
 			return
 		}
+		before := prod
 
+		if update_product.SKU != nil {
+			sku, err := validateSKU(*update_product.SKU)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			prod.SKU = sku
+		}
 		if update_product.Title != nil {
 			prod.Title = *update_product.Title
 		}
@@ -236,13 +288,40 @@ func (p *ProductServiceStruct) UpdateProductsDetails(product_id *string, update_
 			prod.Img = *update_product.Img
 		}
 		if update_product.Price != nil {
+			if err := validatePrice(*update_product.Price); err != nil {
+				errChan <- err
+				return
+			}
 			prod.Price = *update_product.Price
 		}
+		if update_product.Prices != nil {
+			prod.Prices = *update_product.Prices
+		}
+		if update_product.Price != nil || update_product.Prices != nil {
+			if err := validatePrices(prod.Price, prod.Prices); err != nil {
+				errChan <- err
+				return
+			}
+		}
+		if update_product.TaxCategory != nil {
+			prod.TaxCategory = model.NormalizeTaxCategory(*update_product.TaxCategory)
+		}
+		if update_product.WeightGrams != nil {
+			prod.WeightGrams = *update_product.WeightGrams
+		}
+		if update_product.Dimensions != nil {
+			prod.Dimensions = dimensions(update_product.Dimensions)
+		}
 		if update_product.Size != nil {
 			prod.Size = *update_product.Size
 		}
 		if update_product.Categories != nil {
-			prod.Categories = *update_product.Categories
+			categories, err := validateCategories(ctx, p.db, *update_product.Categories)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			prod.Categories = categories
 		}
 		if update_product.Color != nil {
 			prod.Color = *update_product.Color
@@ -250,22 +329,43 @@ func (p *ProductServiceStruct) UpdateProductsDetails(product_id *string, update_
 		if update_product.InStock != nil {
 			prod.InStock = *update_product.InStock
 		}
+		// orders take from the stock meanwhile, it is only written when set
+		stock := prod.Stock
+		prod.Stock = update_product.Stock
+		if prod.Stock != nil {
+			prod.InStock = *prod.Stock > 0
+		}
+		rating, images := prod.Rating, prod.Images
+		prod.Rating, prod.Images = nil, nil
 
+		update := bson.M{
+			"$set": prod,
+		}
+		// an empty sku is left out of $set, clearing it needs an $unset
+		if prod.SKU == "" && before.SKU != "" {
+			update["$unset"] = bson.M{"sku": ""}
+		}
 		_, err = p.db.Database("go-ecomm").Collection("products").UpdateOne(ctx,
 			bson.M{
 				"_id": bson.M{
 					"$eq": prod_objId,
 				},
 			},
-			bson.M{
-				"$set": prod,
-			},
+			update,
 		)
 
-		if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("a product with the sku %q already exists", prod.SKU), Code: 409}
+			return
+		} else if err != nil {
 			errChan <- err
 			return
 		}
+		prod.Rating, prod.Images = rating, images
+		if prod.Stock == nil {
+			prod.Stock = stock
+		}
+		go queueProductAlerts(p.db, &before, &prod)
 		prodChan <- prod
 	}()
 
@@ -461,3 +561,128 @@ func (p *ProductServiceStruct) GetAllProduct() (*[]model.Product, error) {
 	}
 
 }
+
+// Streams the whole catalogue to emit, oldest product first
+func (p *ProductServiceStruct) ExportProducts(emit func(*model.Product) error) error {
+	// the catalogue can be large, so it gets a much longer deadline than regular requests
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+	defer cancel()
+
+	cur, err := p.db.Database("go-ecomm").Collection("products").Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(500),
+	)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var product model.Product
+		if err := cur.Decode(&product); err != nil {
+			return err
+		}
+		if err := emit(&product); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func validatePrice(price model.Money) error {
+	if !model.IsValidCurrency(price.Currency) {
+		return model.ErrMsg{Err: fmt.Errorf("price needs a supported currency"), Code: 400}
+	}
+	if price.IsNegative() {
+		return model.ErrMsg{Err: fmt.Errorf("price can't be negative"), Code: 400}
+	}
+	return nil
+}
+
+// explicit prices need one entry per currency other than the base price's
+func validatePrices(base model.Money, prices []model.Money) error {
+	seen := map[string]bool{base.Currency: true}
+	for _, price := range prices {
+		if err := validatePrice(price); err != nil {
+			return err
+		}
+		if seen[price.Currency] {
+			return model.ErrMsg{Err: fmt.Errorf("duplicate price in %s", price.Currency), Code: 400}
+		}
+		seen[price.Currency] = true
+	}
+	return nil
+}
+
+// SKUs are trimmed and can't contain spaces, they end up in spreadsheets
+func validateSKU(sku string) (string, error) {
+	sku = strings.TrimSpace(sku)
+	if len(sku) > 64 {
+		return "", model.ErrMsg{Err: fmt.Errorf("sku is longer than 64 characters"), Code: 400}
+	}
+	if strings.IndexFunc(sku, unicode.IsSpace) >= 0 {
+		return "", model.ErrMsg{Err: fmt.Errorf("sku %q contains spaces", sku), Code: 400}
+	}
+	return sku, nil
+}
+
+func dimensions(payload *request.DimensionsPayload) *model.Dimensions {
+	if payload == nil {
+		return nil
+	}
+	return &model.Dimensions{
+		LengthMm: payload.LengthMm,
+		WidthMm:  payload.WidthMm,
+		HeightMm: payload.HeightMm,
+	}
+}
+
+// reserveStock takes the ordered quantities off the products that count their
+// stock, a product that runs out goes out of stock. When a line can't be
+// covered nothing is taken.
+func reserveStock(ctx context.Context, db *mongo.Client, lines []model.ProductInfo) error {
+	products := db.Database("go-ecomm").Collection("products")
+	for n, line := range lines {
+		res, err := products.UpdateOne(ctx,
+			bson.M{"_id": line.ProductID, "stock": bson.M{"$gte": line.Quantity}},
+			stockChange(-line.Quantity),
+		)
+		if err == nil && res.MatchedCount == 0 {
+			var prod model.Product
+			err = products.FindOne(ctx, bson.M{"_id": line.ProductID},
+				options.FindOne().SetProjection(bson.M{"title": 1, "stock": 1})).Decode(&prod)
+			if err == nil && prod.Stock == nil {
+				// the stock of the product isn't counted
+				continue
+			}
+			if err == nil {
+				err = model.ErrMsg{Err: fmt.Errorf("only %d of %s are left", *prod.Stock, prod.Title), Code: 409}
+			}
+		}
+		if err != nil {
+			releaseStock(ctx, db, lines[:n])
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseStock gives the quantities reserveStock took back
+func releaseStock(ctx context.Context, db *mongo.Client, lines []model.ProductInfo) {
+	for _, line := range lines {
+		_, err := db.Database("go-ecomm").Collection("products").UpdateOne(ctx,
+			bson.M{"_id": line.ProductID, "stock": bson.M{"$type": "number"}},
+			stockChange(line.Quantity),
+		)
+		if err != nil {
+			fmt.Println("failed to release stock of product", line.ProductID.Hex()+":", err)
+		}
+	}
+}
+
+// changes the stock by delta and keeps instock in line with it
+func stockChange(delta int) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"stock": bson.M{"$add": bson.A{"$stock", delta}}}}},
+		{{Key: "$set", Value: bson.M{"instock": bson.M{"$gt": bson.A{"$stock", 0}}}}},
+	}
+}
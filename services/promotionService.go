@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/tax"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PromotionService interface {
+	CreatePromotion(payload *request.PromotionPayload) (*model.Promotion, error)
+	GetPromotions() (*[]model.Promotion, error)
+	UpdatePromotion(payload *request.UpdatePromotionPayload, promotionId string) (*model.Promotion, error)
+	DeletePromotion(promotionId string) (*model.Promotion, error)
+	DryRun(payload *request.DryRunPayload) (*model.PromotionResult, error)
+}
+
+type PromotionServiceStruct struct {
+	db    *mongo.Client
+	taxes tax.Calculator
+}
+
+func NewPromotionService(db *mongo.Client, taxes tax.Calculator) *PromotionServiceStruct {
+	return &PromotionServiceStruct{
+		db:    db,
+		taxes: taxes,
+	}
+}
+
+// Create an automatic promotion
+func (p *PromotionServiceStruct) CreatePromotion(payload *request.PromotionPayload) (*model.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	promotion, err := buildPromotion(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(promotionChan)
+
+		categories, err := validateCategories(ctx, p.db, promotion.Categories)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotion.Categories = categories
+
+		_, err = p.db.Database("go-ecomm").Collection("promotions").InsertOne(ctx, promotion)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- promotion
+	}()
+
+	for {
+		select {
+		case promotion := <-promotionChan:
+			return promotion, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// All promotions in the order they are evaluated
+func (p *PromotionServiceStruct) GetPromotions() (*[]model.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	promotionsChan := make(chan *[]model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(promotionsChan)
+
+		cur, err := p.db.Database("go-ecomm").Collection("promotions").Find(ctx, bson.M{},
+			options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "createdat", Value: 1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		promotions := []model.Promotion{}
+		if err := cur.All(ctx, &promotions); err != nil {
+			errChan <- err
+			return
+		}
+		promotionsChan <- &promotions
+	}()
+
+	for {
+		select {
+		case promotions := <-promotionsChan:
+			return promotions, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Change the name, priority, exclusivity or schedule of a promotion. The
+// rule itself stays fixed so orders keep matching what they were given.
+func (p *PromotionServiceStruct) UpdatePromotion(payload *request.UpdatePromotionPayload, promotionId string) (*model.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	promotionObjID, err := primitive.ObjectIDFromHex(promotionId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid promotionId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(promotionChan)
+
+		collection := p.db.Database("go-ecomm").Collection("promotions")
+
+		var promotion model.Promotion
+		if err := collection.FindOne(ctx, bson.M{"_id": promotionObjID}).Decode(&promotion); err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.Name != nil {
+			promotion.Name = *payload.Name
+		}
+		if payload.Description != nil {
+			promotion.Description = *payload.Description
+		}
+		if payload.Priority != nil {
+			promotion.Priority = *payload.Priority
+		}
+		if payload.Exclusive != nil {
+			promotion.Exclusive = *payload.Exclusive
+		}
+		if payload.Active != nil {
+			promotion.Active = *payload.Active
+		}
+		if payload.StartsAt != nil {
+			promotion.StartsAt = payload.StartsAt
+		}
+		if payload.EndsAt != nil {
+			promotion.EndsAt = payload.EndsAt
+		}
+		if err := promotion.Validate(); err != nil {
+			errChan <- model.ErrMsg{Err: err, Code: 400}
+			return
+		}
+		promotion.UpdatedAt = time.Now()
+
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": promotionObjID}, promotion)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- &promotion
+	}()
+
+	for {
+		select {
+		case promotion := <-promotionChan:
+			return promotion, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Delete a promotion, orders keep the discounts they were given
+func (p *PromotionServiceStruct) DeletePromotion(promotionId string) (*model.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	promotionChan := make(chan *model.Promotion, 32)
+	errChan := make(chan error, 32)
+
+	promotionObjID, err := primitive.ObjectIDFromHex(promotionId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid promotionId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(promotionChan)
+
+		var promotion model.Promotion
+		err := p.db.Database("go-ecomm").Collection("promotions").FindOneAndDelete(ctx, bson.M{"_id": promotionObjID}).Decode(&promotion)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotionChan <- &promotion
+	}()
+
+	for {
+		select {
+		case promotion := <-promotionChan:
+			return promotion, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Preview what the promotions and coupons would do to a cart without saving
+// anything
+func (p *PromotionServiceStruct) DryRun(payload *request.DryRunPayload) (*model.PromotionResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	resultChan := make(chan *model.PromotionResult, 32)
+	errChan := make(chan error, 32)
+
+	drafts := make([]model.Promotion, 0, len(payload.Drafts))
+	for i := range payload.Drafts {
+		draft, err := buildPromotion(&payload.Drafts[i])
+		if err != nil {
+			return nil, err
+		}
+		draft.Active = true
+		drafts = append(drafts, *draft)
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(resultChan)
+
+		var userId primitive.ObjectID
+		items := []model.ProductDetails{}
+		codes := payload.CouponCodes
+
+		cartFilter := bson.M{}
+		switch {
+		case payload.CartID != "":
+			cartObjID, err := primitive.ObjectIDFromHex(payload.CartID)
+			if err != nil {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("invalid cart_id"), Code: 400}
+				return
+			}
+			cartFilter["_id"] = cartObjID
+		case payload.UserID != "":
+			userObjID, err := primitive.ObjectIDFromHex(payload.UserID)
+			if err != nil {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("invalid user_id"), Code: 400}
+				return
+			}
+			cartFilter["userid"] = userObjID
+		case len(payload.Products) > 0:
+			for _, product := range payload.Products {
+				productObjID, err := primitive.ObjectIDFromHex(product.ProductID)
+				if err != nil {
+					errChan <- model.ErrMsg{Err: fmt.Errorf("invalid product id %q", product.ProductID), Code: 400}
+					return
+				}
+				items = append(items, model.ProductDetails{ProductID: productObjID, Quantity: product.Quantity})
+			}
+		default:
+			errChan <- model.ErrMsg{Err: fmt.Errorf("cart_id, user_id or products is required"), Code: 400}
+			return
+		}
+
+		var products []model.Product
+		if len(cartFilter) > 0 {
+			cart, joined, err := findCartProducts(ctx, p.db, cartFilter)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			userId, items, products = cart.UserId, cart.Products, joined
+			if codes == nil {
+				codes = cart.CouponCodes
+			}
+		} else {
+			var err error
+			if products, err = cartProducts(ctx, p.db, items); err != nil {
+				errChan <- err
+				return
+			}
+		}
+
+		userHex := ""
+		if !userId.IsZero() {
+			userHex = userId.Hex()
+		}
+		currency, err := resolveCurrency(ctx, p.db, payload.Currency, userHex)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		contents, err := cartLines(ctx, p.db, items, products, currency)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		lines, currency := contents.Priced, contents.Currency
+
+		promotions, err := activePromotions(ctx, p.db)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		promotions = append(promotions, drafts...)
+
+		result, lineDiscounts, err := applyPromotionsAndCoupons(ctx, p.db, userId, codes, lines, currency, promotions)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if !userId.IsZero() {
+			address, err := defaultShippingAddress(ctx, p.db, userId)
+			if err == nil {
+				err = applyTax(ctx, p.taxes, result, lines, lineDiscounts, address)
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
+		resultChan <- result
+	}()
+
+	for {
+		select {
+		case result := <-resultChan:
+			return result, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func buildPromotion(payload *request.PromotionPayload) (*model.Promotion, error) {
+	productIDs, err := objectIDs(payload.ProductIDs)
+	if err != nil {
+		return nil, err
+	}
+	bundleProductIDs, err := objectIDs(payload.BundleProductIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make([]model.PromotionTier, 0, len(payload.Tiers))
+	for _, tier := range payload.Tiers {
+		tiers = append(tiers, model.PromotionTier{
+			MinSpend:   tier.MinSpend,
+			PercentOff: tier.PercentOff,
+			AmountOff:  tier.AmountOff,
+		})
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+	promotion := model.NewPromotion(&model.Promotion{
+		Name:             payload.Name,
+		Description:      payload.Description,
+		Type:             payload.Type,
+		Priority:         payload.Priority,
+		Exclusive:        payload.Exclusive,
+		Active:           active,
+		StartsAt:         payload.StartsAt,
+		EndsAt:           payload.EndsAt,
+		ProductIDs:       productIDs,
+		Categories:       payload.Categories,
+		BuyQuantity:      payload.BuyQuantity,
+		GetQuantity:      payload.GetQuantity,
+		GetPercentOff:    payload.GetPercentOff,
+		Tiers:            tiers,
+		BundleProductIDs: bundleProductIDs,
+		BundlePrice:      payload.BundlePrice,
+		BundlePercentOff: payload.BundlePercentOff,
+	})
+	if err := promotion.Validate(); err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+	return promotion, nil
+}
+
+func objectIDs(ids []string) ([]primitive.ObjectID, error) {
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid id %q", id), Code: 400}
+		}
+		objIDs = append(objIDs, objID)
+	}
+	return objIDs, nil
+}
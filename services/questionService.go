@@ -0,0 +1,898 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/moderation"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type QuestionService interface {
+	AskQuestion(payload *request.QuestionPayload, userId string) (*model.Question, error)
+	GetProductQuestions(productId string, query *request.QuestionQuery) (*model.QuestionPage, error)
+	GetMyQuestions(userId string) (*[]model.Question, error)
+	DeleteQuestion(userId, questionId string, isAdmin bool) (*model.Question, error)
+	AnswerQuestion(payload *request.AnswerPayload, userId, questionId string, isAdmin bool) (*model.Answer, error)
+	DeleteAnswer(userId, answerId string, isAdmin bool) (*model.Answer, error)
+	MarkHelpful(userId, answerId string) (*model.Answer, error)
+	UnmarkHelpful(userId, answerId string) (*model.Answer, error)
+	GetQuestionQueue(query *request.QAQueueQuery) (*model.QuestionPage, error)
+	GetAnswerQueue(query *request.QAQueueQuery) (*model.AnswerPage, error)
+	ModerateQuestion(payload *request.ModeratePayload, moderatorId, questionId string) (*model.Question, error)
+	ModerateAnswer(payload *request.ModeratePayload, moderatorId, answerId string) (*model.Answer, error)
+}
+
+// QuestionServiceStruct screens questions and answers with the same policy
+// as reviews
+type QuestionServiceStruct struct {
+	db          *mongo.Client
+	policy      *moderation.Policy
+	autoApprove bool
+}
+
+func NewQuestionService(db *mongo.Client, policy *moderation.Policy, autoApprove bool) *QuestionServiceStruct {
+	return &QuestionServiceStruct{
+		db:          db,
+		policy:      policy,
+		autoApprove: autoApprove,
+	}
+}
+
+// Ask about a product, the question is listed once it is published
+func (q *QuestionServiceStruct) AskQuestion(payload *request.QuestionPayload, userId string) (*model.Question, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(payload.ProductID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(questionChan)
+
+		db := q.db.Database("go-ecomm")
+
+		count, err := db.Collection("products").CountDocuments(ctx, bson.M{"_id": productObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if count == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		}
+		var user model.User
+		err = db.Collection("users").FindOne(ctx, bson.M{"_id": usrObjID},
+			options.FindOne().SetProjection(bson.M{"firstname": 1})).Decode(&user)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		body := strings.TrimSpace(payload.Body)
+		status, reasons := q.screen(body)
+		question := &model.Question{
+			ID:          primitive.NewObjectID(),
+			ProductID:   productObjID,
+			UserId:      usrObjID,
+			Author:      user.FirstName,
+			Body:        body,
+			Status:      status,
+			HoldReasons: reasons,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if _, err := db.Collection("questions").InsertOne(ctx, question); err != nil {
+			errChan <- err
+			return
+		}
+		questionChan <- question
+	}()
+
+	select {
+	case question := <-questionChan:
+		return question, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The published questions of a product, a page at a time, each with its
+// published answers, most helpful first
+func (q *QuestionServiceStruct) GetProductQuestions(productId string, query *request.QuestionQuery) (*model.QuestionPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.QuestionPage, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+	filter := bson.M{"productid": productObjID, "status": model.ReviewApproved}
+	sort := bson.D{{Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}
+	switch query.Sort {
+	case "oldest":
+		sort = bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}
+	case "most_answered":
+		sort = bson.D{{Key: "answercount", Value: -1}, {Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		db := q.db.Database("go-ecomm")
+		collection := db.Collection("questions")
+
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, filter, options.Find().
+			SetSort(sort).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"holdreasons": 0}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questions := []model.Question{}
+		if err := cur.All(ctx, &questions); err != nil {
+			errChan <- err
+			return
+		}
+
+		questionIDs := make([]primitive.ObjectID, 0, len(questions))
+		for _, question := range questions {
+			questionIDs = append(questionIDs, question.ID)
+		}
+		cur, err = db.Collection("answers").Find(ctx,
+			bson.M{"questionid": bson.M{"$in": questionIDs}, "status": model.ReviewApproved},
+			options.Find().
+				SetSort(bson.D{{Key: "helpful", Value: -1}, {Key: "createdat", Value: 1}}).
+				SetProjection(bson.M{"holdreasons": 0}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answers := []model.Answer{}
+		if err := cur.All(ctx, &answers); err != nil {
+			errChan <- err
+			return
+		}
+		byQuestion := map[primitive.ObjectID][]model.Answer{}
+		for _, answer := range answers {
+			byQuestion[answer.QuestionID] = append(byQuestion[answer.QuestionID], answer)
+		}
+		for i := range questions {
+			questions[i].Answers = byQuestion[questions[i].ID]
+			if questions[i].Answers == nil {
+				questions[i].Answers = []model.Answer{}
+			}
+		}
+
+		pageChan <- &model.QuestionPage{
+			Questions: questions,
+			Page:      page,
+			Limit:     limit,
+			Total:     total,
+		}
+	}()
+
+	select {
+	case page := <-pageChan:
+		return page, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (q *QuestionServiceStruct) GetMyQuestions(userId string) (*[]model.Question, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	questionsChan := make(chan *[]model.Question, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(questionsChan)
+
+		cur, err := q.db.Database("go-ecomm").Collection("questions").Find(ctx,
+			bson.M{"userid": usrObjID},
+			options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questions := []model.Question{}
+		if err := cur.All(ctx, &questions); err != nil {
+			errChan <- err
+			return
+		}
+		questionsChan <- &questions
+	}()
+
+	select {
+	case questions := <-questionsChan:
+		return questions, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Delete an own question with its answers, admins can delete any
+func (q *QuestionServiceStruct) DeleteQuestion(userId, questionId string, isAdmin bool) (*model.Question, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid questionId"), Code: 400}
+	}
+	filter := bson.M{"_id": questionObjID}
+	if !isAdmin {
+		filter["userid"] = usrObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(questionChan)
+
+		db := q.db.Database("go-ecomm")
+
+		var question model.Question
+		if err := db.Collection("questions").FindOneAndDelete(ctx, filter).Decode(&question); err != nil {
+			errChan <- err
+			return
+		}
+		answerIDs, err := db.Collection("answers").Distinct(ctx, "_id", bson.M{"questionid": question.ID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if len(answerIDs) > 0 {
+			if _, err := db.Collection("answer_votes").DeleteMany(ctx, bson.M{"answerid": bson.M{"$in": answerIDs}}); err != nil {
+				errChan <- err
+				return
+			}
+			if _, err := db.Collection("answers").DeleteMany(ctx, bson.M{"questionid": question.ID}); err != nil {
+				errChan <- err
+				return
+			}
+		}
+		questionChan <- &question
+	}()
+
+	select {
+	case question := <-questionChan:
+		return question, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Answer a published question. Admins, the seller of the product and
+// customers who received it can answer, the asker is notified once the answer
+// is published.
+func (q *QuestionServiceStruct) AnswerQuestion(payload *request.AnswerPayload, userId, questionId string, isAdmin bool) (*model.Answer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid questionId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(answerChan)
+
+		db := q.db.Database("go-ecomm")
+
+		var question model.Question
+		err := db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "status": model.ReviewApproved}).Decode(&question)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		var prod model.Product
+		err = db.Collection("products").FindOne(ctx, bson.M{"_id": question.ProductID},
+			options.FindOne().SetProjection(bson.M{"user_id": 1})).Decode(&prod)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		answeredAs := ""
+		switch {
+		case isAdmin:
+			answeredAs = model.AnswerByAdmin
+		case prod.UserID == usrObjID:
+			answeredAs = model.AnswerBySeller
+		default:
+			verified, err := verifiedPurchase(ctx, q.db, usrObjID, question.ProductID)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if !verified {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("only the seller or customers who received the product can answer"), Code: 403}
+				return
+			}
+			answeredAs = model.AnswerByVerifiedBuyer
+		}
+
+		var user model.User
+		err = db.Collection("users").FindOne(ctx, bson.M{"_id": usrObjID},
+			options.FindOne().SetProjection(bson.M{"firstname": 1})).Decode(&user)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		body := strings.TrimSpace(payload.Body)
+		status, reasons := q.screen(body)
+		answer := &model.Answer{
+			ID:          primitive.NewObjectID(),
+			QuestionID:  question.ID,
+			ProductID:   question.ProductID,
+			UserId:      usrObjID,
+			Author:      user.FirstName,
+			AnsweredAs:  answeredAs,
+			Body:        body,
+			Status:      status,
+			HoldReasons: reasons,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		if _, err := db.Collection("answers").InsertOne(ctx, answer); err != nil {
+			errChan <- err
+			return
+		}
+		if answer.Published() {
+			q.published(ctx, answer)
+		}
+		answerChan <- answer
+	}()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Delete an own answer, admins can delete any
+func (q *QuestionServiceStruct) DeleteAnswer(userId, answerId string, isAdmin bool) (*model.Answer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	answerObjID, err := primitive.ObjectIDFromHex(answerId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid answerId"), Code: 400}
+	}
+	filter := bson.M{"_id": answerObjID}
+	if !isAdmin {
+		filter["userid"] = usrObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(answerChan)
+
+		db := q.db.Database("go-ecomm")
+
+		var answer model.Answer
+		if err := db.Collection("answers").FindOneAndDelete(ctx, filter).Decode(&answer); err != nil {
+			errChan <- err
+			return
+		}
+		if _, err := db.Collection("answer_votes").DeleteMany(ctx, bson.M{"answerid": answer.ID}); err != nil {
+			fmt.Println("failed to delete answer votes:", err)
+		}
+		if answer.Published() {
+			q.countAnswer(ctx, answer.QuestionID, -1)
+		}
+		answerChan <- &answer
+	}()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Vote a published answer helpful, once per customer
+func (q *QuestionServiceStruct) MarkHelpful(userId, answerId string) (*model.Answer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	answerObjID, err := primitive.ObjectIDFromHex(answerId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid answerId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(answerChan)
+
+		db := q.db.Database("go-ecomm")
+		answers := db.Collection("answers")
+
+		var answer model.Answer
+		if err := answers.FindOne(ctx, bson.M{"_id": answerObjID, "status": model.ReviewApproved}).Decode(&answer); err != nil {
+			errChan <- err
+			return
+		}
+		if answer.UserId == usrObjID {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you can't vote for your own answer"), Code: 400}
+			return
+		}
+
+		// the unique index keeps it to one vote per customer
+		_, err := db.Collection("answer_votes").InsertOne(ctx, &model.AnswerVote{
+			ID:        primitive.NewObjectID(),
+			AnswerID:  answerObjID,
+			UserId:    usrObjID,
+			CreatedAt: time.Now(),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you have already voted for this answer"), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		err = answers.FindOneAndUpdate(ctx,
+			bson.M{"_id": answerObjID},
+			bson.M{"$inc": bson.M{"helpful": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&answer)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- &answer
+	}()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Take back a helpful vote
+func (q *QuestionServiceStruct) UnmarkHelpful(userId, answerId string) (*model.Answer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	answerObjID, err := primitive.ObjectIDFromHex(answerId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid answerId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(answerChan)
+
+		db := q.db.Database("go-ecomm")
+
+		res, err := db.Collection("answer_votes").DeleteOne(ctx, bson.M{"answerid": answerObjID, "userid": usrObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if res.DeletedCount == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you haven't voted for this answer"), Code: 404}
+			return
+		}
+
+		var answer model.Answer
+		err = db.Collection("answers").FindOneAndUpdate(ctx,
+			bson.M{"_id": answerObjID},
+			bson.M{"$inc": bson.M{"helpful": -1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&answer)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answerChan <- &answer
+	}()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The questions waiting for a moderator, oldest first
+func (q *QuestionServiceStruct) GetQuestionQueue(query *request.QAQueueQuery) (*model.QuestionPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.QuestionPage, 32)
+	errChan := make(chan error, 32)
+
+	filter, page, limit := qaQueueFilter(query)
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		collection := q.db.Database("go-ecomm").Collection("questions")
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questions := []model.Question{}
+		if err := cur.All(ctx, &questions); err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- &model.QuestionPage{
+			Questions: questions,
+			Page:      page,
+			Limit:     limit,
+			Total:     total,
+		}
+	}()
+
+	select {
+	case page := <-pageChan:
+		return page, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The answers waiting for a moderator, oldest first
+func (q *QuestionServiceStruct) GetAnswerQueue(query *request.QAQueueQuery) (*model.AnswerPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.AnswerPage, 32)
+	errChan := make(chan error, 32)
+
+	filter, page, limit := qaQueueFilter(query)
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		collection := q.db.Database("go-ecomm").Collection("answers")
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		answers := []model.Answer{}
+		if err := cur.All(ctx, &answers); err != nil {
+			errChan <- err
+			return
+		}
+		pageChan <- &model.AnswerPage{
+			Answers: answers,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		}
+	}()
+
+	select {
+	case page := <-pageChan:
+		return page, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Approve or reject a question, rejecting hides its answers with it
+func (q *QuestionServiceStruct) ModerateQuestion(payload *request.ModeratePayload, moderatorId, questionId string) (*model.Question, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	questionChan := make(chan *model.Question, 32)
+	errChan := make(chan error, 32)
+
+	moderatorObjID, err := primitive.ObjectIDFromHex(moderatorId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid questionId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(questionChan)
+
+		var question model.Question
+		err := q.db.Database("go-ecomm").Collection("questions").FindOneAndUpdate(ctx,
+			bson.M{"_id": questionObjID},
+			bson.M{"$set": moderationUpdate(payload.Status, strings.TrimSpace(payload.Note), moderatorObjID, time.Now())},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&question)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		questionChan <- &question
+	}()
+
+	select {
+	case question := <-questionChan:
+		return question, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Approve or reject an answer, the asker is notified when it is published
+// for the first time
+func (q *QuestionServiceStruct) ModerateAnswer(payload *request.ModeratePayload, moderatorId, answerId string) (*model.Answer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	answerChan := make(chan *model.Answer, 32)
+	errChan := make(chan error, 32)
+
+	moderatorObjID, err := primitive.ObjectIDFromHex(moderatorId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	answerObjID, err := primitive.ObjectIDFromHex(answerId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid answerId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(answerChan)
+
+		// the answer as it was, to tell whether it became published
+		var answer model.Answer
+		now := time.Now()
+		note := strings.TrimSpace(payload.Note)
+		err := q.db.Database("go-ecomm").Collection("answers").FindOneAndUpdate(ctx,
+			bson.M{"_id": answerObjID},
+			bson.M{"$set": moderationUpdate(payload.Status, note, moderatorObjID, now)},
+		).Decode(&answer)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wasPublished := answer.Published()
+		answer.Status = payload.Status
+		answer.ModerationNote = note
+		answer.ModeratedBy = &moderatorObjID
+		answer.ModeratedAt = &now
+		if answer.Published() {
+			answer.HoldReasons = []string{}
+		}
+		if answer.Published() && !wasPublished {
+			q.published(ctx, &answer)
+		} else if wasPublished && !answer.Published() {
+			q.countAnswer(ctx, answer.QuestionID, -1)
+		}
+		answerChan <- &answer
+	}()
+
+	select {
+	case answer := <-answerChan:
+		return answer, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// the status of a new question or answer, and why it is held
+func (q *QuestionServiceStruct) screen(body string) (string, []string) {
+	reasons := q.policy.Check(body)
+	if len(reasons) > 0 || !q.autoApprove {
+		return model.ReviewPending, reasons
+	}
+	return model.ReviewApproved, reasons
+}
+
+// counts a newly published answer on its question and lets the asker know
+func (q *QuestionServiceStruct) published(ctx context.Context, answer *model.Answer) {
+	q.countAnswer(ctx, answer.QuestionID, 1)
+
+	db := q.db.Database("go-ecomm")
+	var question model.Question
+	if err := db.Collection("questions").FindOne(ctx, bson.M{"_id": answer.QuestionID}).Decode(&question); err != nil {
+		fmt.Println("failed to notify about answer:", err)
+		return
+	}
+	if question.UserId == answer.UserId {
+		return
+	}
+	var user model.User
+	err := db.Collection("users").FindOne(ctx, bson.M{"_id": question.UserId},
+		options.FindOne().SetProjection(bson.M{"email": 1, "firstname": 1})).Decode(&user)
+	if err != nil {
+		fmt.Println("failed to notify about answer:", err)
+		return
+	}
+	var prod model.Product
+	err = db.Collection("products").FindOne(ctx, bson.M{"_id": answer.ProductID},
+		options.FindOne().SetProjection(bson.M{"title": 1})).Decode(&prod)
+	if err != nil {
+		fmt.Println("failed to notify about answer:", err)
+		return
+	}
+
+	_, err = enqueueNotification(ctx, q.db, &model.Notification{
+		DedupKey: "question_answered:" + answer.ID.Hex(),
+		Kind:     "question_answered",
+		UserId:   question.UserId,
+		To:       user.Email,
+		Subject:  "Your question about " + prod.Title + " was answered",
+		Body:     fmt.Sprintf("Hi %s, %s answered your question \"%s\":\n\n%s", user.FirstName, answer.Author, question.Body, answer.Body),
+		Data: map[string]string{
+			"productId":  answer.ProductID.Hex(),
+			"questionId": question.ID.Hex(),
+			"answerId":   answer.ID.Hex(),
+		},
+	})
+	if err != nil {
+		fmt.Println("failed to notify about answer:", err)
+	}
+}
+
+func (q *QuestionServiceStruct) countAnswer(ctx context.Context, questionId primitive.ObjectID, delta int) {
+	_, err := q.db.Database("go-ecomm").Collection("questions").UpdateOne(ctx,
+		bson.M{"_id": questionId}, bson.M{"$inc": bson.M{"answercount": delta}})
+	if err != nil {
+		fmt.Println("failed to count answers:", err)
+	}
+}
+
+func qaQueueFilter(query *request.QAQueueQuery) (bson.M, int, int) {
+	status := query.Status
+	if status == "" {
+		status = model.ReviewPending
+	}
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+	return bson.M{"status": status}, page, limit
+}
+
+// the fields a moderator decision sets on a question or answer
+func moderationUpdate(status, note string, moderatorId primitive.ObjectID, at time.Time) bson.M {
+	set := bson.M{
+		"status":         status,
+		"moderationnote": note,
+		"moderatedby":    moderatorId,
+		"moderatedat":    at,
+	}
+	if status == model.ReviewApproved {
+		set["holdreasons"] = []string{}
+	}
+	return set
+}
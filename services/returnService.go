@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReturnService interface {
+	CreateReturn(payload *request.CreateReturnPayload, userId string) (*model.ReturnRequest, error)
+	GetUserReturns(userId string) (*[]model.ReturnRequest, error)
+	GetOrderReturnHistory(orderId, userId string, isAdmin bool) (*model.OrderReturnHistory, error)
+	GetAllReturns(status string) (*[]model.ReturnRequest, error)
+	ApproveReturn(returnId string, payload *request.ReviewReturnPayload) (*model.ReturnRequest, error)
+	RejectReturn(returnId string, payload *request.ReviewReturnPayload) (*model.ReturnRequest, error)
+	ReceiveReturn(returnId string, payload *request.ReceiveReturnPayload) (*model.ReturnRequest, error)
+}
+
+type ReturnServiceStruct struct {
+	db       *mongo.Client
+	invoices InvoiceService
+}
+
+func NewReturnService(db *mongo.Client, invoices InvoiceService) *ReturnServiceStruct {
+	return &ReturnServiceStruct{
+		db:       db,
+		invoices: invoices,
+	}
+}
+
+// Customer asks to return one or more lines of a delivered order
+func (r *ReturnServiceStruct) CreateReturn(payload *request.CreateReturnPayload, userId string) (*model.ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	returnChan := make(chan *model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	orderObjID, err := primitive.ObjectIDFromHex(payload.OrderID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid order_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(returnChan)
+
+		db := r.db.Database("go-ecomm")
+
+		var order model.Order
+		err := db.Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID, "userid": userObjID}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		if order.Status != model.OrderStatusDelivered {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("only delivered orders can be returned"), Code: 400}
+			return
+		}
+
+		// quantities that are already part of an open or completed return
+		alreadyReturned, err := r.returnedQuantities(ctx, orderObjID)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		ordered := map[primitive.ObjectID]int{}
+		for _, line := range order.Products {
+			ordered[line.ProductID] += line.Quantity
+		}
+
+		requested := map[primitive.ObjectID]int{}
+		items := make([]model.ReturnItem, 0, len(payload.Items))
+		for _, item := range payload.Items {
+			productObjID, err := primitive.ObjectIDFromHex(item.ProductID)
+			if err != nil {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("invalid product_id: %s", item.ProductID), Code: 400}
+				return
+			}
+			if _, ok := ordered[productObjID]; !ok {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("product %s is not part of this order", item.ProductID), Code: 400}
+				return
+			}
+
+			requested[productObjID] += item.Quantity
+			if requested[productObjID]+alreadyReturned[productObjID] > ordered[productObjID] {
+				errChan <- model.ErrMsg{Err: fmt.Errorf("cannot return more of product %s than was ordered", item.ProductID), Code: 400}
+				return
+			}
+
+			items = append(items, model.ReturnItem{
+				ProductID: productObjID,
+				Quantity:  item.Quantity,
+				Reason:    item.Reason,
+			})
+		}
+
+		newReturn := model.NewReturnRequest(orderObjID, userObjID, items)
+		newReturn.OrderNumber = order.OrderNumber
+		_, err = db.Collection("returns").InsertOne(ctx, newReturn)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		// the quantities were checked against the order as it was read, a return
+		// created meanwhile changes it and this one is taken back
+		res, err := db.Collection("orders").UpdateOne(ctx,
+			bson.M{"_id": orderObjID, "updatedat": order.UpdatedAt},
+			bson.M{
+				"$push": bson.M{"returnids": newReturn.ID},
+				"$set":  bson.M{"updatedat": time.Now()},
+			},
+		)
+		if err != nil || res.MatchedCount == 0 {
+			if _, delErr := db.Collection("returns").DeleteOne(context.Background(), bson.M{"_id": newReturn.ID}); delErr != nil {
+				fmt.Println("failed to remove return of a modified order:", newReturn.ID.Hex(), delErr)
+			}
+			if err == nil {
+				err = model.ErrMsg{Err: fmt.Errorf("order was modified concurrently, retry"), Code: 409}
+			}
+			errChan <- err
+			return
+		}
+
+		returnChan <- newReturn
+	}()
+
+	select {
+	case ret := <-returnChan:
+		return ret, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// returns of the logged in user
+func (r *ReturnServiceStruct) GetUserReturns(userId string) (*[]model.ReturnRequest, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	return r.findReturns(bson.M{"userid": userObjID})
+}
+
+// return and refund history of a single order
+func (r *ReturnServiceStruct) GetOrderReturnHistory(orderId, userId string, isAdmin bool) (*model.OrderReturnHistory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	filter := bson.M{"_id": orderObjID}
+	if !isAdmin {
+		userObjID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+		}
+		filter["userid"] = userObjID
+	}
+
+	var order model.Order
+	err = r.db.Database("go-ecomm").Collection("orders").FindOne(ctx, filter).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return nil, model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+	} else if err != nil {
+		return nil, err
+	}
+
+	returns, err := r.findReturns(bson.M{"orderid": orderObjID})
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := order.Refunds
+	if refunds == nil {
+		refunds = []model.Refund{}
+	}
+
+	return &model.OrderReturnHistory{
+		OrderID:        order.ID,
+		Returns:        *returns,
+		Refunds:        refunds,
+		RefundedAmount: order.RefundedAmount,
+	}, nil
+}
+
+// every return, optionally filtered by status (admin)
+func (r *ReturnServiceStruct) GetAllReturns(status string) (*[]model.ReturnRequest, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.findReturns(filter)
+}
+
+func (r *ReturnServiceStruct) ApproveReturn(returnId string, payload *request.ReviewReturnPayload) (*model.ReturnRequest, error) {
+	return r.transition(returnId, model.ReturnStatusRequested, bson.M{
+		"status":    model.ReturnStatusApproved,
+		"adminnote": payload.Note,
+	})
+}
+
+func (r *ReturnServiceStruct) RejectReturn(returnId string, payload *request.ReviewReturnPayload) (*model.ReturnRequest, error) {
+	return r.transition(returnId, model.ReturnStatusRequested, bson.M{
+		"status":    model.ReturnStatusRejected,
+		"adminnote": payload.Note,
+	})
+}
+
+// Admin marks the items of an approved return as received. This issues the
+// partial refund on the order and puts the selected products back in stock.
+func (r *ReturnServiceStruct) ReceiveReturn(returnId string, payload *request.ReceiveReturnPayload) (*model.ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	returnChan := make(chan *model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	restock := map[primitive.ObjectID]bool{}
+	for _, id := range payload.RestockProductIDs {
+		productObjID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid product id: %s", id), Code: 400}
+		}
+		restock[productObjID] = true
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(returnChan)
+
+		db := r.db.Database("go-ecomm")
+		now := time.Now()
+
+		// claim the return first so it can't be refunded twice
+		ret, err := r.transition(returnId, model.ReturnStatusApproved, bson.M{
+			"status":     model.ReturnStatusRefunded,
+			"receivedat": now,
+		})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		// until the refund is on the order, any failure hands the return back
+		// so the admin can retry
+		release := func() {
+			_, err := db.Collection("returns").UpdateOne(context.Background(),
+				bson.M{"_id": ret.ID, "status": model.ReturnStatusRefunded},
+				bson.M{"$set": bson.M{"status": model.ReturnStatusApproved, "receivedat": nil}},
+			)
+			if err != nil {
+				fmt.Println("failed to release return:", ret.ID.Hex(), err)
+			}
+		}
+
+		var order model.Order
+		err = db.Collection("orders").FindOne(ctx, bson.M{"_id": ret.OrderID}).Decode(&order)
+		if err != nil {
+			release()
+			errChan <- fmt.Errorf("failed to fetch order of return: %w", err)
+			return
+		}
+
+		unitPrices := map[primitive.ObjectID]model.Money{}
+		orderLines := map[primitive.ObjectID]model.ProductInfo{}
+		for _, line := range order.Products {
+			unitPrices[line.ProductID] = line.Price
+			orderLines[line.ProductID] = line
+		}
+
+		refundAmount := model.ZeroMoney(order.Amount.Currency)
+		for _, item := range ret.Items {
+			price := unitPrices[item.ProductID]
+			if price.IsZero() {
+				// orders placed before prices were snapshotted
+				var prod model.Product
+				if err := db.Collection("products").FindOne(ctx, bson.M{"_id": item.ProductID}).Decode(&prod); err == nil {
+					price = prod.Price
+				}
+			}
+			lineRefund, err := price.Mul(int64(item.Quantity))
+			// coupon discounts given on the line are not refunded, tax
+			// charged on top of the price is
+			if line, ok := orderLines[item.ProductID]; ok && err == nil {
+				var share model.Money
+				if share, err = line.DiscountFor(item.Quantity); err == nil {
+					lineRefund, err = lineRefund.Sub(share)
+				}
+				if err == nil && !order.TaxInclusive {
+					if share, err = line.TaxFor(item.Quantity); err == nil {
+						lineRefund, err = lineRefund.Add(share)
+					}
+				}
+			}
+			if err == nil {
+				refundAmount, err = refundAmount.Add(lineRefund)
+			}
+			if err != nil {
+				release()
+				errChan <- fmt.Errorf("failed to compute refund: %w", err)
+				return
+			}
+		}
+
+		// never refund more than what is left on the order
+		remaining, err := order.Amount.Sub(order.RefundedAmount)
+		if err == nil {
+			refundAmount, err = refundAmount.Min(remaining)
+		}
+		var refundedAmount model.Money
+		if err == nil {
+			refundedAmount, err = order.RefundedAmount.Add(refundAmount)
+		}
+		if err != nil {
+			release()
+			errChan <- fmt.Errorf("failed to compute refund: %w", err)
+			return
+		}
+
+		refund := model.Refund{
+			ID:        primitive.NewObjectID(),
+			ReturnID:  ret.ID,
+			Amount:    refundAmount,
+			Reason:    "return " + ret.ID.Hex(),
+			CreatedAt: now,
+		}
+
+		// guarded by updatedat so two refunds on the same order can't overwrite each other
+		res, err := db.Collection("orders").UpdateOne(ctx,
+			bson.M{"_id": order.ID, "updatedat": order.UpdatedAt},
+			bson.M{
+				"$push": bson.M{"refunds": refund},
+				"$set": bson.M{
+					"refundedamount": refundedAmount,
+					"updatedat":      now,
+				},
+			},
+		)
+		if err == nil && res.MatchedCount == 0 {
+			err = fmt.Errorf("order was modified concurrently")
+		}
+		if err != nil {
+			release()
+			errChan <- fmt.Errorf("failed to record refund: %w", err)
+			return
+		}
+
+		// the refund is recorded, from here on failures are logged and the
+		// return stays refunded
+		for i, item := range ret.Items {
+			if !restock[item.ProductID] {
+				continue
+			}
+			var before model.Product
+			err := db.Collection("products").FindOneAndUpdate(ctx,
+				bson.M{"_id": item.ProductID},
+				bson.M{"$set": bson.M{"instock": true}},
+			).Decode(&before)
+			if err == mongo.ErrNoDocuments {
+				// the product was deleted since, there is nothing to restock
+				continue
+			} else if err != nil {
+				fmt.Println("failed to restock product:", item.ProductID.Hex(), err)
+				continue
+			}
+			// products that count their stock get the returned units back
+			_, err = db.Collection("products").UpdateOne(ctx,
+				bson.M{"_id": item.ProductID, "stock": bson.M{"$type": "number"}},
+				bson.M{"$inc": bson.M{"stock": item.Quantity}},
+			)
+			if err != nil {
+				fmt.Println("failed to add returned units to the stock:", item.ProductID.Hex(), err)
+			}
+			after := before
+			after.InStock = true
+			go queueProductAlerts(r.db, &before, &after)
+			ret.Items[i].Restocked = true
+		}
+
+		// the refund is on the order, if the credit note fails an admin issues
+		// it later with IssueMissingDocuments
+		if _, err := r.invoices.IssueCreditNote(order.ID.Hex(), &refund); err != nil {
+			fmt.Println("failed to issue credit note:", err)
+		}
+
+		go notifyOrder(r.db, &order, "return_refunded", ret.ID.Hex(),
+			"Refund for order "+order.OrderNumber,
+			fmt.Sprintf("we received the items you returned from order %s and refunded %s.", order.OrderNumber, refundAmount.Format()))
+
+		ret.RefundAmount = refundAmount
+		_, err = db.Collection("returns").UpdateOne(ctx,
+			bson.M{"_id": ret.ID},
+			bson.M{"$set": bson.M{
+				"refundamount": refundAmount,
+				"items":        ret.Items,
+			}},
+		)
+		if err != nil {
+			fmt.Println("failed to save refunded return:", ret.ID.Hex(), err)
+		}
+
+		returnChan <- ret
+	}()
+
+	select {
+	case ret := <-returnChan:
+		return ret, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// moves a return from one status to the next, failing if it isn't in the expected status
+func (r *ReturnServiceStruct) transition(returnId, from string, set bson.M) (*model.ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	returnObjID, err := primitive.ObjectIDFromHex(returnId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid returnId"), Code: 400}
+	}
+
+	set["updatedat"] = time.Now()
+
+	var ret model.ReturnRequest
+	err = r.db.Database("go-ecomm").Collection("returns").FindOneAndUpdate(ctx,
+		bson.M{"_id": returnObjID, "status": from},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&ret)
+	if err == mongo.ErrNoDocuments {
+		return nil, model.ErrMsg{Err: fmt.Errorf("return not found or not in %s state", from), Code: 409}
+	} else if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (r *ReturnServiceStruct) findReturns(filter bson.M) (*[]model.ReturnRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	returnsChan := make(chan *[]model.ReturnRequest, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(returnsChan)
+
+		cur, err := r.db.Database("go-ecomm").Collection("returns").Find(ctx, filter,
+			options.Find().SetSort(bson.M{"createdat": -1}),
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		returns := []model.ReturnRequest{}
+		for cur.Next(ctx) {
+			var ret model.ReturnRequest
+			if err := cur.Decode(&ret); err != nil {
+				errChan <- err
+				return
+			}
+			returns = append(returns, ret)
+		}
+		returnsChan <- &returns
+	}()
+
+	select {
+	case returns := <-returnsChan:
+		return returns, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (r *ReturnServiceStruct) returnedQuantities(ctx context.Context, orderId primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	cur, err := r.db.Database("go-ecomm").Collection("returns").Find(ctx, bson.M{
+		"orderid": orderId,
+		"status":  bson.M{"$ne": model.ReturnStatusRejected},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	quantities := map[primitive.ObjectID]int{}
+	for cur.Next(ctx) {
+		var ret model.ReturnRequest
+		if err := cur.Decode(&ret); err != nil {
+			return nil, err
+		}
+		for _, item := range ret.Items {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	return quantities, nil
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Report a published review. Once enough customers reported it, it is taken
+// off the product and waits for a moderator.
+func (r *ReviewServiceStruct) ReportReview(payload *request.ReportReviewPayload, userId, reviewId string) (*model.ReviewReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reportChan := make(chan *model.ReviewReport, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reportChan)
+
+		db := r.db.Database("go-ecomm")
+		reviews := db.Collection("reviews")
+
+		var review model.Review
+		err := reviews.FindOne(ctx, bson.M{"_id": reviewObjID, "status": model.ReviewApproved}).Decode(&review)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if review.UserId == usrObjID {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you can't report your own review"), Code: 400}
+			return
+		}
+
+		report := &model.ReviewReport{
+			ID:        primitive.NewObjectID(),
+			ReviewID:  reviewObjID,
+			UserId:    usrObjID,
+			Reason:    payload.Reason,
+			Comment:   strings.TrimSpace(payload.Comment),
+			CreatedAt: time.Now(),
+		}
+		_, err = db.Collection("review_reports").InsertOne(ctx, report)
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you have already reported this review"), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		err = reviews.FindOneAndUpdate(ctx,
+			bson.M{"_id": reviewObjID},
+			bson.M{"$inc": bson.M{"reportcount": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&review)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		threshold := r.options.ReportThreshold
+		if threshold > 0 && review.ReportCount >= threshold && review.Published() {
+			// only the report that crossed the threshold takes it off
+			hidden, err := reviews.UpdateOne(ctx,
+				bson.M{"_id": reviewObjID, "status": model.ReviewApproved},
+				bson.M{
+					"$set":  bson.M{"status": model.ReviewPending},
+					"$push": bson.M{"holdreasons": fmt.Sprintf("reported by %d customers", review.ReportCount)},
+				},
+			)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if hidden.ModifiedCount > 0 {
+				if err := adjustRating(ctx, r.db, review.ProductID, review.Rating, 0); err != nil {
+					fmt.Println("failed to update product rating:", err)
+				}
+			}
+		}
+		reportChan <- report
+	}()
+
+	select {
+	case report := <-reportChan:
+		return report, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The reviews waiting for a moderator, oldest first, or the approved or
+// rejected ones to look at again
+func (r *ReviewServiceStruct) GetModerationQueue(query *request.ModerationQueueQuery) (*model.ModerationQueue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	queueChan := make(chan *model.ModerationQueue, 32)
+	errChan := make(chan error, 32)
+
+	status := query.Status
+	if status == "" {
+		status = model.ReviewPending
+	}
+	filter := bson.M{"status": status}
+	if query.MinReports > 0 {
+		filter["reportcount"] = bson.M{"$gte": query.MinReports}
+	}
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(queueChan)
+
+		collection := r.db.Database("go-ecomm").Collection("reviews")
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviews := []model.Review{}
+		if err := cur.All(ctx, &reviews); err != nil {
+			errChan <- err
+			return
+		}
+
+		queueChan <- &model.ModerationQueue{
+			Reviews: reviews,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		}
+	}()
+
+	select {
+	case queue := <-queueChan:
+		return queue, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Approve or reject a review. The decision clears the reports so far and is
+// kept in the moderation log.
+func (r *ReviewServiceStruct) ModerateReview(payload *request.ModeratePayload, moderatorId, reviewId string) (*model.Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	moderatorObjID, err := primitive.ObjectIDFromHex(moderatorId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reviewChan)
+
+		db := r.db.Database("go-ecomm")
+		collection := db.Collection("reviews")
+
+		var review model.Review
+		if err := collection.FindOne(ctx, bson.M{"_id": reviewObjID}).Decode(&review); err != nil {
+			errChan <- err
+			return
+		}
+		old := review
+		decision := newModerationDecision(&old, moderatorObjID, payload.Status, strings.TrimSpace(payload.Note))
+
+		now := time.Now()
+		review.Status = payload.Status
+		review.ReportCount = 0
+		review.ModeratedAt = &now
+		if review.Status == model.ReviewApproved {
+			review.HoldReasons = []string{}
+		}
+
+		// a report or an edit in the meantime has to be looked at first
+		res, err := collection.UpdateOne(ctx,
+			bson.M{
+				"_id":         review.ID,
+				"status":      old.Status,
+				"reportcount": old.ReportCount,
+				"updatedat":   old.UpdatedAt,
+			},
+			bson.M{"$set": bson.M{
+				"status":      review.Status,
+				"reportcount": review.ReportCount,
+				"holdreasons": review.HoldReasons,
+				"moderatedat": review.ModeratedAt,
+			}},
+		)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if res.MatchedCount == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("the review was changed at the same time, reload it"), Code: 409}
+			return
+		}
+
+		if err := moveRating(ctx, r.db, &old, &review); err != nil {
+			fmt.Println("failed to update product rating:", err)
+		}
+		if _, err := db.Collection("review_moderation_log").InsertOne(ctx, decision); err != nil {
+			fmt.Println("failed to log moderation decision:", err)
+		}
+		reviewChan <- &review
+	}()
+
+	select {
+	case review := <-reviewChan:
+		return review, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (r *ReviewServiceStruct) GetReviewReports(reviewId string) (*[]model.ReviewReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reportsChan := make(chan *[]model.ReviewReport, 32)
+	errChan := make(chan error, 32)
+
+	reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reportsChan)
+
+		cur, err := r.db.Database("go-ecomm").Collection("review_reports").Find(ctx,
+			bson.M{"reviewid": reviewObjID},
+			options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reports := []model.ReviewReport{}
+		if err := cur.All(ctx, &reports); err != nil {
+			errChan <- err
+			return
+		}
+		reportsChan <- &reports
+	}()
+
+	select {
+	case reports := <-reportsChan:
+		return reports, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The moderation decisions, newest first, of one review or of all when
+// reviewId is empty
+func (r *ReviewServiceStruct) GetModerationLog(reviewId string) (*[]model.ModerationDecision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	logChan := make(chan *[]model.ModerationDecision, 32)
+	errChan := make(chan error, 32)
+
+	filter := bson.M{}
+	if reviewId != "" {
+		reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+		}
+		filter["reviewid"] = reviewObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(logChan)
+
+		cur, err := r.db.Database("go-ecomm").Collection("review_moderation_log").Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(200))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		decisions := []model.ModerationDecision{}
+		if err := cur.All(ctx, &decisions); err != nil {
+			errChan <- err
+			return
+		}
+		logChan <- &decisions
+	}()
+
+	select {
+	case decisions := <-logChan:
+		return decisions, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// the log entry of a moderator changing a review from the state it is in
+func newModerationDecision(review *model.Review, moderatorId primitive.ObjectID, to, note string) *model.ModerationDecision {
+	reasons := review.HoldReasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return &model.ModerationDecision{
+		ID:          primitive.NewObjectID(),
+		ReviewID:    review.ID,
+		ModeratorID: moderatorId,
+		From:        review.Status,
+		To:          to,
+		Note:        note,
+		HoldReasons: reasons,
+		ReportCount: review.ReportCount,
+		CreatedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,531 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/config"
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/moderation"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReviewService interface {
+	CreateReview(payload *request.ReviewPayload, userId string) (*model.Review, error)
+	UpdateReview(payload *request.UpdateReviewPayload, userId, reviewId string) (*model.Review, error)
+	DeleteReview(userId, reviewId string, isAdmin bool) (*model.Review, error)
+	GetProductReviews(productId string, query *request.ReviewQuery) (*model.ReviewPage, error)
+	GetMyReviews(userId string) (*[]model.Review, error)
+	ReportReview(payload *request.ReportReviewPayload, userId, reviewId string) (*model.ReviewReport, error)
+	GetModerationQueue(query *request.ModerationQueueQuery) (*model.ModerationQueue, error)
+	ModerateReview(payload *request.ModeratePayload, moderatorId, reviewId string) (*model.Review, error)
+	GetReviewReports(reviewId string) (*[]model.ReviewReport, error)
+	GetModerationLog(reviewId string) (*[]model.ModerationDecision, error)
+}
+
+type ReviewOptions struct {
+	Policy *moderation.Policy
+	// reviews that pass the policy are published without a moderator
+	AutoApprove bool
+	// reports after which a published review is held again, 0 for never
+	ReportThreshold int
+}
+
+func NewReviewOptions(cfg *config.Config) ReviewOptions {
+	return ReviewOptions{
+		Policy:          moderation.NewPolicy(cfg.REVIEW_BANNED_WORDS, cfg.REVIEW_HOLD_LINKS),
+		AutoApprove:     cfg.REVIEW_AUTO_APPROVE,
+		ReportThreshold: cfg.REVIEW_REPORT_THRESHOLD,
+	}
+}
+
+type ReviewServiceStruct struct {
+	db      *mongo.Client
+	options ReviewOptions
+}
+
+func NewReviewService(db *mongo.Client, options ReviewOptions) *ReviewServiceStruct {
+	return &ReviewServiceStruct{
+		db:      db,
+		options: options,
+	}
+}
+
+// Review a product, a customer can review each product once. The review is
+// published right away unless it has to wait for a moderator.
+func (r *ReviewServiceStruct) CreateReview(payload *request.ReviewPayload, userId string) (*model.Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(payload.ProductID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reviewChan)
+
+		db := r.db.Database("go-ecomm")
+
+		count, err := db.Collection("products").CountDocuments(ctx, bson.M{"_id": productObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if count == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		}
+
+		var user model.User
+		err = db.Collection("users").FindOne(ctx, bson.M{"_id": usrObjID},
+			options.FindOne().SetProjection(bson.M{"firstname": 1})).Decode(&user)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		verified, err := verifiedPurchase(ctx, r.db, usrObjID, productObjID)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		images := payload.Images
+		if images == nil {
+			images = []string{}
+		}
+		review := &model.Review{
+			ID:               primitive.NewObjectID(),
+			ProductID:        productObjID,
+			UserId:           usrObjID,
+			Author:           user.FirstName,
+			Rating:           payload.Rating,
+			Title:            strings.TrimSpace(payload.Title),
+			Body:             strings.TrimSpace(payload.Body),
+			Images:           images,
+			VerifiedPurchase: verified,
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
+		}
+		r.screen(review)
+
+		// the unique index stops a second review posted at the same time
+		_, err = db.Collection("reviews").InsertOne(ctx, review)
+		if mongo.IsDuplicateKeyError(err) {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("you have already reviewed this product"), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		if review.Published() {
+			if err := adjustRating(ctx, r.db, productObjID, 0, review.Rating); err != nil {
+				fmt.Println("failed to update product rating:", err)
+			}
+		}
+		reviewChan <- review
+	}()
+
+	select {
+	case review := <-reviewChan:
+		return review, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Edit an own review. The verified purchase badge is checked again, the
+// product may have been delivered since, and the edit is screened like a new
+// review.
+func (r *ReviewServiceStruct) UpdateReview(payload *request.UpdateReviewPayload, userId, reviewId string) (*model.Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reviewChan)
+
+		collection := r.db.Database("go-ecomm").Collection("reviews")
+
+		var review model.Review
+		if err := collection.FindOne(ctx, bson.M{"_id": reviewObjID, "userid": usrObjID}).Decode(&review); err != nil {
+			errChan <- err
+			return
+		}
+		old := review
+
+		if payload.Rating != nil {
+			review.Rating = *payload.Rating
+		}
+		if payload.Title != nil {
+			review.Title = strings.TrimSpace(*payload.Title)
+		}
+		if payload.Body != nil {
+			review.Body = strings.TrimSpace(*payload.Body)
+		}
+		if payload.Images != nil {
+			review.Images = *payload.Images
+		}
+		verified, err := verifiedPurchase(ctx, r.db, usrObjID, review.ProductID)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		review.VerifiedPurchase = verified
+		review.UpdatedAt = time.Now()
+		r.screen(&review)
+
+		// the rating only moves when no other edit, report or moderator got
+		// in first
+		res, err := collection.ReplaceOne(ctx, bson.M{
+			"_id":         review.ID,
+			"rating":      old.Rating,
+			"status":      old.Status,
+			"reportcount": old.ReportCount,
+		}, review)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if res.MatchedCount == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("the review was changed at the same time, try again"), Code: 409}
+			return
+		}
+		if err := moveRating(ctx, r.db, &old, &review); err != nil {
+			fmt.Println("failed to update product rating:", err)
+		}
+		reviewChan <- &review
+	}()
+
+	select {
+	case review := <-reviewChan:
+		return review, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Delete an own review, admins can delete any. Deleting someone else's review
+// is kept in the moderation log.
+func (r *ReviewServiceStruct) DeleteReview(userId, reviewId string, isAdmin bool) (*model.Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reviewChan := make(chan *model.Review, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	reviewObjID, err := primitive.ObjectIDFromHex(reviewId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid reviewId"), Code: 400}
+	}
+	filter := bson.M{"_id": reviewObjID}
+	if !isAdmin {
+		filter["userid"] = usrObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reviewChan)
+
+		db := r.db.Database("go-ecomm")
+
+		var review model.Review
+		err := db.Collection("reviews").FindOneAndDelete(ctx, filter).Decode(&review)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if review.Published() {
+			if err := adjustRating(ctx, r.db, review.ProductID, review.Rating, 0); err != nil {
+				fmt.Println("failed to update product rating:", err)
+			}
+		}
+		if review.UserId != usrObjID {
+			decision := newModerationDecision(&review, usrObjID, model.ReviewDeleted, "")
+			if _, err := db.Collection("review_moderation_log").InsertOne(ctx, decision); err != nil {
+				fmt.Println("failed to log review deletion:", err)
+			}
+		}
+		reviewChan <- &review
+	}()
+
+	select {
+	case review := <-reviewChan:
+		return review, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// The published reviews of a product, a page at a time, with its rating
+// summary
+func (r *ReviewServiceStruct) GetProductReviews(productId string, query *request.ReviewQuery) (*model.ReviewPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	pageChan := make(chan *model.ReviewPage, 32)
+	errChan := make(chan error, 32)
+
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+
+	filter := bson.M{"productid": productObjID, "status": model.ReviewApproved}
+	if query.Rating != 0 {
+		filter["rating"] = query.Rating
+	}
+	if query.Verified {
+		filter["verifiedpurchase"] = true
+	}
+	page, limit := query.Page, query.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(pageChan)
+
+		db := r.db.Database("go-ecomm")
+
+		var prod model.Product
+		err := db.Collection("products").FindOne(ctx, bson.M{"_id": productObjID},
+			options.FindOne().SetProjection(bson.M{"rating": 1})).Decode(&prod)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rating := prod.Rating
+		if rating == nil {
+			rating = &model.RatingSummary{Histogram: map[string]int64{}}
+		}
+
+		collection := db.Collection("reviews")
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		cur, err := collection.Find(ctx, filter, options.Find().
+			SetSort(reviewSort(query.Sort)).
+			SetSkip(int64((page-1)*limit)).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"holdreasons": 0, "reportcount": 0}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviews := []model.Review{}
+		if err := cur.All(ctx, &reviews); err != nil {
+			errChan <- err
+			return
+		}
+
+		pageChan <- &model.ReviewPage{
+			Reviews: reviews,
+			Rating:  rating,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		}
+	}()
+
+	select {
+	case page := <-pageChan:
+		return page, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (r *ReviewServiceStruct) GetMyReviews(userId string) (*[]model.Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	reviewsChan := make(chan *[]model.Review, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(reviewsChan)
+
+		cur, err := r.db.Database("go-ecomm").Collection("reviews").Find(ctx,
+			bson.M{"userid": usrObjID},
+			options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		reviews := []model.Review{}
+		if err := cur.All(ctx, &reviews); err != nil {
+			errChan <- err
+			return
+		}
+		reviewsChan <- &reviews
+	}()
+
+	select {
+	case reviews := <-reviewsChan:
+		return reviews, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func reviewSort(sort string) bson.D {
+	switch sort {
+	case "oldest":
+		return bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}
+	case "highest":
+		return bson.D{{Key: "rating", Value: -1}, {Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}
+	case "lowest":
+		return bson.D{{Key: "rating", Value: 1}, {Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	return bson.D{{Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}
+}
+
+// Whether the user has received the product in one of their orders
+func verifiedPurchase(ctx context.Context, db *mongo.Client, userId, productId primitive.ObjectID) (bool, error) {
+	count, err := db.Database("go-ecomm").Collection("orders").CountDocuments(ctx, bson.M{
+		"userid": userId,
+		"$or": bson.A{
+			bson.M{"status": model.OrderStatusDelivered, "products.product_id": productId},
+			bson.M{"products": bson.M{"$elemMatch": bson.M{
+				"product_id":        productId,
+				"deliveredquantity": bson.M{"$gt": 0},
+			}}},
+		},
+	}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// screen sets the status of a review its author wrote or edited. A review
+// waiting for a moderator, or rejected by one, goes back to the queue and
+// keeps the reasons it was held for.
+func (r *ReviewServiceStruct) screen(review *model.Review) {
+	reasons := r.options.Policy.Check(review.Title, review.Body)
+	held := review.Status == model.ReviewPending || review.Status == model.ReviewRejected
+	if review.Status == model.ReviewPending {
+		for _, reason := range review.HoldReasons {
+			if !contains(reasons, reason) {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	review.HoldReasons = reasons
+	if held || len(reasons) > 0 || !r.options.AutoApprove {
+		review.Status = model.ReviewPending
+	} else {
+		review.Status = model.ReviewApproved
+	}
+}
+
+// moves the rating summary of a product from one version of a review to the
+// next, only published reviews count
+func moveRating(ctx context.Context, db *mongo.Client, before, after *model.Review) error {
+	remove, add := 0, 0
+	if before.Published() {
+		remove = before.Rating
+	}
+	if after.Published() {
+		add = after.Rating
+	}
+	if remove == add {
+		return nil
+	}
+	return adjustRating(ctx, db, after.ProductID, remove, add)
+}
+
+// Moves the rating summary of a product by one review: remove takes a rating
+// out, add puts one in, 0 for neither. The average is worked out in the same
+// update so it can't drift from the counts.
+func adjustRating(ctx context.Context, db *mongo.Client, productId primitive.ObjectID, remove, add int) error {
+	current := func(field string) bson.M {
+		return bson.M{"$ifNull": bson.A{"$rating." + field, 0}}
+	}
+	countDelta := 0
+	if add > 0 {
+		countDelta++
+	}
+	if remove > 0 {
+		countDelta--
+	}
+	set := bson.M{
+		"rating.count": bson.M{"$add": bson.A{current("count"), countDelta}},
+		"rating.sum":   bson.M{"$add": bson.A{current("sum"), add - remove}},
+	}
+	if remove != add {
+		if remove > 0 {
+			key := "histogram." + strconv.Itoa(remove)
+			set["rating."+key] = bson.M{"$add": bson.A{current(key), -1}}
+		}
+		if add > 0 {
+			key := "histogram." + strconv.Itoa(add)
+			set["rating."+key] = bson.M{"$add": bson.A{current(key), 1}}
+		}
+	}
+
+	_, err := db.Database("go-ecomm").Collection("products").UpdateOne(ctx,
+		bson.M{"_id": productId},
+		bson.A{
+			bson.M{"$set": set},
+			bson.M{"$set": bson.M{"rating.average": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$rating.count", 0}},
+				bson.M{"$round": bson.A{bson.M{"$divide": bson.A{"$rating.sum", "$rating.count"}}, 2}},
+				0,
+			}}}},
+		},
+	)
+	return err
+}
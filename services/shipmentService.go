@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ShipmentService interface {
+	CreateShipment(payload *request.CreateShipmentPayload) (*model.Shipment, error)
+	MarkDelivered(shipmentId string, payload *request.DeliverShipmentPayload) (*model.Shipment, error)
+	GetOrderShipments(orderId, userId string, isAdmin bool) (*[]model.Shipment, error)
+}
+
+type ShipmentServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewShipmentService(db *mongo.Client) *ShipmentServiceStruct {
+	return &ShipmentServiceStruct{
+		db: db,
+	}
+}
+
+// Create a shipment covering some or all of the lines of an order
+func (s *ShipmentServiceStruct) CreateShipment(payload *request.CreateShipmentPayload) (*model.Shipment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	shipmentChan := make(chan *model.Shipment, 32)
+	errChan := make(chan error, 32)
+
+	orderObjID, err := primitive.ObjectIDFromHex(payload.OrderID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid order_id"), Code: 400}
+	}
+
+	lines := make([]model.ShipmentLine, 0, len(payload.Lines))
+	for _, line := range payload.Lines {
+		productObjID, err := primitive.ObjectIDFromHex(line.ProductID)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id: %s", line.ProductID), Code: 400}
+		}
+		lines = append(lines, model.ShipmentLine{ProductID: productObjID, Quantity: line.Quantity})
+	}
+
+	shippedAt := time.Now()
+	if payload.ShippedAt != nil {
+		shippedAt = *payload.ShippedAt
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(shipmentChan)
+
+		db := s.db.Database("go-ecomm")
+
+		var order model.Order
+		err := db.Collection("orders").FindOne(ctx, bson.M{"_id": orderObjID}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		for _, line := range lines {
+			if err := allocate(order.Products, line, func(p *model.ProductInfo) *int { return &p.ShippedQuantity }, func(p *model.ProductInfo) int { return p.Quantity }); err != nil {
+				errChan <- err
+				return
+			}
+		}
+
+		shipment := model.NewShipment(orderObjID, lines, payload.Carrier, payload.TrackingNumber, shippedAt)
+		shipment.OrderNumber = order.OrderNumber
+		if err := s.saveOrderFulfilment(ctx, &order); err != nil {
+			errChan <- err
+			return
+		}
+
+		_, err = db.Collection("shipments").InsertOne(ctx, shipment)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		go notifyOrder(s.db, &order, "order_shipped", shipment.ID.Hex(),
+			"Order "+order.OrderNumber+" is on its way",
+			fmt.Sprintf("your order %s was shipped with %s, tracking number %s.", order.OrderNumber, shipment.Carrier, shipment.TrackingNumber))
+
+		shipmentChan <- shipment
+	}()
+
+	select {
+	case shipment := <-shipmentChan:
+		return shipment, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Mark every line of a shipment as delivered
+func (s *ShipmentServiceStruct) MarkDelivered(shipmentId string, payload *request.DeliverShipmentPayload) (*model.Shipment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	shipmentChan := make(chan *model.Shipment, 32)
+	errChan := make(chan error, 32)
+
+	shipmentObjID, err := primitive.ObjectIDFromHex(shipmentId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid shipmentId"), Code: 400}
+	}
+
+	deliveredAt := time.Now()
+	if payload.DeliveredAt != nil {
+		deliveredAt = *payload.DeliveredAt
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(shipmentChan)
+
+		db := s.db.Database("go-ecomm")
+
+		var shipment model.Shipment
+		err := db.Collection("shipments").FindOneAndUpdate(ctx,
+			bson.M{"_id": shipmentObjID, "status": model.ShipmentStatusShipped},
+			bson.M{"$set": bson.M{
+				"status":      model.ShipmentStatusDelivered,
+				"deliveredat": deliveredAt,
+				"updatedat":   time.Now(),
+			}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&shipment)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("shipment not found or already delivered"), Code: 409}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+
+		// until the order counts the delivery, any failure puts the shipment
+		// back so the delivery can be retried
+		release := func() {
+			_, err := db.Collection("shipments").UpdateOne(context.Background(),
+				bson.M{"_id": shipment.ID, "status": model.ShipmentStatusDelivered},
+				bson.M{"$set": bson.M{
+					"status":      model.ShipmentStatusShipped,
+					"deliveredat": nil,
+					"updatedat":   time.Now(),
+				}},
+			)
+			if err != nil {
+				fmt.Println("failed to release shipment:", shipment.ID.Hex(), err)
+			}
+		}
+
+		var order model.Order
+		err = db.Collection("orders").FindOne(ctx, bson.M{"_id": shipment.OrderID}).Decode(&order)
+		if err != nil {
+			release()
+			errChan <- fmt.Errorf("failed to fetch order of shipment: %w", err)
+			return
+		}
+
+		for _, line := range shipment.Lines {
+			if err := allocate(order.Products, line, func(p *model.ProductInfo) *int { return &p.DeliveredQuantity }, func(p *model.ProductInfo) int { return p.ShippedQuantity }); err != nil {
+				release()
+				errChan <- err
+				return
+			}
+		}
+
+		if err := s.saveOrderFulfilment(ctx, &order); err != nil {
+			release()
+			errChan <- err
+			return
+		}
+		go notifyOrder(s.db, &order, "order_delivered", shipment.ID.Hex(),
+			"Order "+order.OrderNumber+" was delivered",
+			fmt.Sprintf("a shipment of your order %s was delivered.", order.OrderNumber))
+
+		shipmentChan <- &shipment
+	}()
+
+	select {
+	case shipment := <-shipmentChan:
+		return shipment, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// shipments of an order, visible to its owner and admins
+func (s *ShipmentServiceStruct) GetOrderShipments(orderId, userId string, isAdmin bool) (*[]model.Shipment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	orderObjID, err := primitive.ObjectIDFromHex(orderId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid orderId"), Code: 400}
+	}
+
+	filter := bson.M{"_id": orderObjID}
+	if !isAdmin {
+		userObjID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+		}
+		filter["userid"] = userObjID
+	}
+
+	count, err := s.db.Database("go-ecomm").Collection("orders").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, model.ErrMsg{Err: fmt.Errorf("order not found"), Code: 404}
+	}
+
+	cur, err := s.db.Database("go-ecomm").Collection("shipments").Find(ctx,
+		bson.M{"orderid": orderObjID},
+		options.Find().SetSort(bson.M{"shippedat": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	shipments := []model.Shipment{}
+	if err := cur.All(ctx, &shipments); err != nil {
+		return nil, err
+	}
+	return &shipments, nil
+}
+
+// writes the recomputed line quantities and status back, guarding against concurrent updates
+func (s *ShipmentServiceStruct) saveOrderFulfilment(ctx context.Context, order *model.Order) error {
+	previousUpdate := order.UpdatedAt
+	order.UpdateFulfilment()
+	order.UpdatedAt = time.Now()
+
+	res, err := s.db.Database("go-ecomm").Collection("orders").UpdateOne(ctx,
+		bson.M{"_id": order.ID, "updatedat": previousUpdate},
+		bson.M{"$set": bson.M{
+			"products":  order.Products,
+			"status":    order.Status,
+			"updatedat": order.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return model.ErrMsg{Err: fmt.Errorf("order was modified concurrently, retry"), Code: 409}
+	}
+	return nil
+}
+
+// spreads a shipment line over the order lines of the same product. counter
+// points at the quantity being increased, limit is how far it may go.
+func allocate(lines []model.ProductInfo, line model.ShipmentLine, counter func(*model.ProductInfo) *int, limit func(*model.ProductInfo) int) error {
+	remaining := line.Quantity
+	for i := range lines {
+		if lines[i].ProductID != line.ProductID || remaining == 0 {
+			continue
+		}
+		c := counter(&lines[i])
+		free := limit(&lines[i]) - *c
+		if free <= 0 {
+			continue
+		}
+		take := min(free, remaining)
+		*c += take
+		remaining -= take
+	}
+	if remaining > 0 {
+		return model.ErrMsg{Err: fmt.Errorf("quantity of product %s exceeds what is left to fulfil", line.ProductID.Hex()), Code: 400}
+	}
+	return nil
+}
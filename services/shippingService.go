@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ShippingService interface {
+	CreateZone(payload *request.ShippingZonePayload) (*model.ShippingZone, error)
+	GetZones() (*[]model.ShippingZone, error)
+	UpdateZone(payload *request.UpdateShippingZonePayload, zoneId string) (*model.ShippingZone, error)
+	DeleteZone(zoneId string) (*model.ShippingZone, error)
+	CreateMethod(payload *request.ShippingMethodPayload) (*model.ShippingMethod, error)
+	GetMethods(zoneId string) (*[]model.ShippingMethod, error)
+	UpdateMethod(payload *request.UpdateShippingMethodPayload, methodId string) (*model.ShippingMethod, error)
+	DeleteMethod(methodId string) (*model.ShippingMethod, error)
+}
+
+type ShippingServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewShippingService(db *mongo.Client) *ShippingServiceStruct {
+	return &ShippingServiceStruct{
+		db: db,
+	}
+}
+
+// Create a zone of countries or postal code prefixes
+func (s *ShippingServiceStruct) CreateZone(payload *request.ShippingZonePayload) (*model.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	zone := model.NewShippingZone(payload.Name, payload.Countries, payload.PostalPrefixes)
+	if err := zone.Validate(); err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(zoneChan)
+
+		_, err := s.db.Database("go-ecomm").Collection("shipping_zones").InsertOne(ctx, zone)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zoneChan <- zone
+	}()
+
+	for {
+		select {
+		case zone := <-zoneChan:
+			return zone, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func (s *ShippingServiceStruct) GetZones() (*[]model.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	zonesChan := make(chan *[]model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(zonesChan)
+
+		cur, err := s.db.Database("go-ecomm").Collection("shipping_zones").Find(ctx, bson.M{},
+			options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		zones := []model.ShippingZone{}
+		if err := cur.All(ctx, &zones); err != nil {
+			errChan <- err
+			return
+		}
+		zonesChan <- &zones
+	}()
+
+	for {
+		select {
+		case zones := <-zonesChan:
+			return zones, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func (s *ShippingServiceStruct) UpdateZone(payload *request.UpdateShippingZonePayload, zoneId string) (*model.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	zoneObjID, err := primitive.ObjectIDFromHex(zoneId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid zoneId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(zoneChan)
+
+		collection := s.db.Database("go-ecomm").Collection("shipping_zones")
+
+		var zone model.ShippingZone
+		if err := collection.FindOne(ctx, bson.M{"_id": zoneObjID}).Decode(&zone); err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.Name != nil {
+			zone.Name = *payload.Name
+		}
+		if payload.Countries != nil {
+			zone.Countries = *payload.Countries
+		}
+		if payload.PostalPrefixes != nil {
+			zone.PostalPrefixes = *payload.PostalPrefixes
+		}
+		zone.Normalize()
+		if err := zone.Validate(); err != nil {
+			errChan <- model.ErrMsg{Err: err, Code: 400}
+			return
+		}
+		zone.UpdatedAt = time.Now()
+
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": zoneObjID}, zone)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		zoneChan <- &zone
+	}()
+
+	for {
+		select {
+		case zone := <-zoneChan:
+			return zone, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Delete a zone together with its methods, orders keep the method they used
+func (s *ShippingServiceStruct) DeleteZone(zoneId string) (*model.ShippingZone, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	zoneChan := make(chan *model.ShippingZone, 32)
+	errChan := make(chan error, 32)
+
+	zoneObjID, err := primitive.ObjectIDFromHex(zoneId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid zoneId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(zoneChan)
+
+		db := s.db.Database("go-ecomm")
+
+		var zone model.ShippingZone
+		err := db.Collection("shipping_zones").FindOneAndDelete(ctx, bson.M{"_id": zoneObjID}).Decode(&zone)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if _, err := db.Collection("shipping_methods").DeleteMany(ctx, bson.M{"zoneid": zoneObjID}); err != nil {
+			errChan <- fmt.Errorf("failed to delete the zone's methods: %w", err)
+			return
+		}
+		zoneChan <- &zone
+	}()
+
+	for {
+		select {
+		case zone := <-zoneChan:
+			return zone, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Create a delivery method for a zone
+func (s *ShippingServiceStruct) CreateMethod(payload *request.ShippingMethodPayload) (*model.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	zoneObjID, err := primitive.ObjectIDFromHex(payload.ZoneID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid zone_id"), Code: 400}
+	}
+
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+	method := model.NewShippingMethod(&model.ShippingMethod{
+		ZoneID:          zoneObjID,
+		Name:            payload.Name,
+		Description:     payload.Description,
+		Type:            payload.Type,
+		Currency:        payload.Currency,
+		FlatRate:        payload.FlatRate,
+		WeightRates:     weightRates(payload.WeightRates),
+		PriceThresholds: thresholdRates(payload.PriceThresholds),
+		MinDays:         payload.MinDays,
+		MaxDays:         payload.MaxDays,
+		Active:          active,
+	})
+	if err := method.Validate(); err != nil {
+		return nil, model.ErrMsg{Err: err, Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(methodChan)
+
+		db := s.db.Database("go-ecomm")
+
+		count, err := db.Collection("shipping_zones").CountDocuments(ctx, bson.M{"_id": zoneObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if count == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("shipping zone not found"), Code: 404}
+			return
+		}
+
+		if _, err := db.Collection("shipping_methods").InsertOne(ctx, method); err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- method
+	}()
+
+	for {
+		select {
+		case method := <-methodChan:
+			return method, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// All methods, or only those of a zone
+func (s *ShippingServiceStruct) GetMethods(zoneId string) (*[]model.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	methodsChan := make(chan *[]model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	filter := bson.M{}
+	if zoneId != "" {
+		zoneObjID, err := primitive.ObjectIDFromHex(zoneId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid zoneId"), Code: 400}
+		}
+		filter["zoneid"] = zoneObjID
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(methodsChan)
+
+		cur, err := s.db.Database("go-ecomm").Collection("shipping_methods").Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "zoneid", Value: 1}, {Key: "name", Value: 1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		methods := []model.ShippingMethod{}
+		if err := cur.All(ctx, &methods); err != nil {
+			errChan <- err
+			return
+		}
+		methodsChan <- &methods
+	}()
+
+	for {
+		select {
+		case methods := <-methodsChan:
+			return methods, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Change the rates, estimate or availability of a method
+func (s *ShippingServiceStruct) UpdateMethod(payload *request.UpdateShippingMethodPayload, methodId string) (*model.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	methodObjID, err := primitive.ObjectIDFromHex(methodId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid methodId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(methodChan)
+
+		collection := s.db.Database("go-ecomm").Collection("shipping_methods")
+
+		var method model.ShippingMethod
+		if err := collection.FindOne(ctx, bson.M{"_id": methodObjID}).Decode(&method); err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.Name != nil {
+			method.Name = *payload.Name
+		}
+		if payload.Description != nil {
+			method.Description = *payload.Description
+		}
+		if payload.FlatRate != nil {
+			method.FlatRate = payload.FlatRate
+		}
+		if payload.WeightRates != nil {
+			method.WeightRates = weightRates(*payload.WeightRates)
+		}
+		if payload.PriceThresholds != nil {
+			method.PriceThresholds = thresholdRates(*payload.PriceThresholds)
+		}
+		if payload.MinDays != nil {
+			method.MinDays = *payload.MinDays
+		}
+		if payload.MaxDays != nil {
+			method.MaxDays = *payload.MaxDays
+		}
+		if payload.Active != nil {
+			method.Active = *payload.Active
+		}
+		method.SortRates()
+		if err := method.Validate(); err != nil {
+			errChan <- model.ErrMsg{Err: err, Code: 400}
+			return
+		}
+		method.UpdatedAt = time.Now()
+
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": methodObjID}, method)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- &method
+	}()
+
+	for {
+		select {
+		case method := <-methodChan:
+			return method, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func (s *ShippingServiceStruct) DeleteMethod(methodId string) (*model.ShippingMethod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	methodChan := make(chan *model.ShippingMethod, 32)
+	errChan := make(chan error, 32)
+
+	methodObjID, err := primitive.ObjectIDFromHex(methodId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid methodId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(methodChan)
+
+		var method model.ShippingMethod
+		err := s.db.Database("go-ecomm").Collection("shipping_methods").FindOneAndDelete(ctx, bson.M{"_id": methodObjID}).Decode(&method)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		methodChan <- &method
+	}()
+
+	for {
+		select {
+		case method := <-methodChan:
+			return method, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+func weightRates(payload []request.WeightRatePayload) []model.WeightRate {
+	rates := make([]model.WeightRate, 0, len(payload))
+	for _, rate := range payload {
+		rates = append(rates, model.WeightRate{UpToGrams: rate.UpToGrams, Price: rate.Price})
+	}
+	return rates
+}
+
+func thresholdRates(payload []request.ThresholdRatePayload) []model.ThresholdRate {
+	rates := make([]model.ThresholdRate, 0, len(payload))
+	for _, rate := range payload {
+		rates = append(rates, model.ThresholdRate{MinSubtotal: rate.MinSubtotal, Price: rate.Price})
+	}
+	return rates
+}
+
+// value of the goods after discounts that the price thresholds look at, and
+// whether a coupon made shipping free
+func shippableValue(pricing *model.PromotionResult) (model.Money, bool, error) {
+	freeShipping := false
+	for _, discount := range pricing.Coupons {
+		freeShipping = freeShipping || discount.FreeShipping
+	}
+	goods, err := pricing.Subtotal.Sub(pricing.Discount)
+	return goods, freeShipping, err
+}
+
+// Prices the active methods of the zones the address is in, cheapest first.
+// Zones narrowed to postal prefixes win over whole countries. The subtotal is
+// the value of the goods after discounts, in the cart's currency.
+func shippingOptions(ctx context.Context, db *mongo.Client, address *model.Address, lines []pricedLine, subtotal model.Money, freeShipping bool) ([]model.ShippingOption, error) {
+	cur, err := db.Database("go-ecomm").Collection("shipping_zones").Find(ctx, bson.M{"countries": address.Country})
+	if err != nil {
+		return nil, err
+	}
+	zones := []model.ShippingZone{}
+	if err := cur.All(ctx, &zones); err != nil {
+		return nil, err
+	}
+
+	var broad, narrow []primitive.ObjectID
+	for _, zone := range zones {
+		if !zone.Matches(address) {
+			continue
+		}
+		if len(zone.PostalPrefixes) > 0 {
+			narrow = append(narrow, zone.ID)
+		} else {
+			broad = append(broad, zone.ID)
+		}
+	}
+	zoneIDs := broad
+	if len(narrow) > 0 {
+		zoneIDs = narrow
+	}
+	available := []model.ShippingOption{}
+	if len(zoneIDs) == 0 {
+		return available, nil
+	}
+
+	cur, err = db.Database("go-ecomm").Collection("shipping_methods").Find(ctx, bson.M{
+		"zoneid": bson.M{"$in": zoneIDs},
+		"active": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	methods := []model.ShippingMethod{}
+	if err := cur.All(ctx, &methods); err != nil {
+		return nil, err
+	}
+
+	weight := 0
+	for _, line := range lines {
+		weight += line.WeightGrams * line.Quantity
+	}
+
+	for _, method := range methods {
+		methodSubtotal, err := convertMoney(ctx, db, subtotal, method.Currency)
+		if err != nil {
+			return nil, err
+		}
+		cost, ok := method.Cost(weight, methodSubtotal)
+		if !ok {
+			continue
+		}
+		if cost, err = convertMoney(ctx, db, cost, subtotal.Currency); err != nil {
+			return nil, err
+		}
+		if freeShipping {
+			cost = model.ZeroMoney(subtotal.Currency)
+		}
+		available = append(available, model.ShippingOption{
+			MethodID:    method.ID,
+			ZoneID:      method.ZoneID,
+			Name:        method.Name,
+			Description: method.Description,
+			Cost:        cost,
+			MinDays:     method.MinDays,
+			MaxDays:     method.MaxDays,
+		})
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].Cost.Amount < available[j].Cost.Amount
+	})
+	return available, nil
+}
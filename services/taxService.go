@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/tax"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TaxService interface {
+	SetRate(payload *request.TaxRatePayload) (*model.TaxRate, error)
+	GetRates(country string) (*[]model.TaxRate, error)
+	DeleteRate(rateId string) (*model.TaxRate, error)
+	FindRates(ctx context.Context, country, region string) ([]model.TaxRate, error)
+}
+
+type TaxServiceStruct struct {
+	db *mongo.Client
+}
+
+func NewTaxService(db *mongo.Client) *TaxServiceStruct {
+	return &TaxServiceStruct{
+		db: db,
+	}
+}
+
+// Create or replace the rate of a country or region for a tax category
+func (t *TaxServiceStruct) SetRate(payload *request.TaxRatePayload) (*model.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	rateChan := make(chan *model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	rate := model.NewTaxRate(payload.Country, payload.Region, payload.Category, payload.Name, payload.RateBasisPoints)
+
+	go func() {
+		defer close(errChan)
+		defer close(rateChan)
+
+		// one rate per country, region and category, the id of a replaced rate is kept
+		var saved model.TaxRate
+		err := t.db.Database("go-ecomm").Collection("tax_rates").FindOneAndUpdate(ctx,
+			bson.M{"country": rate.Country, "region": rate.Region, "category": rate.Category},
+			bson.M{
+				"$set": bson.M{
+					"name":            rate.Name,
+					"ratebasispoints": rate.RateBasisPoints,
+					"updatedat":       rate.UpdatedAt,
+				},
+				"$setOnInsert": bson.M{
+					"_id":       rate.ID,
+					"createdat": rate.CreatedAt,
+				},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&saved)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- &saved
+	}()
+
+	for {
+		select {
+		case rate := <-rateChan:
+			return rate, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Rates of the rate table, optionally of one country
+func (t *TaxServiceStruct) GetRates(country string) (*[]model.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	ratesChan := make(chan *[]model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	filter := bson.M{}
+	if country != "" {
+		filter["country"] = strings.ToUpper(country)
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(ratesChan)
+
+		cur, err := t.db.Database("go-ecomm").Collection("tax_rates").Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "country", Value: 1}, {Key: "region", Value: 1}, {Key: "category", Value: 1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer cur.Close(ctx)
+
+		rates := []model.TaxRate{}
+		if err := cur.All(ctx, &rates); err != nil {
+			errChan <- err
+			return
+		}
+		ratesChan <- &rates
+	}()
+
+	for {
+		select {
+		case rates := <-ratesChan:
+			return rates, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// Remove a rate, orders keep the tax they were charged
+func (t *TaxServiceStruct) DeleteRate(rateId string) (*model.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	rateChan := make(chan *model.TaxRate, 32)
+	errChan := make(chan error, 32)
+
+	rateObjID, err := primitive.ObjectIDFromHex(rateId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid rateId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(rateChan)
+
+		var rate model.TaxRate
+		err := t.db.Database("go-ecomm").Collection("tax_rates").FindOneAndDelete(ctx, bson.M{"_id": rateObjID}).Decode(&rate)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		rateChan <- &rate
+	}()
+
+	for {
+		select {
+		case rate := <-rateChan:
+			return rate, nil
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// FindRates returns the country wide rates and the rates of the region, it
+// is the rate lookup of the table calculator
+func (t *TaxServiceStruct) FindRates(ctx context.Context, country, region string) ([]model.TaxRate, error) {
+	regions := bson.A{""}
+	if region = strings.ToUpper(strings.TrimSpace(region)); region != "" {
+		regions = append(regions, region)
+	}
+
+	cur, err := t.db.Database("go-ecomm").Collection("tax_rates").Find(ctx, bson.M{
+		"country": strings.ToUpper(strings.TrimSpace(country)),
+		"region":  bson.M{"$in": regions},
+	}, options.Find().SetSort(bson.M{"region": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	rates := []model.TaxRate{}
+	if err := cur.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// Taxes the lines after their discounts for the address and adds the result
+// to the pricing. Without an address, e.g. a legacy free text one, nothing
+// is charged.
+func applyTax(ctx context.Context, taxes tax.Calculator, pricing *model.PromotionResult, lines []pricedLine, lineDiscounts []model.Money, address *model.Address) error {
+	req := &tax.Request{
+		Currency: pricing.Currency,
+		Lines:    make([]tax.Line, 0, len(lines)),
+	}
+	if address != nil {
+		req.Country, req.Region = address.Country, address.Region
+	}
+	for i, line := range lines {
+		amount, err := line.Total.Sub(lineDiscounts[i])
+		if err != nil {
+			return err
+		}
+		req.Lines = append(req.Lines, tax.Line{
+			ProductID:   line.ProductID,
+			TaxCategory: line.TaxCategory,
+			Quantity:    line.Quantity,
+			Amount:      amount,
+		})
+	}
+
+	result, err := taxes.Calculate(ctx, req)
+	if err != nil {
+		return err
+	}
+	if len(result.Lines) != len(lines) {
+		return fmt.Errorf("tax calculator returned %d lines for %d", len(result.Lines), len(lines))
+	}
+
+	pricing.Tax = result.Tax
+	pricing.TaxInclusive = result.Inclusive
+	pricing.TaxLines = result.Lines
+	for i := range pricing.Lines {
+		pricing.Lines[i].Tax = result.Lines[i].Tax
+	}
+	if !result.Inclusive {
+		if pricing.Total, err = pricing.Total.Add(result.Tax); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the user's default shipping address, nil when there is none
+func defaultShippingAddress(ctx context.Context, db *mongo.Client, userId primitive.ObjectID) (*model.Address, error) {
+	var address model.Address
+	err := db.Database("go-ecomm").Collection("addresses").FindOne(ctx, bson.M{
+		"userid":            userId,
+		"isdefaultshipping": true,
+	}).Decode(&address)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -185,6 +186,15 @@ func (u *UserServiceStruct) UpdateMyProfile(user_data *request.UpdateRequest, us
 			user.ProfileImage = user_data.ProfileImage
 			updateData["profileImage"] = *user_data.ProfileImage
 		}
+		if user_data.Currency != nil {
+			currency := strings.ToUpper(*user_data.Currency)
+			if currency != "" && !model.IsValidCurrency(currency) {
+				err_chan <- model.ErrMsg{Err: fmt.Errorf("unsupported currency %q", currency), Code: 400}
+				return
+			}
+			user.Currency = currency
+			updateData["currency"] = currency
+		}
 		user.UpdatedAt = time.Now()
 		updateData["updatedAt"] = user.UpdatedAt
 
@@ -0,0 +1,715 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"github.com/souvikjs01/go-ecommerce/request"
+	"github.com/souvikjs01/go-ecommerce/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// An empty wishlistId stands for the user's saved for later list, which is
+// created the first time something is saved.
+type WishlistService interface {
+	CreateWishlist(payload *request.WishlistPayload, userId string) (*model.Wishlist, error)
+	GetWishlists(userId string) (*[]model.Wishlist, error)
+	GetWishlist(userId, wishlistId string) (*model.Wishlist, error)
+	GetSharedWishlist(token string) (*model.Wishlist, error)
+	UpdateWishlist(payload *request.UpdateWishlistPayload, userId, wishlistId string) (*model.Wishlist, error)
+	DeleteWishlist(userId, wishlistId string) (*model.Wishlist, error)
+	AddToWishlist(payload *request.WishlistItemPayload, userId, wishlistId string) (*model.Wishlist, error)
+	RemoveFromWishlist(userId, wishlistId, productId string) (*model.Wishlist, error)
+	MoveFromCart(userId, wishlistId, productId string) (*model.Wishlist, error)
+	MoveToCart(userId, wishlistId, productId string) (*model.Cart, error)
+	GetProductWishlistCounts(limit int) (*[]model.ProductWishlistCount, error)
+}
+
+type WishlistServiceStruct struct {
+	db *mongo.Client
+	// the most of one product a cart line can hold
+	maxLineQuantity int
+}
+
+func NewWishlistService(db *mongo.Client, maxLineQuantity int) *WishlistServiceStruct {
+	return &WishlistServiceStruct{
+		db:              db,
+		maxLineQuantity: maxLineQuantity,
+	}
+}
+
+func (w *WishlistServiceStruct) CreateWishlist(payload *request.WishlistPayload, userId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	wishlist := model.NewWishlist(usrObjID, payload.Name, model.WishlistKindWishlist)
+	if payload.Public {
+		if err := share(wishlist); err != nil {
+			return nil, err
+		}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		if err := w.checkName(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		if _, err := w.db.Database("go-ecomm").Collection("wishlists").InsertOne(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// the user's named wishlists, without the saved for later list
+func (w *WishlistServiceStruct) GetWishlists(userId string) (*[]model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistsChan := make(chan *[]model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistsChan)
+
+		cur, err := w.db.Database("go-ecomm").Collection("wishlists").Find(ctx,
+			bson.M{"userid": usrObjID, "kind": model.WishlistKindWishlist},
+			options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}}))
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlists := []model.Wishlist{}
+		if err := cur.All(ctx, &wishlists); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistsChan <- &wishlists
+	}()
+
+	select {
+	case wishlists := <-wishlistsChan:
+		return wishlists, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// a wishlist with its products
+func (w *WishlistServiceStruct) GetWishlist(userId, wishlistId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if err := w.fillProducts(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// a public wishlist by its share token, for anyone who has the link
+func (w *WishlistServiceStruct) GetSharedWishlist(token string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		var wishlist model.Wishlist
+		err := w.db.Database("go-ecomm").Collection("wishlists").FindOne(ctx, bson.M{
+			"sharetoken": token,
+			"public":     true,
+		}).Decode(&wishlist)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if err := w.fillProducts(ctx, &wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- &wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Rename a wishlist or share it. Making a list private drops its token, so
+// links shared before stop working.
+func (w *WishlistServiceStruct) UpdateWishlist(payload *request.UpdateWishlistPayload, userId, wishlistId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	if wishlistId == "" {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid wishlistId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		if payload.Name != nil && strings.TrimSpace(*payload.Name) != wishlist.Name {
+			wishlist.Name = strings.TrimSpace(*payload.Name)
+			if err := w.checkName(ctx, wishlist); err != nil {
+				errChan <- err
+				return
+			}
+		}
+		if payload.Public != nil {
+			if *payload.Public && !wishlist.Public {
+				if err := share(wishlist); err != nil {
+					errChan <- err
+					return
+				}
+			} else if !*payload.Public {
+				wishlist.Public = false
+				wishlist.ShareToken = nil
+			}
+		}
+
+		if err := w.saveWishlist(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (w *WishlistServiceStruct) DeleteWishlist(userId, wishlistId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	wishlistObjID, err := primitive.ObjectIDFromHex(wishlistId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid wishlistId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		var wishlist model.Wishlist
+		err := w.db.Database("go-ecomm").Collection("wishlists").FindOneAndDelete(ctx, bson.M{
+			"_id":    wishlistObjID,
+			"userid": usrObjID,
+			"kind":   model.WishlistKindWishlist,
+		}).Decode(&wishlist)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- &wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (w *WishlistServiceStruct) AddToWishlist(payload *request.WishlistItemPayload, userId, wishlistId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(payload.ProductID)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid product_id"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		// out of stock products can be wished for, deleted ones can't
+		count, err := w.db.Database("go-ecomm").Collection("products").CountDocuments(ctx, bson.M{"_id": productObjID})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if count == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product not found"), Code: 404}
+			return
+		}
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlist.Add(productObjID, payload.Quantity)
+
+		if err := w.saveWishlist(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+func (w *WishlistServiceStruct) RemoveFromWishlist(userId, wishlistId, productId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if _, ok := wishlist.Remove(productObjID); !ok {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product is not on the list"), Code: 404}
+			return
+		}
+
+		if err := w.saveWishlist(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Move a cart line to a wishlist, or to saved for later, keeping its quantity
+func (w *WishlistServiceStruct) MoveFromCart(userId, wishlistId, productId string) (*model.Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	wishlistChan := make(chan *model.Wishlist, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(wishlistChan)
+
+		carts := w.db.Database("go-ecomm").Collection("carts")
+
+		var cart model.Cart
+		if err := carts.FindOne(ctx, bson.M{"userid": usrObjID}).Decode(&cart); err != nil {
+			errChan <- err
+			return
+		}
+		quantity := 0
+		for _, item := range cart.Products {
+			if item.ProductID == productObjID {
+				quantity = item.Quantity
+				break
+			}
+		}
+		if quantity == 0 {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product is not in the cart"), Code: 404}
+			return
+		}
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		wishlist.Add(productObjID, quantity)
+
+		// the list is saved first, a failure after it leaves the product in
+		// both places rather than in neither
+		if err := w.saveWishlist(ctx, wishlist); err != nil {
+			errChan <- err
+			return
+		}
+		_, err = carts.UpdateOne(ctx, bson.M{"_id": cart.ID}, bson.M{
+			"$pull": bson.M{"products": bson.M{"productid": productObjID}},
+			"$set":  cartTouched(),
+		})
+		if err != nil {
+			errChan <- fmt.Errorf("failed to remove product from cart: %w", err)
+			return
+		}
+		wishlistChan <- wishlist
+	}()
+
+	select {
+	case wishlist := <-wishlistChan:
+		return wishlist, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// Move a product from a wishlist, or from saved for later, into the cart
+func (w *WishlistServiceStruct) MoveToCart(userId, wishlistId, productId string) (*model.Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	cartChan := make(chan *model.Cart, 32)
+	errChan := make(chan error, 32)
+
+	usrObjID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid userId"), Code: 400}
+	}
+	productObjID, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, model.ErrMsg{Err: fmt.Errorf("invalid productId"), Code: 400}
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(cartChan)
+
+		db := w.db.Database("go-ecomm")
+
+		wishlist, err := w.findWishlist(ctx, usrObjID, wishlistId)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		item, ok := wishlist.Remove(productObjID)
+		if !ok {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product is not on the list"), Code: 404}
+			return
+		}
+
+		var prod model.Product
+		err = db.Collection("products").FindOne(ctx, bson.M{"_id": productObjID}).Decode(&prod)
+		if err == mongo.ErrNoDocuments {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product no longer exists"), Code: 404}
+			return
+		} else if err != nil {
+			errChan <- err
+			return
+		}
+		if !prod.InStock {
+			errChan <- model.ErrMsg{Err: fmt.Errorf("product %s is out of stock", prod.Title), Code: 400}
+			return
+		}
+
+		cart, err := mergeIntoCart(ctx, w.db, usrObjID,
+			[]model.ProductDetails{{ProductID: productObjID, Quantity: item.Quantity}},
+			model.CartMergeSum, w.maxLineQuantity)
+		if err != nil {
+			errChan <- fmt.Errorf("failed to add product to cart: %w", err)
+			return
+		}
+		// only this item, the rest of the list may have changed meanwhile
+		_, err = db.Collection("wishlists").UpdateOne(ctx,
+			bson.M{"_id": wishlist.ID},
+			bson.M{
+				"$pull": bson.M{"items": bson.M{"productid": productObjID}},
+				"$set":  bson.M{"updatedat": time.Now()},
+			},
+		)
+		if err != nil {
+			fmt.Println("failed to remove moved product from wishlist:", err)
+		}
+		cartChan <- cart
+	}()
+
+	select {
+	case cart := <-cartChan:
+		return cart, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// How many wishlists and saved for later lists each product is on, the most
+// wished for first
+func (w *WishlistServiceStruct) GetProductWishlistCounts(limit int) (*[]model.ProductWishlistCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	countsChan := make(chan *[]model.ProductWishlistCount, 32)
+	errChan := make(chan error, 32)
+
+	if limit < 1 {
+		limit = 50
+	}
+	onKind := func(kind string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$kind", kind}}, 1, 0}}}
+	}
+	pipeline := bson.A{
+		bson.M{"$unwind": "$items"},
+		bson.M{"$group": bson.M{
+			"_id":           "$items.productid",
+			"wishlists":     onKind(model.WishlistKindWishlist),
+			"savedforlater": onKind(model.WishlistKindSavedForLater),
+			"users":         bson.M{"$addToSet": "$userid"},
+		}},
+		bson.M{"$addFields": bson.M{"users": bson.M{"$size": "$users"}}},
+		bson.M{"$sort": bson.D{{Key: "wishlists", Value: -1}, {Key: "savedforlater", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+		bson.M{"$lookup": bson.M{
+			"from":         "products",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "product",
+		}},
+		bson.M{"$addFields": bson.M{"title": bson.M{"$arrayElemAt": bson.A{"$product.title", 0}}}},
+		bson.M{"$project": bson.M{"product": 0}},
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(countsChan)
+
+		cur, err := w.db.Database("go-ecomm").Collection("wishlists").Aggregate(ctx, pipeline)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		counts := []model.ProductWishlistCount{}
+		if err := cur.All(ctx, &counts); err != nil {
+			errChan <- err
+			return
+		}
+		countsChan <- &counts
+	}()
+
+	select {
+	case counts := <-countsChan:
+		return counts, nil
+	case err := <-errChan:
+		return nil, err
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
+// the user's wishlist, or their saved for later list when wishlistId is
+// empty. A saved for later list that doesn't exist yet is returned unsaved.
+func (w *WishlistServiceStruct) findWishlist(ctx context.Context, userId primitive.ObjectID, wishlistId string) (*model.Wishlist, error) {
+	filter := bson.M{"userid": userId, "kind": model.WishlistKindSavedForLater}
+	if wishlistId != "" {
+		wishlistObjID, err := primitive.ObjectIDFromHex(wishlistId)
+		if err != nil {
+			return nil, model.ErrMsg{Err: fmt.Errorf("invalid wishlistId"), Code: 400}
+		}
+		filter = bson.M{"_id": wishlistObjID, "userid": userId, "kind": model.WishlistKindWishlist}
+	}
+
+	var wishlist model.Wishlist
+	err := w.db.Database("go-ecomm").Collection("wishlists").FindOne(ctx, filter).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments && wishlistId == "" {
+		return model.NewWishlist(userId, "Saved for later", model.WishlistKindSavedForLater), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+// a user's wishlists need different names to tell them apart
+func (w *WishlistServiceStruct) checkName(ctx context.Context, wishlist *model.Wishlist) error {
+	if wishlist.Name == "" {
+		return model.ErrMsg{Err: fmt.Errorf("name is required"), Code: 400}
+	}
+	count, err := w.db.Database("go-ecomm").Collection("wishlists").CountDocuments(ctx, bson.M{
+		"_id":    bson.M{"$ne": wishlist.ID},
+		"userid": wishlist.UserId,
+		"kind":   model.WishlistKindWishlist,
+		"name":   wishlist.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return model.ErrMsg{Err: fmt.Errorf("you already have a wishlist called %q", wishlist.Name), Code: 409}
+	}
+	return nil
+}
+
+func (w *WishlistServiceStruct) saveWishlist(ctx context.Context, wishlist *model.Wishlist) error {
+	wishlist.UpdatedAt = time.Now()
+	_, err := w.db.Database("go-ecomm").Collection("wishlists").ReplaceOne(ctx,
+		bson.M{"_id": wishlist.ID}, wishlist, options.Replace().SetUpsert(true))
+	return err
+}
+
+// fills in the current products of the items, deleted products are left nil
+func (w *WishlistServiceStruct) fillProducts(ctx context.Context, wishlist *model.Wishlist) error {
+	productIDs := make([]primitive.ObjectID, 0, len(wishlist.Items))
+	for _, item := range wishlist.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	cur, err := w.db.Database("go-ecomm").Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return err
+	}
+	products := []model.Product{}
+	if err := cur.All(ctx, &products); err != nil {
+		return err
+	}
+	byID := make(map[primitive.ObjectID]*model.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	for i := range wishlist.Items {
+		wishlist.Items[i].Product = byID[wishlist.Items[i].ProductID]
+	}
+	return nil
+}
+
+// makes the list public with a new share token
+func share(wishlist *model.Wishlist) error {
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		return fmt.Errorf("failed to create share token: %w", err)
+	}
+	wishlist.Public = true
+	wishlist.ShareToken = &token
+	return nil
+}
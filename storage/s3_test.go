@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// s3StandIn keeps the objects of one bucket in memory and checks the
+// signature of every request the way S3 does, from what arrived on the wire
+type s3StandIn struct {
+	t       *testing.T
+	region  string
+	access  string
+	secret  string
+	objects map[string][]byte
+	// what the last request looked like
+	host string
+	uri  string
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.host = r.Host
+	s.uri = r.RequestURI
+	body, _ := io.ReadAll(r.Body)
+	if err := s.verify(r, body); err != "" {
+		s.t.Errorf("%s %s: %s", r.Method, r.RequestURI, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.RequestURI] = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		data, ok := s.objects[r.RequestURI]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, r.RequestURI)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify rebuilds the canonical request and returns what is wrong with the
+// signature, if anything
+func (s *s3StandIn) verify(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "no AWS4-HMAC-SHA256 authorization: " + auth
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	when, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return "invalid x-amz-date: " + amzDate
+	}
+	if d := time.Since(when); d > 15*time.Minute || d < -15*time.Minute {
+		return "x-amz-date too far off: " + amzDate
+	}
+	date := when.Format("20060102")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	if fields["Credential"] != s.access+"/"+scope {
+		return "unexpected credential: " + fields["Credential"]
+	}
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		return "payload hash does not match the body"
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	var headers strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+fields["SignedHeaders"]+";", ";"+required+";") {
+			return required + " is not signed"
+		}
+	}
+	if r.Header.Get("Content-Type") != "" && !strings.Contains(fields["SignedHeaders"], "content-type") {
+		return "content-type is not signed"
+	}
+
+	path, query, _ := strings.Cut(r.RequestURI, "?")
+	canonical := strings.Join([]string{
+		r.Method,
+		path,
+		query,
+		headers.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + s.secret)
+	for _, part := range []string{date, s.region, "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if want := hex.EncodeToString(key); fields["Signature"] != want {
+		return "signature " + fields["Signature"] + " does not match " + want + " for canonical request\n" + canonical
+	}
+	return ""
+}
+
+func TestS3StorageSignsRequests(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pathStyle bool
+		wantHost  string
+		wantURI   string
+	}{
+		{"path style", true, "", "/photos/products/a%20b%2Bc.png"},
+		{"virtual host", false, "photos.s3.test", "/products/a%20b%2Bc.png"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := &s3StandIn{t: t, region: "eu-west-1", access: "AKIDEXAMPLE", secret: "secret", objects: map[string][]byte{}}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			endpoint := server.URL
+			if !tc.pathStyle {
+				endpoint = "http://s3.test"
+			}
+			store := NewS3Storage(S3Config{
+				Endpoint:  endpoint,
+				Region:    "eu-west-1",
+				Bucket:    "photos",
+				AccessKey: "AKIDEXAMPLE",
+				SecretKey: "secret",
+				PathStyle: tc.pathStyle,
+			})
+			// every bucket host name resolves to the stand-in
+			dialer := &net.Dialer{}
+			store.Client.Transport = &http.Transport{
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+				},
+			}
+			wantHost := tc.wantHost
+			if wantHost == "" {
+				wantHost = strings.TrimPrefix(server.URL, "http://")
+			}
+			check := func(method string) {
+				t.Helper()
+				if standIn.host != wantHost {
+					t.Errorf("%s went to host %q, want %q", method, standIn.host, wantHost)
+				}
+				if standIn.uri != tc.wantURI {
+					t.Errorf("%s went to %q, want %q", method, standIn.uri, tc.wantURI)
+				}
+			}
+
+			ctx := context.Background()
+			key := "products/a b+c.png"
+			if err := store.Save(ctx, key, strings.NewReader("image data"), "image/png"); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			check(http.MethodPut)
+
+			r, err := store.Open(ctx, key)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if !bytes.Equal(data, []byte("image data")) {
+				t.Errorf("Open read %q, want %q", data, "image data")
+			}
+			check(http.MethodGet)
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			check(http.MethodDelete)
+
+			if _, err := store.Open(ctx, key); err != ErrNotFound {
+				t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StorageRejectsBadKeys(t *testing.T) {
+	store := NewS3Storage(S3Config{Endpoint: "http://s3.test", Bucket: "photos"})
+	for _, key := range []string{"", "../secret", "products/../../etc"} {
+		if err := store.Delete(context.Background(), key); err == nil || !strings.Contains(err.Error(), "invalid storage key") {
+			t.Errorf("Delete(%q) = %v, want an invalid key error", key, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/souvikjs01/go-ecommerce/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Calculator works out the tax of a cart or order. The rate table is the
+// built in implementation, an external tax service can implement it as well.
+type Calculator interface {
+	Calculate(ctx context.Context, req *Request) (*Result, error)
+}
+
+// Request describes the lines to tax and where they are shipped to
+type Request struct {
+	Currency string
+	Country  string
+	Region   string
+	Lines    []Line
+}
+
+type Line struct {
+	ProductID   primitive.ObjectID
+	TaxCategory string
+	Quantity    int
+	// price of the line after discounts, including tax when prices are tax inclusive
+	Amount model.Money
+}
+
+type Result struct {
+	// whether the amounts of the request already contained the tax
+	Inclusive bool
+	// in the order of the request lines
+	Lines []model.TaxLine
+	Tax   model.Money
+}
+
+// RateLookup returns the country and region rates that apply to an address
+type RateLookup func(ctx context.Context, country, region string) ([]model.TaxRate, error)
+
+// TableCalculator charges the rates of an admin managed rate table
+type TableCalculator struct {
+	lookup    RateLookup
+	inclusive bool
+}
+
+func NewTableCalculator(lookup RateLookup, inclusive bool) *TableCalculator {
+	return &TableCalculator{
+		lookup:    lookup,
+		inclusive: inclusive,
+	}
+}
+
+func (t *TableCalculator) Calculate(ctx context.Context, req *Request) (*Result, error) {
+	result := &Result{
+		Inclusive: t.inclusive,
+		Lines:     make([]model.TaxLine, 0, len(req.Lines)),
+		Tax:       model.ZeroMoney(req.Currency),
+	}
+
+	var rates []model.TaxRate
+	if req.Country != "" {
+		var err error
+		if rates, err = t.lookup(ctx, req.Country, req.Region); err != nil {
+			return nil, fmt.Errorf("failed to load tax rates: %w", err)
+		}
+	}
+
+	for _, line := range req.Lines {
+		category := model.NormalizeTaxCategory(line.TaxCategory)
+		taxLine, err := taxLine(line, category, ratesFor(rates, category), t.inclusive)
+		if err != nil {
+			return nil, err
+		}
+		if result.Tax, err = result.Tax.Add(taxLine.Tax); err != nil {
+			return nil, err
+		}
+		result.Lines = append(result.Lines, *taxLine)
+	}
+	return result, nil
+}
+
+// rates of the category, lines of a category without rates are not taxed
+func ratesFor(rates []model.TaxRate, category string) []model.TaxRate {
+	matching := []model.TaxRate{}
+	for _, rate := range rates {
+		if rate.Category == category {
+			matching = append(matching, rate)
+		}
+	}
+	return matching
+}
+
+func taxLine(line Line, category string, rates []model.TaxRate, inclusive bool) (*model.TaxLine, error) {
+	var total int64
+	for _, rate := range rates {
+		total += rate.RateBasisPoints
+	}
+
+	// with inclusive prices the tax is taken out of the amount
+	taxable := line.Amount
+	if inclusive && total > 0 {
+		net, err := line.Amount.MulRat(10000, 10000+total)
+		if err != nil {
+			return nil, err
+		}
+		taxable = net
+	}
+
+	taxLine := &model.TaxLine{
+		ProductID:   line.ProductID,
+		TaxCategory: category,
+		Taxable:     taxable,
+		Tax:         model.ZeroMoney(line.Amount.Currency),
+		Rates:       make([]model.AppliedTax, 0, len(rates)),
+	}
+	for n, rate := range rates {
+		amount, err := taxable.MulRat(rate.RateBasisPoints, 10000)
+		if err != nil {
+			return nil, err
+		}
+		// the last inclusive rate takes the rounding so net and tax add up to the price
+		if inclusive && n == len(rates)-1 {
+			gross, err := line.Amount.Sub(taxable)
+			if err == nil {
+				amount, err = gross.Sub(taxLine.Tax)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if taxLine.Tax, err = taxLine.Tax.Add(amount); err != nil {
+			return nil, err
+		}
+		taxLine.Rates = append(taxLine.Rates, model.AppliedTax{
+			Name:            rate.Name,
+			RateBasisPoints: rate.RateBasisPoints,
+			Amount:          amount,
+		})
+	}
+	return taxLine, nil
+}
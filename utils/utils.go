@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -25,20 +30,30 @@ func VerifyPassword(password, hash string) bool {
 	return err == nil
 }
 
+// GetRedis never fails, without a usable UPSTASH_URI every command returns
+// an error instead
 func GetRedis() *redis.Client {
-
-	// Get the Redis URI
-	cfg, _ := config.SetConfig()
-	REDIS_URI := cfg.UPSTASH_URI
-	fmt.Println("REDIS_URI", REDIS_URI)
-
-	opt, _ := redis.ParseURL(REDIS_URI)
-	client := redis.NewClient(opt)
-	fmt.Println("client", client)
-
+	client, err := NewRedis()
+	if err != nil {
+		fmt.Println("redis unavailable:", err)
+		return redis.NewClient(&redis.Options{})
+	}
 	return client
 }
 
+// NewRedis connects to the Redis at UPSTASH_URI
+func NewRedis() (*redis.Client, error) {
+	cfg, err := config.SetConfig()
+	if err != nil {
+		return nil, err
+	}
+	opt, err := redis.ParseURL(cfg.UPSTASH_URI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPSTASH_URI: %w", err)
+	}
+	return redis.NewClient(opt), nil
+}
+
 func CreateJWTToken(userId string, username string, isAdmin bool) (string, error) {
 	config, _ := config.SetConfig()
 	token := jwt.NewWithClaims(
@@ -71,3 +86,59 @@ func JWTVerification(tokenString string) (*jwt.Token, error) {
 	}
 	return token, nil
 }
+
+// SignValue appends an HMAC of the value so a cookie can't be forged
+func SignValue(value string) string {
+	config, _ := config.SetConfig()
+	return value + "." + valueSignature(value, config.JWT_SECRET)
+}
+
+// VerifySignedValue returns the value of a SignValue string when its
+// signature matches
+func VerifySignedValue(signed string) (string, bool) {
+	config, _ := config.SetConfig()
+	i := strings.LastIndex(signed, ".")
+	if i < 0 {
+		return "", false
+	}
+	value, signature := signed[:i], signed[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(valueSignature(value, config.JWT_SECRET))) {
+		return "", false
+	}
+	return value, true
+}
+
+func valueSignature(value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// RandomToken returns an unguessable url safe token of n random bytes
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CSVSafe keeps spreadsheet programs from running cells as formulas. Cells
+// that start like one get a leading quote, as do cells that already start
+// with a quote, so CSVUnquote gives back the original.
+func CSVSafe(cells []string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@'", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
+
+// CSVUnquote undoes CSVSafe on a cell read back from a file
+func CSVUnquote(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune("=+-@'", rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}